@@ -1,8 +1,14 @@
 package csvconv
 
 import (
+	"cmp"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
+	"os"
+	"slices"
 	"strings"
 
 	"github.com/urfave/cli/v2"
@@ -14,6 +20,14 @@ func join(lines ...string) string {
 	return strings.Join(lines, "\n")
 }
 
+// firstRune returns the first rune of s, or 0 if s is empty.
+func firstRune(s string) rune {
+	for _, r := range s {
+		return r
+	}
+	return 0
+}
+
 func setupLevel(verbose bool) {
 	if verbose {
 		slog.SetLogLoggerLevel(slog.LevelDebug)
@@ -21,12 +35,21 @@ func setupLevel(verbose bool) {
 	}
 }
 
+// printSyntaxError prints the error with a snippet pointing to the invalid statement.
+func printSyntaxError(w io.Writer, err error) {
+	slog.Error("Syntax Error", "error", err)
+	var synErr *csvlang.SyntaxError
+	if errors.As(err, &synErr) {
+		fmt.Fprintf(w, "%v\n%s\n", synErr, synErr.Snippet())
+	}
+}
+
 func App() *cli.App {
 	return &cli.App{
 		Name:     "DotApp: CSV Converter",
 		Usage:    "Convert CSV files",
 		HelpName: "csvconv",
-		Flags: []cli.Flag{
+		Flags: slices.Concat([]cli.Flag{
 			&cli.BoolFlag{
 				Name:    "verbose",
 				Aliases: []string{"v"},
@@ -39,13 +62,7 @@ func App() *cli.App {
 				Usage:   "Inline edit (overwrite src, dst must be empty)",
 				Value:   false,
 			},
-			&cli.StringFlag{
-				Name:     "input",
-				Aliases:  []string{"f"},
-				Usage:    "Input file",
-				Value:    "",
-				Required: true,
-			},
+		}, inputFlags(), []cli.Flag{
 			&cli.StringFlag{
 				Name:    "output",
 				Aliases: []string{"o"},
@@ -59,12 +76,53 @@ func App() *cli.App {
 				Value:   "auto",
 			},
 			&cli.StringFlag{
-				Name:    "delim",
-				Aliases: []string{"d"},
-				Usage:   "Delimiter for src/dst (can be set separately or together, e.g. -d ',;' | -d ',')",
-				Value:   ",",
+				Name:  "output-encoding",
+				Usage: "Output encoding (auto, utf-8, utf-8-bom, windows-1252, ...), auto keeps the BOM of UTF-8 input",
+				Value: "auto",
 			},
-		},
+			&cli.StringFlag{
+				Name:  "quote",
+				Usage: "Quoting of CSV output (minimal, all, non-numeric)",
+				Value: "minimal",
+			},
+			&cli.StringFlag{
+				Name:  "output-format",
+				Usage: "Output format (auto, csv, tsv, json, jsonl, markdown, table), auto uses the output file extension",
+				Value: "auto",
+			},
+			&cli.StringFlag{
+				Name:  "on-error",
+				Usage: "Handling of invalid values (fail, skip, keep, blank), skip drops the row, keep and blank keep it",
+				Value: "fail",
+			},
+			&cli.StringFlag{
+				Name:  "rejects",
+				Usage: "CSV file for rows with invalid values, with the failed statement and the error",
+				Value: "",
+			},
+			&cli.IntFlag{
+				Name:  "sort-memory",
+				Usage: "Memory budget of sort in MiB, larger inputs are sorted in temp files, 0 sorts in memory",
+				Value: converters.DefaultSortMemory >> 20,
+			},
+			&cli.StringFlag{
+				Name:    "query-file",
+				Aliases: []string{"q"},
+				Usage:   "Query file with one statement per line, # comments, and let variables, query args are appended",
+				Value:   "",
+			},
+			&cli.StringFlag{
+				Name:  "pipeline",
+				Usage: "Named pipeline of the pipelines file to run, query args are appended",
+				Value: "",
+			},
+			&cli.StringFlag{
+				Name:    "pipelines",
+				Usage:   "Pipelines file with named queries (default: " + DefaultPipelinesFile() + ")",
+				Value:   "",
+				EnvVars: []string{"CSVCONV_PIPELINES"},
+			},
+		}),
 		Args:      true,
 		ArgsUsage: "src [dst]  # no more flags after this",
 		Description: join(
@@ -73,80 +131,492 @@ func App() *cli.App {
 			"   'select a,b,c | numbers dot'",
 			"   'select 1,2,3 | dates iso'",
 			"   'select a,b,c | numbers dot:comma'",
-			"   'select a,b,c | numbers dot:comma'",
-			"   'select a,b,c | numbers dot:comma'",
+			"   'if:de+eur amount > 100 | number:de+eur:en+group+2 amount'",
+			"   'date:%d.%m.%Y\\ %H:%M:rfc3339@UTC time'",
+			"   'join owners.csv on id=account_id left | select id, owner'",
+			"   'sort date:iso desc, amount:num:comma, name:natural nulls first'",
+			"   'dedupe by id keep last | sort date desc | head 10'",
+			"   'select * except id | rename /\\s+/ _ | move total first'",
+			"   'pivot:de date by category value amount agg sum'",
+			"   'extract memo /IBAN: (?P<iban>\\S+)/ | split name by /\\s+/ into first, last | explode tags by ;'",
+			"Query files and pipelines:",
+			"   -q cleanup.csvl 'head 10'",
+			"   run bank-cleanup -f in.csv",
+			"   repl -f in.csv",
+			"Multiple inputs:",
+			"   -f 'exports/*.csv' -f extra.csv --source-column _source",
+			"   -f exports/ --union",
+			"Excel:",
+			"   -d ',;' --output-encoding utf-8-bom --quote non-numeric",
 		),
-		Suggest:              true,
-		EnableBashCompletion: true,
-		HideVersion:          true,
+		Suggest:                   true,
+		DisableSliceFlagSeparator: true,
+		EnableBashCompletion:      true,
+		HideVersion:               true,
+		Commands:                  []*cli.Command{schemaCommand(), validateCommand(), runCommand(), replCommand()},
 		Action: func(ctx *cli.Context) error {
 			setupLevel(ctx.Bool("verbose"))
-			query := ctx.Args().Slice()
 			inline := ctx.Bool("inline")
-			src := ctx.String("input")
 			dst := ctx.String("output")
-			delim := ctx.String("delim")
-			dstDelim := delim
-
-			errs := []string{}
-
-			if len(delim) > 1 {
-				delims := strings.Split(delim, "")
-				delim = delims[0]
-				if len(delims) != 2 {
-					errs = append(errs, "delimiter must be a single character or a pair of characters")
-				} else {
-					dstDelim = delims[1]
-				}
-			}
+
+			src, opts, srcDelim, errs := inputOptions(ctx)
 
 			if src == "-" && inline {
 				errs = append(errs, "stdin cannot be used with inline")
 			}
-			if src == "" {
-				errs = append(errs, "src is required")
+			if inline && len(ctx.StringSlice("input")) > 1 {
+				errs = append(errs, "multiple inputs cannot be used with inline")
 			}
 			if inline && dst != "" {
 				errs = append(errs, "dst cannot be used with inline")
 			}
-			if len(delim) != 1 {
-				errs = append(errs, "delimiter must be a single character")
-			}
-			if len(dstDelim) != 1 {
-				errs = append(errs, "destination delimiter must be a single character")
-			}
 
-			mode, modeErr := ParseNLMode(ctx.String("nl"))
+			mode, modeErr := ParseNLMode(ctx.String("newline"))
 			if modeErr != nil {
 				errs = append(errs, "invalid output mode")
 			}
 
-			if len(errs) > 0 {
-				for _, err := range errs {
-					slog.Error("Argument Error", "error", err)
-				}
-				return errors.New("invalid arguments")
+			outputEncoding, encodingErr := ParseEncoding(ctx.String("output-encoding"))
+			if encodingErr != nil {
+				errs = append(errs, encodingErr.Error())
+			}
+
+			quote, quoteErr := ParseQuoteMode(ctx.String("quote"))
+			if quoteErr != nil {
+				errs = append(errs, quoteErr.Error())
 			}
 
-			statements := csvlang.Parse(query...)
+			format, formatErr := ParseOutputFormat(ctx.String("output-format"))
+			if formatErr != nil {
+				errs = append(errs, formatErr.Error())
+			}
+
+			onError, onErrorErr := converters.ParseErrorMode(ctx.String("on-error"))
+			if onErrorErr != nil {
+				errs = append(errs, onErrorErr.Error())
+			}
 
-			convs := []converters.Converter{}
-			for _, stmt := range statements {
-				convs = append(convs, stmt.Converter())
+			if err := argumentError(errs); err != nil {
+				return err
 			}
 
-			err := ConvertCsv(
+			program, vars, err := readQuery(ctx)
+			if err != nil {
+				slog.Error("Query Error", "error", err)
+				return cli.Exit("Invalid query", 1)
+			}
+
+			statements, err := csvlang.ParseFile(vars, program, ctx.Args().Slice()...)
+			if err != nil {
+				printSyntaxError(ctx.App.ErrWriter, err)
+				return cli.Exit("Invalid query", 1)
+			}
+
+			csvlang.SetTableReader(statements, func(file string) (converters.Records, error) {
+				return ReadCsvFile(srcDelim, file)
+			})
+			csvlang.SetSortMemory(statements, int64(ctx.Int("sort-memory"))<<20)
+
+			rows := csvlang.RowConverters(statements)
+			var rejects *converters.Rejects
+			var rejectsFile *RejectsFile
+			if rejectsPath := ctx.String("rejects"); onError != converters.ErrorFail || rejectsPath != "" {
+				rejects = converters.NewRejects(onError, nil)
+				if rejectsPath != "" {
+					if rejectsFile, err = CreateRejectsFile(rejectsPath); err != nil {
+						return err
+					}
+					rejects.Report = rejectsFile.Write
+				}
+				rows = csvlang.RejectRows(statements, rejects)
+			}
+
+			// blocking statements, e.g., sort, buffer their records while streaming
+			err = ConvertCSVStream(
 				src, dst,
-				WithDelimiters(rune(delim[0]), rune(dstDelim[0])),
-				WithOutputMode(mode),
-				WithInline(inline),
-				With(convs...),
+				append(opts,
+					WithOutputMode(mode),
+					WithOutputEncoding(outputEncoding),
+					WithQuoting(quote),
+					WithOutputFormat(format),
+					WithInline(inline),
+					WithRows(rows...),
+				)...,
 			)
+			if rejectsFile != nil {
+				err = errors.Join(err, rejectsFile.Close())
+			}
 			if err != nil {
 				slog.Error("Conversion Error", "error", err)
 				return cli.Exit("Conversion failed", 1)
 			}
+			if rejects != nil && rejects.Count > 0 {
+				slog.Warn("Rejected invalid values", "count", rejects.Count, "on-error", ctx.String("on-error"))
+			}
+			return nil
+		},
+	}
+}
+
+// readQuery returns the csvlang program of the query file or pipeline, if any,
+// and the variables of the pipeline.
+func readQuery(ctx *cli.Context) (string, csvlang.Vars, error) {
+	file, name := ctx.String("query-file"), ctx.String("pipeline")
+	var program string
+	var vars csvlang.Vars
+	switch {
+	case file != "" && name != "":
+		return "", nil, errors.New("query-file cannot be used with pipeline")
+	case file != "":
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", nil, err
+		}
+		program = string(data)
+	case name != "":
+		pipelines, err := LoadPipelines(cmp.Or(ctx.String("pipelines"), DefaultPipelinesFile()))
+		if err != nil {
+			return "", nil, err
+		}
+		if program, vars, err = pipelines.Query(name); err != nil {
+			return "", nil, err
+		}
+	}
+	return program, vars, nil
+}
+
+// inputFlags returns the flags of the input, see [inputOptions].
+func inputFlags() []cli.Flag {
+	return []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    "input",
+			Aliases: []string{"f"},
+			Usage:   "Input file, glob, or directory, repeat to concatenate several inputs, e.g. -f 'exports/*.csv'",
+		},
+		&cli.StringFlag{
+			Name:  "source-column",
+			Usage: "Add a column with the input file of each row, e.g. _source",
+			Value: "",
+		},
+		&cli.BoolFlag{
+			Name:  "union",
+			Usage: "Allow inputs with different columns, missing values are empty",
+			Value: false,
+		},
+		&cli.StringFlag{
+			Name:  "input-format",
+			Usage: "Input format (auto, csv, tsv, json, jsonl, fixed), auto uses the input file extension",
+			Value: "auto",
+		},
+		&cli.StringFlag{
+			Name:  "fixed",
+			Usage: "Columns of fixed-width input, e.g. 'name:1-20,amount:21-32'",
+			Value: "",
+		},
+		&cli.StringFlag{
+			Name:    "delim",
+			Aliases: []string{"d"},
+			Usage:   "Delimiter for src/dst (can be set separately or together, e.g. -d ',;' | -d ','), detected if empty",
+			Value:   "",
+		},
+		&cli.StringFlag{
+			Name:  "header",
+			Usage: "Input has a header (yes, no, auto), auto guesses it from the first rows, column numbers are used if there is no header",
+			Value: "yes",
+		},
+		&cli.StringFlag{
+			Name:  "input-encoding",
+			Usage: "Input encoding (auto, utf-8, utf-16le, utf-16be, windows-1252, iso-8859-1)",
+			Value: "auto",
+		},
+	}
+}
+
+// inputOptions parses the flags of the input and the delimiters.
+// It returns the first input file, the options, and the input delimiter, or a list of errors.
+func inputOptions(ctx *cli.Context) (src string, opts []Opt, srcDelim rune, errs []string) {
+	delim := ctx.String("delim")
+	dstDelim := delim
+	if len(delim) > 1 {
+		delims := strings.Split(delim, "")
+		delim = delims[0]
+		if len(delims) != 2 {
+			errs = append(errs, "delimiter must be a single character or a pair of characters")
+		} else {
+			dstDelim = delims[1]
+		}
+	}
+	if len(delim) > 1 {
+		errs = append(errs, "delimiter must be a single character")
+	}
+	if len(dstDelim) > 1 {
+		errs = append(errs, "destination delimiter must be a single character")
+	}
+	inputs, inputErr := ExpandInputs(ctx.StringSlice("input")...)
+	switch {
+	case inputErr != nil:
+		errs = append(errs, inputErr.Error())
+	case len(inputs) == 0:
+		errs = append(errs, "src is required")
+	default:
+		src = inputs[0]
+	}
+
+	header, headerErr := ParseHeaderMode(ctx.String("header"))
+	if headerErr != nil {
+		errs = append(errs, headerErr.Error())
+	}
+
+	encoding, encodingErr := ParseEncoding(ctx.String("input-encoding"))
+	if encodingErr != nil {
+		errs = append(errs, encodingErr.Error())
+	}
+
+	inFormat, formatErr := ParseInputFormat(ctx.String("input-format"))
+	if formatErr != nil {
+		errs = append(errs, formatErr.Error())
+	}
+
+	var fixed []FixedColumn
+	if spec := ctx.String("fixed"); spec != "" {
+		var fixedErr error
+		fixed, fixedErr = ParseFixedColumns(spec)
+		if fixedErr != nil {
+			errs = append(errs, fixedErr.Error())
+		}
+	}
+
+	srcDelim = firstRune(delim)
+	opts = []Opt{
+		WithDelimiters(srcDelim, firstRune(dstDelim)),
+		WithHeader(header),
+		WithEncoding(encoding),
+		WithInputFormat(inFormat),
+		WithFixedColumns(fixed...),
+		WithInputs(inputs...),
+		WithSourceColumn(ctx.String("source-column")),
+		WithUnion(ctx.Bool("union")),
+	}
+	return src, opts, srcDelim, errs
+}
+
+// argumentError logs the errors and returns an error if there are any.
+func argumentError(errs []string) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	for _, err := range errs {
+		slog.Error("Argument Error", "error", err)
+	}
+	return errors.New("invalid arguments")
+}
+
+func schemaCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "schema",
+		Usage: "Print the inferred schema of the input, or save it as JSON with -o",
+		Flags: append(inputFlags(),
+			&cli.IntFlag{
+				Name:  "sample",
+				Usage: "Number of records used to infer the schema, 0 reads all records",
+				Value: 1000,
+			},
+		),
+		Action: func(ctx *cli.Context) error {
+			setupLevel(ctx.Bool("verbose"))
+			if err := inheritFlags(ctx); err != nil {
+				return err
+			}
+			src, opts, _, errs := inputOptions(ctx)
+			format, formatErr := ParseOutputFormat(ctx.String("output-format"))
+			if formatErr != nil {
+				errs = append(errs, formatErr.Error())
+			}
+			if err := argumentError(errs); err != nil {
+				return err
+			}
+
+			schema, err := InferSchemaFile(src, ctx.Int("sample"), opts...)
+			if err != nil {
+				slog.Error("Schema Error", "error", err)
+				return cli.Exit("Schema inference failed", 1)
+			}
+
+			dst := ctx.String("output")
+			var data []byte
+			switch {
+			case format == FormatJSON || (format == AutoFormat && dst != ""):
+				data, err = json.MarshalIndent(schema, "", "  ")
+				data = append(data, '\n')
+			default:
+				data, err = WriteRecords(cmp.Or(format, FormatTable), WriterConfig{Delimiter: ','}, schema.Records())
+			}
+			if err != nil {
+				return err
+			}
+			if dst == "" {
+				_, err = ctx.App.Writer.Write(data)
+				return err
+			}
+			return WriteFileRaw(dst, data)
+		},
+	}
+}
+
+func validateCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "validate",
+		Usage:     "Validate the input against a schema file and report invalid values",
+		ArgsUsage: "schema.json",
+		Flags: append(inputFlags(),
+			&cli.IntFlag{
+				Name:  "max-errors",
+				Usage: "Stop after the given number of invalid values, 0 reports all",
+				Value: 100,
+			},
+		),
+		Action: func(ctx *cli.Context) error {
+			setupLevel(ctx.Bool("verbose"))
+			if err := inheritFlags(ctx); err != nil {
+				return err
+			}
+			src, opts, _, errs := inputOptions(ctx)
+			if ctx.NArg() != 1 {
+				errs = append(errs, "schema file is required")
+			}
+			if err := argumentError(errs); err != nil {
+				return err
+			}
+
+			data, err := os.ReadFile(ctx.Args().First())
+			if err != nil {
+				return err
+			}
+			schema, err := converters.ParseSchema(data)
+			if err != nil {
+				slog.Error("Schema Error", "error", err)
+				return cli.Exit("Invalid schema", 1)
+			}
+
+			count, maxErrors := 0, ctx.Int("max-errors")
+			report := func(rowErr converters.RowError) error {
+				fmt.Fprintln(ctx.App.Writer, rowErr)
+				if count++; maxErrors > 0 && count >= maxErrors {
+					return fmt.Errorf("%w: stopped after %d invalid values", converters.ErrSchemaMismatch, count)
+				}
+				return nil
+			}
+			if err := ValidateFile(src, schema, report, opts...); err != nil {
+				slog.Error("Validation Error", "error", err)
+				return cli.Exit("Validation failed", 1)
+			}
 			return nil
 		},
 	}
 }
+
+// runCommand runs a named pipeline. It accepts the flags of the conversion after the name,
+// e.g., "run bank-cleanup -f in.csv", and runs the app with the pipeline flag.
+func runCommand() *cli.Command {
+	return &cli.Command{
+		Name:            "run",
+		Usage:           "Run a named pipeline of the pipelines file",
+		ArgsUsage:       "name [flags] [query...]",
+		SkipFlagParsing: true,
+		Action: func(ctx *cli.Context) error {
+			name := ctx.Args().First()
+			if name == "" || strings.HasPrefix(name, "-") {
+				return argumentError([]string{"pipeline name is required"})
+			}
+			args := []string{ctx.App.HelpName}
+			for _, flag := range ctx.App.Flags {
+				if flagName := flag.Names()[0]; ctx.IsSet(flagName) {
+					for _, value := range flagValues(ctx, flagName) {
+						args = append(args, fmt.Sprintf("--%s=%s", flagName, value))
+					}
+				}
+			}
+			args = append(args, "--pipeline", name)
+			return ctx.App.RunContext(ctx.Context, append(args, ctx.Args().Tail()...))
+		},
+	}
+}
+
+// inheritFlags sets the flags of the command that are set on the parent command,
+// so that they can be given before or after the command name.
+func inheritFlags(ctx *cli.Context) error {
+	parent := ctx.Lineage()[1]
+	for _, flag := range ctx.Command.Flags {
+		name := flag.Names()[0]
+		if parent.IsSet(name) && !ctx.IsSet(name) {
+			for _, value := range flagValues(parent, name) {
+				if err := ctx.Set(name, value); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// flagValues returns the command line values of a flag, one for each value of a slice flag.
+func flagValues(ctx *cli.Context, name string) []string {
+	if values, ok := ctx.Value(name).(cli.StringSlice); ok {
+		return values.Value()
+	}
+	return []string{fmt.Sprint(ctx.Value(name))}
+}
+
+func replCommand() *cli.Command {
+	return &cli.Command{
+		Name:      "repl",
+		Usage:     "Edit a query interactively with completion, history, and a preview of the result",
+		ArgsUsage: "[query...]",
+		Flags: append(inputFlags(),
+			&cli.IntFlag{
+				Name:  "rows",
+				Usage: "Number of previewed rows",
+				Value: 10,
+			},
+			&cli.StringFlag{
+				Name:  "history",
+				Usage: "History file of the queries, empty disables the history",
+				Value: DefaultHistoryFile(),
+			},
+		),
+		Action: func(ctx *cli.Context) error {
+			setupLevel(ctx.Bool("verbose"))
+			if err := inheritFlags(ctx); err != nil {
+				return err
+			}
+			src, opts, srcDelim, errs := inputOptions(ctx)
+			if err := argumentError(errs); err != nil {
+				return err
+			}
+
+			records, err := ReadInputFile(src, opts...)
+			if err != nil {
+				return err
+			}
+			repl := NewRepl(records, ctx.Int("rows"))
+			repl.Read = func(file string) (converters.Records, error) {
+				return ReadCsvFile(srcDelim, file)
+			}
+			repl.SetLine(strings.Join(ctx.Args().Slice(), " "))
+
+			var save func(query string) error
+			if file := ctx.String("history"); file != "" {
+				if repl.History, err = ReadHistory(file); err != nil {
+					return err
+				}
+				save = func(query string) error { return AppendHistory(file, query) }
+			}
+			if !ctx.Bool("verbose") {
+				// errors of the query are shown in the preview and not logged
+				level := slog.SetLogLoggerLevel(slog.LevelError + 1)
+				defer slog.SetLogLoggerLevel(level)
+			}
+			return repl.Run(os.Stdin, ctx.App.Writer, save)
+		},
+	}
+}
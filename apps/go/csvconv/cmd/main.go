@@ -3,6 +3,7 @@ package main
 import (
 	"log/slog"
 	"os"
+	_ "time/tzdata" // time zones of date formats on systems without zoneinfo
 
 	"ubunatic.com/dotapps/go/csvconv"
 )
@@ -3,11 +3,16 @@ package main_test
 import (
 	"log/slog"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
 	"ubunatic.com/dotapps/go/csvconv"
+	"ubunatic.com/dotapps/go/csvconv/converters"
+	"ubunatic.com/dotapps/go/csvconv/csvlang"
+	"ubunatic.com/dotapps/go/tui"
 )
 
 func TestCli(t *testing.T) {
@@ -45,3 +50,641 @@ func TestHelp(t *testing.T) {
 	err := app.Run(args)
 	require.NoError(t, err)
 }
+
+// testFiles writes the files to a new temp dir and returns its path.
+// File names may contain subdirectories.
+func testFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(data), 0o644))
+	}
+	return dir
+}
+
+// runApp runs csvconv with the args, where "{dir}" is replaced with dir,
+// and returns the output and the error output of the app.
+func runApp(dir string, args ...string) (stdout, stderr string, err error) {
+	app := csvconv.App()
+	out, errOut := &strings.Builder{}, &strings.Builder{}
+	app.Writer, app.ErrWriter = out, errOut
+	app.ExitErrHandler = func(*cli.Context, error) {} // do not exit the test
+	argv := []string{"csvconv"}
+	for _, arg := range args {
+		argv = append(argv, strings.ReplaceAll(arg, "{dir}", dir))
+	}
+	err = app.Run(argv)
+	return out.String(), errOut.String(), err
+}
+
+func TestConvert(t *testing.T) {
+	utf16 := func(s string) string {
+		data := []byte{0xFF, 0xFE}
+		for _, r := range s {
+			data = append(data, byte(r), byte(r>>8))
+		}
+		return string(data)
+	}
+	bank := strings.Join([]string{
+		"Buchung;Betrag;Zweck",
+		"24.12.2023;-12,50;Geschenk",
+		"27.12.2023;1.000,00;Miete",
+		"offen;n/a;Rest",
+		"",
+	}, "\n")
+	query := strings.Join([]string{
+		"# large amounts",
+		"let threshold = 100",
+		"if amount > $threshold",
+		"  and name != $CSVCONV_TEST_NAME",
+		"select name",
+	}, "\n")
+	notes := "name,amount,note\nMüller,-12.50,\"a \"\"b\"\"\"\n€ Gebühr,3,\n"
+	t.Setenv("CSVCONV_TEST_NAME", "Grace")
+
+	tests := []struct {
+		name    string
+		files   map[string]string // files of the test dir, in.csv is used as input if present
+		args    []string          // flags and query, "{dir}" is replaced with the test dir
+		want    string            // content of out.csv
+		outputs map[string]string // content of other output files
+		stderr  string            // expected error output, if the run fails
+		err     bool
+	}{
+		// inputs
+		{name: "bom", files: map[string]string{"in.csv": "\xEF\xBB\xBFName;Betrag\r\nMüller;-12,50\r\n"},
+			want: "\xEF\xBB\xBFName;Betrag\r\nMüller;-12,50\r\n"},
+		{name: "windows-1252", files: map[string]string{"in.csv": "Empf\xE4nger;Betrag;Zweck\r\nM\xFCller;-12,50;\x80 Geb\xFChr\r\n"},
+			args: []string{"select Empfänger, Zweck"}, want: "Empfänger;Zweck\r\nMüller;€ Gebühr\r\n"},
+		{name: "iso-8859-1", files: map[string]string{"in.csv": "Name|Ort\nJ\xFCrgen|K\xF6ln\n"},
+			want: "Name|Ort\nJürgen|Köln\n"},
+		{name: "utf-16", files: map[string]string{"in.csv": utf16("Name\tBetrag\r\nMüller\t-12,50\r\n")},
+			want: "Name\tBetrag\r\nMüller\t-12,50\r\n"},
+		{name: "no header", files: map[string]string{"in.csv": "Müller,\"1,234.50\",01.01.2024\nMeier,7,02.01.2024\n"},
+			args: []string{"--header", "auto", "select 1, 3"}, want: "1,3\nMüller,01.01.2024\nMeier,02.01.2024\n"},
+		{name: "numeric header", files: map[string]string{"in.csv": "id,2023,2024\na,1,2\nb,3,4\n"},
+			args: []string{"select id, 2024"}, want: "id,2024\na,2\nb,4\n"},
+		{name: "quoted", files: map[string]string{"in.csv": "\"a;b\",c\n\"1;2\",3\n"},
+			want: "a;b,c\n1;2,3\n"},
+		{name: "json", files: map[string]string{"in.json": `[{"id": 1, "account": {"iban": "DE00 1234"}, "amount": -12.50}, {"id": 2, "amount": 7}]`},
+			args: []string{"-f", "{dir}/in.json", "--output-format", "markdown", "filter amount < 0 | select id, account.iban as iban"},
+			want: "| id  | iban      |\n| --- | --------- |\n| 1   | DE00 1234 |\n"},
+
+		// outputs
+		{name: "default output", files: map[string]string{"in.csv": notes}, want: notes},
+		{name: "bom output", files: map[string]string{"in.csv": notes}, args: []string{"--output-encoding", "utf-8-bom", "-d", ",;"},
+			want: "\xEF\xBB\xBFname;amount;note\nMüller;-12.50;\"a \"\"b\"\"\"\n€ Gebühr;3;\n"},
+		{name: "windows-1252 output", files: map[string]string{"in.csv": notes}, args: []string{"--output-encoding", "cp1252", "-n", "crlf"},
+			want: "name,amount,note\r\nM\xFCller,-12.50,\"a \"\"b\"\"\"\r\n\x80 Geb\xFChr,3,\r\n"},
+		{name: "latin1 replaces €", files: map[string]string{"in.csv": notes}, args: []string{"--output-encoding", "latin1"},
+			want: "name,amount,note\nM\xFCller,-12.50,\"a \"\"b\"\"\"\n\x1A Geb\xFChr,3,\n"},
+		{name: "quote all", files: map[string]string{"in.csv": notes}, args: []string{"--quote", "all"},
+			want: "\"name\",\"amount\",\"note\"\n\"Müller\",\"-12.50\",\"a \"\"b\"\"\"\n\"€ Gebühr\",\"3\",\"\"\n"},
+		{name: "quote non-numeric", files: map[string]string{"in.csv": notes}, args: []string{"--quote", "non-numeric", "-d", ",;"},
+			want: "\"name\";\"amount\";\"note\"\n\"Müller\";-12.50;\"a \"\"b\"\"\"\n\"€ Gebühr\";3;\"\"\n"},
+
+		// statements
+		{name: "join", files: map[string]string{"in.csv": "id;name\n1;checking\n2;savings\n3;depot\n", "owners.csv": "account;name\n1;Alice\n2;Bob\n2;Carol\n"},
+			args: []string{"-d", ";", "join '{dir}/owners.csv' on id=account left"},
+			want: "id;name;owners.name\n1;checking;Alice\n2;savings;Bob\n2;savings;Carol\n3;depot;\n"},
+		{name: "dates", files: map[string]string{"in.csv": "time,created,level\n2024-03-01T09:15:00+01:00,1709280900,info\n2024-03-01T23:30:00Z,1709335800,warn\n"},
+			args: []string{`date:%d.%m.%Y\ %H:%M@Europe/Berlin time | date:unix:datetime@UTC created`},
+			want: "time,created,level\n01.03.2024 09:15,2024-03-01 08:15:00,info\n02.03.2024 00:30,2024-03-01 23:30:00,warn\n"},
+		{name: "ambiguous date", files: map[string]string{"in.csv": "date\n01/02/2024\n"}, args: []string{"date:any:iso date"}, err: true},
+		{name: "select cast", files: map[string]string{"in.csv": bank},
+			args: []string{"-d", ";,", "select:blank Buchung:date:iso as booked, Betrag:num:comma:dot as amount, Zweck"},
+			want: "booked,amount,Zweck\n2023-12-24,-12.50,Geschenk\n2023-12-27,\"1,000.00\",Miete\n,,Rest\n"},
+		{name: "invalid cast", files: map[string]string{"in.csv": bank}, args: []string{"select Buchung:date:iso"}, err: true},
+		{name: "number formats", files: map[string]string{"in.csv": "Konto;Betrag;Anteil\nMiete;(1.000,00 €);25,5%\nGehalt;2.500 €;74,5%\nZinsen;0,455 €;0%\n"},
+			args: []string{"-d", ";,", "if:de+eur+acct Betrag > 0 | number:de+eur+acct:en+usd+group+2 Betrag | number:de+pct:en Anteil"},
+			want: "Konto,Betrag,Anteil\nGehalt,\"$2,500.00\",0.745\nZinsen,$0.46,0\n"},
+		{name: "sort", files: map[string]string{"in.csv": "day;amount;file\n24.12.2023;1.000,5;img10\n01.01.2024;;img2\n24.12.2023;-3;img1\n01.01.2024;7;img2\n"},
+			args: []string{"--sort-memory", "0", "sort day:date:dd.mm.yyyy desc, amount:num:comma nulls first, file:natural"},
+			want: "day;amount;file\n01.01.2024;;img2\n01.01.2024;7;img2\n24.12.2023;-3;img1\n24.12.2023;1.000,5;img10\n"},
+		{name: "external sort", files: map[string]string{"in.csv": "day;amount;file\n24.12.2023;1.000,5;img10\n01.01.2024;;img2\n24.12.2023;-3;img1\n01.01.2024;7;img2\n"},
+			args: []string{"--sort-memory", "1", "sort day:date:dd.mm.yyyy desc, amount:num:comma nulls first, file:natural"},
+			want: "day;amount;file\n01.01.2024;;img2\n01.01.2024;7;img2\n24.12.2023;-3;img1\n24.12.2023;1.000,5;img10\n"},
+		{name: "limit", files: map[string]string{"in.csv": "id,name\n0,n0\n1,n1\n2,n2\n3,n0\n"},
+			args: []string{"skip 1 | head 2"}, want: "id,name\n1,n1\n2,n2\n"},
+		{name: "column ops", files: map[string]string{"in.csv": "7,Ada Lovelace,1.5,0.3\n8,Alan Turing,2,0.4\n"},
+			args: []string{"--header", "no", `header id, "full name", amount_net, amount_tax | select * except id | rename /\s+/ _ | move full_name last`},
+			want: "amount_net,amount_tax,full_name\n1.5,0.3,Ada Lovelace\n2,0.4,Alan Turing\n"},
+		{name: "pivot", files: map[string]string{"in.csv": "date;category;amount\n2024-01;food;12,50\n2024-01;rent;800\n2024-01;food;7,50\n2024-02;rent;800\n"},
+			args: []string{"pivot:de date by category value amount"}, want: "date;food;rent\n2024-01;20;800\n2024-02;;800\n"},
+		{name: "extract", files: map[string]string{"in.csv": "name,memo,tags\nAda Lovelace,SEPA IBAN: DE02120300000000202051 BIC: BYLADEM1001,math;code\nAlan Turing,card payment,\n"},
+			args: []string{`extract memo /IBAN: (?P<iban>\S+)/ | split name by ' ' into first, last | explode tags by ; | select first, last, iban, tags`},
+			want: "first,last,iban,tags\nAda,Lovelace,DE02120300000000202051,math\nAda,Lovelace,DE02120300000000202051,code\nAlan,Turing,,\n"},
+
+		// errors and queries
+		{name: "rejects", files: map[string]string{"in.csv": "id,amount\n1,1.5\n2,n/a\n3,2\n"},
+			args: []string{"--on-error", "skip", "--rejects", "{dir}/rejects.csv", "number:dot:comma amount"},
+			want: "id,amount\n1,\"1,5\"\n3,2\n", outputs: map[string]string{"rejects.csv": "row,statement,error,id,amount\n3,1:number,\"column \"\"amount\"\": invalid number\",2,n/a\n"}},
+		{name: "rejects on failure", files: map[string]string{"in.csv": "id,amount\n1,1.5\n2,n/a\n3,2\n"},
+			args:    []string{"--rejects", "{dir}/rejects.csv", "number:dot:comma amount"},
+			outputs: map[string]string{"rejects.csv": "row,statement,error,id,amount\n3,1:number,\"column \"\"amount\"\": invalid number\",2,n/a\n"}, err: true},
+		{name: "syntax error", args: []string{"-f", "-", "select a | foo b"}, stderr: "select a | foo b\n           ^"},
+		{name: "query file", files: map[string]string{"in.csv": "name,amount\nAda,120\nAlan,80\nGrace,300\n", "query.csvl": query},
+			args: []string{"-q", "{dir}/query.csvl"}, want: "name\nAda\n"},
+		{name: "query file error", files: map[string]string{"in.csv": "name,amount\n", "query.csvl": query},
+			args: []string{"-q", "{dir}/query.csvl", "sort $unknown"}, stderr: "at line 6, column 6: unknown variable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := testFiles(t, tt.files)
+			args := []string{"-o", "{dir}/out.csv"}
+			if _, ok := tt.files["in.csv"]; ok {
+				args = append(args, "-f", "{dir}/in.csv")
+			}
+			_, stderr, err := runApp(dir, append(args, tt.args...)...)
+			for name, want := range tt.outputs {
+				data, readErr := os.ReadFile(filepath.Join(dir, name))
+				require.NoError(t, readErr)
+				require.Equal(t, want, string(data), name)
+			}
+			if tt.err || tt.stderr != "" {
+				require.Error(t, err)
+				require.Contains(t, stderr, tt.stderr)
+				return
+			}
+			require.NoError(t, err)
+			data, err := os.ReadFile(filepath.Join(dir, "out.csv"))
+			require.NoError(t, err)
+			require.Equal(t, tt.want, string(data))
+		})
+	}
+}
+
+func TestStreamInline(t *testing.T) {
+	dir := testFiles(t, map[string]string{"in.csv": "a,b\n1.5,x\n2.5,y\n"})
+	_, _, err := runApp(dir, "-i", "-f", "{dir}/in.csv", "select b, a | number:dot:comma a")
+	require.NoError(t, err)
+	data, err := os.ReadFile(dir + "/in.csv")
+	require.NoError(t, err)
+	require.Equal(t, "b,a\nx,\"1,5\"\ny,\"2,5\"\n", string(data))
+}
+
+func TestStream(t *testing.T) {
+	src := os.TempDir() + "/csvconv_test_stream.csv"
+	dst1 := os.TempDir() + "/csvconv_test_stream1.csv"
+	dst2 := os.TempDir() + "/csvconv_test_stream2.csv"
+	defer os.Remove(src)
+	defer os.Remove(dst1)
+	defer os.Remove(dst2)
+
+	err := os.WriteFile(src, []byte(strings.Join([]string{
+		`name,age,date`,
+		`Charlie,35,12.12.2021`,
+		`Alice,25,01.01.2020`,
+		`Bob,30,03.03.2023`,
+		"", // nl
+	}, "\r\n")), 0o644)
+	require.NoError(t, err)
+
+	sortByName := func(r converters.Records) (converters.Records, error) {
+		return converters.Sort(r, "name", true, converters.NumberInvalid)
+	}
+
+	for _, program := range []string{
+		"select name, date | dates iso | filter name != Bob",
+		"filter age > 26 | select date as d, name",
+		"",
+	} {
+		t.Run(program, func(t *testing.T) {
+			rows := func() []converters.RowConverter {
+				statements, err := csvlang.Parse(program)
+				require.NoError(t, err)
+				rows := csvlang.RowConverters(statements)
+				return append(rows, converters.Buffer(sortByName))
+			}
+
+			err := csvconv.ConvertCsv(src, dst1, csvconv.WithRows(rows()...))
+			require.NoError(t, err)
+			err = csvconv.ConvertCSVStream(src, dst2, csvconv.WithRows(rows()...))
+			require.NoError(t, err)
+
+			want, err := os.ReadFile(dst1)
+			require.NoError(t, err)
+			got, err := os.ReadFile(dst2)
+			require.NoError(t, err)
+			require.Equal(t, string(want), string(got))
+			require.Contains(t, string(got), "\r\n")
+		})
+	}
+}
+
+func TestOutputFormats(t *testing.T) {
+	dir := t.TempDir()
+	src := dir + "/data.csv"
+	require.NoError(t, os.WriteFile(src, []byte("name,city\nJürgen,Köln\n\"Ann|e\",\"Sa\"\"n\nJosé\"\nZoe\u0308,東京,x\n"), 0o644))
+
+	tests := []struct {
+		format csvconv.OutputFormat
+		dst    string
+		want   string
+	}{
+		{csvconv.AutoFormat, "out.tsv", "name\tcity\nJürgen\tKöln\nAnn|e\t\"Sa\"\"n\nJosé\"\nZoe\u0308\t東京\tx\n"},
+		{csvconv.AutoFormat, "out.json", strings.Join([]string{
+			`[`,
+			`  {"name": "Jürgen", "city": "Köln"},`,
+			`  {"name": "Ann|e", "city": "Sa\"n\nJosé"},`,
+			`  {"name": "Zoe` + "\u0308" + `", "city": "東京", "3": "x"}`,
+			`]`,
+			``,
+		}, "\n")},
+		{csvconv.FormatJSONL, "out.csv", strings.Join([]string{
+			`{"name": "Jürgen", "city": "Köln"}`,
+			`{"name": "Ann|e", "city": "Sa\"n\nJosé"}`,
+			`{"name": "Zoe` + "\u0308" + `", "city": "東京", "3": "x"}`,
+			``,
+		}, "\n")},
+		{csvconv.AutoFormat, "out.md", strings.Join([]string{
+			`| name   | city      |     |`,
+			`| ------ | --------- | --- |`,
+			`| Jürgen | Köln      |     |`,
+			`| Ann\|e | Sa"n José |     |`,
+			"| Zoe\u0308    | 東京      | x   |",
+			``,
+		}, "\n")},
+		{csvconv.FormatTable, "out", strings.Join([]string{
+			`name    city`,
+			`Jürgen  Köln`,
+			`Ann|e   Sa"n José`,
+			"Zoe\u0308     東京       x",
+			``,
+		}, "\n")},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format)+tt.dst, func(t *testing.T) {
+			dst := dir + "/" + tt.dst
+			for _, convert := range []func(string, string, ...csvconv.Opt) error{csvconv.ConvertCsv, csvconv.ConvertCSVStream} {
+				err := convert(src, dst, csvconv.WithOutputFormat(tt.format))
+				require.NoError(t, err)
+				data, err := os.ReadFile(dst)
+				require.NoError(t, err)
+				require.Equal(t, tt.want, string(data))
+			}
+		})
+	}
+}
+
+func TestJSONKeys(t *testing.T) {
+	records := converters.Records{{"name", "name", "name_2", ""}, {"a", "b", "c", "d", "e"}}
+	data, err := csvconv.WriteRecords(csvconv.FormatJSONL, csvconv.WriterConfig{NL: csvconv.NoUseCRLF}, records)
+	require.NoError(t, err)
+	require.Equal(t, `{"name": "a", "name_2": "b", "name_2_2": "c", "": "d", "5": "e"}`+"\n", string(data))
+}
+
+func TestDisplayWidth(t *testing.T) {
+	require.Equal(t, 6, csvconv.DisplayWidth("Jürgen"))
+	require.Equal(t, 6, csvconv.DisplayWidth("Jürgen"))
+	require.Equal(t, 4, csvconv.DisplayWidth("東京"))
+}
+
+func TestInputFormats(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"data.json": `[
+			{"id": 1, "account": {"iban": "DE00 1234", "owner": null}, "amount": -12.50},
+			{"id": 2, "amount": 7, "tags": ["rent", "fix"]}
+		]`,
+		"data.jsonl": `{"id": 1, "account": {"iban": "DE00 1234", "owner": null}, "amount": -12.50}` + "\n" +
+			`{"id": 2, "amount": 7, "tags": ["rent", "fix"]}` + "\n",
+		"data.dat": "1   DE00 1234      -12.50\r\n\r\n2                    7\r\n",
+		"data.csv": "sep=;\r\nid;amount\r\n1;-12.50\r\n",
+	}
+	for name, data := range files {
+		require.NoError(t, os.WriteFile(dir+"/"+name, []byte(data), 0o644))
+	}
+
+	fixed, err := csvconv.ParseFixedColumns("id:1-4, account.iban:5-18, amount:19-")
+	require.NoError(t, err)
+
+	tests := []struct {
+		src  string
+		opts []csvconv.Opt
+		want string
+	}{
+		{"data.json", nil, strings.Join([]string{
+			"id,account.iban,account.owner,amount,tags.0,tags.1",
+			"1,DE00 1234,,-12.50,,",
+			"2,,,7,rent,fix",
+			"",
+		}, "\n")},
+		{"data.jsonl", nil, strings.Join([]string{
+			"id,account.iban,account.owner,amount,tags.0,tags.1",
+			"1,DE00 1234,,-12.50,,",
+			"2,,,7,rent,fix",
+			"",
+		}, "\n")},
+		{"data.dat", []csvconv.Opt{csvconv.WithFixedColumns(fixed...)}, strings.Join([]string{
+			"id,account.iban,amount",
+			"1,DE00 1234,-12.50",
+			"2,,7",
+			"",
+		}, "\r\n")},
+		{"data.csv", []csvconv.Opt{csvconv.WithDelimiters(',', ',')}, "id,amount\r\n1,-12.50\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			dst := dir + "/out.csv"
+			for _, convert := range []func(string, string, ...csvconv.Opt) error{csvconv.ConvertCsv, csvconv.ConvertCSVStream} {
+				err := convert(dir+"/"+tt.src, dst, tt.opts...)
+				require.NoError(t, err)
+				data, err := os.ReadFile(dst)
+				require.NoError(t, err)
+				require.Equal(t, tt.want, string(data))
+			}
+		})
+	}
+
+}
+
+func TestParseFixedColumns(t *testing.T) {
+	cols, err := csvconv.ParseFixedColumns("name:1-20,amount:21-32,flag:33")
+	require.NoError(t, err)
+	require.Equal(t, []csvconv.FixedColumn{
+		{Name: "name", Start: 1, End: 20},
+		{Name: "amount", Start: 21, End: 32},
+		{Name: "flag", Start: 33, End: 33},
+	}, cols)
+
+	for _, spec := range []string{"", "name", "name:0-2", "name:3-2", ":1-2", "a:x-2"} {
+		_, err := csvconv.ParseFixedColumns(spec)
+		require.ErrorIs(t, err, csvconv.ErrInvalidFixedColumns, spec)
+	}
+}
+
+func TestSniffDelimiter(t *testing.T) {
+	tests := []struct {
+		sample string
+		want   rune
+	}{
+		{"a,b,c\n1,2,3\n", ','},
+		{"a;b\n1,5;2,5\n3,0;4,0\n", ';'},
+		{"a\tb c\n1\t2\n", '\t'},
+		{"a|b\n1|2\n", '|'},
+		{"\"x, y\";b\n\"1, 2\";3\n", ';'},
+		{"single\nvalue\n", ','},
+	}
+	for _, tt := range tests {
+		got, _ := csvconv.SniffDelimiter(tt.sample)
+		require.Equal(t, string(tt.want), string(got), tt.sample)
+	}
+
+	require.True(t, csvconv.GuessHeader([][]string{{"name", "amount"}, {"Bob", "1,50"}}))
+	require.True(t, csvconv.GuessHeader([][]string{{"2023", "name"}, {"Bob", "1,50"}}))
+	require.False(t, csvconv.GuessHeader([][]string{{"Alice", "2,00"}, {"Bob", "1,50"}}))
+	require.False(t, csvconv.GuessHeader([][]string{{"01.01.2024", "x"}, {"2024-01-02", "y"}}))
+	require.True(t, csvconv.GuessHeader([][]string{{"total", "2024", "name"}, {"5", "3", "Bob"}}), "text above a number")
+	require.True(t, csvconv.GuessHeader([][]string{{"name", "city"}, {"Bob", "Berlin"}}), "text only")
+}
+
+func TestSchema(t *testing.T) {
+	dir := testFiles(t, map[string]string{"export.csv": strings.Join([]string{
+		"Buchung;Betrag;Anzahl;Storno;Notiz",
+		"24.12.2023;-12,50;1;no;",
+		"27.12.2023;1.000,00;2;yes;Miete",
+		"",
+	}, "\n")})
+	src := dir + "/export.csv"
+	schemaFile := dir + "/export.schema.json"
+
+	out, _, err := runApp(dir, "-f", src, "schema")
+	require.NoError(t, err)
+	require.Equal(t, strings.Join([]string{
+		"name     type    format      nullable",
+		"Buchung  date    dd.mm.yyyy  false",
+		"Betrag   float   comma       false",
+		"Anzahl   int                 false",
+		"Storno   bool                false",
+		"Notiz    string              true",
+		"",
+	}, "\n"), out)
+
+	after, _, err := runApp(dir, "schema", "-f", src, "--header", "yes")
+	require.NoError(t, err)
+	require.Equal(t, out, after, "input flags after the command")
+
+	_, _, err = runApp(dir, "-f", src, "-o", schemaFile, "schema")
+	require.NoError(t, err)
+	data, err := os.ReadFile(schemaFile)
+	require.NoError(t, err)
+	schema, err := converters.ParseSchema(data)
+	require.NoError(t, err)
+	require.Equal(t, converters.ColumnSchema{Name: "Betrag", Type: converters.TypeFloat, Format: "comma"}, schema.Columns[1])
+
+	_, _, err = runApp(dir, "-f", src, "validate", schemaFile)
+	require.NoError(t, err)
+	_, _, err = runApp(dir, "validate", "-f", src, schemaFile)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(src, []byte(strings.Join([]string{
+		"Buchung;Betrag;Anzahl;Storno;Notiz",
+		"2023-12-24;-12,50;1;no;",
+		"27.12.2023;;2.5;maybe;Miete",
+		"",
+	}, "\n")), 0o644))
+	out, _, err = runApp(dir, "-f", src, "validate", schemaFile)
+	require.Error(t, err)
+	require.Equal(t, strings.Join([]string{
+		`row 2, column "Buchung": invalid value: "2023-12-24" is not date (dd.mm.yyyy)`,
+		`row 3, column "Betrag": invalid value: empty value`,
+		`row 3, column "Anzahl": invalid value: "2.5" is not int`,
+		`row 3, column "Storno": invalid value: "maybe" is not bool`,
+		"",
+	}, "\n"), out)
+
+	require.NoError(t, os.WriteFile(src, []byte("Buchung;Betrag;Notiz;Anzahl\n"), 0o644))
+	err = csvconv.ValidateFile(src, schema, nil, csvconv.WithDelimiters(0, 0))
+	require.ErrorIs(t, err, converters.ErrSchemaMismatch)
+	require.ErrorContains(t, err, `missing columns ["Storno"], unexpected columns []`)
+}
+
+func TestPipelines(t *testing.T) {
+	dir := testFiles(t, map[string]string{
+		"in.csv":     "date;amount\n01.02.2024;1200,50\n03.02.2024;80,00\n",
+		"large.csvl": "if:de amount > $threshold\n",
+		"pipelines.yaml": strings.Join([]string{
+			"pipelines:",
+			"  bank-cleanup:",
+			"    description: Convert bank exports",
+			"    vars: {threshold: 100}",
+			"    query: |",
+			"      # German numbers and dates",
+			"      select date:date:dot:iso, amount:num:comma:dot",
+			"      if amount > $threshold",
+			"  large:",
+			"    file: large.csvl",
+			"    vars: {threshold: 1000}",
+		}, "\n"),
+	})
+	config := dir + "/pipelines.yaml"
+
+	run := func(args ...string) string {
+		_, _, err := runApp(dir, args...)
+		require.NoError(t, err)
+		data, err := os.ReadFile(dir + "/out.csv")
+		require.NoError(t, err)
+		return string(data)
+	}
+	require.Equal(t, "date;amount\n2024-02-01;1200.50\n", run("--pipelines", config, "run", "bank-cleanup", "-f", "{dir}/in.csv", "-o", "{dir}/out.csv"))
+	t.Setenv("CSVCONV_PIPELINES", config)
+	require.Equal(t, "date;amount\n01.02.2024;1200,50\n", run("--pipeline", "large", "-f", "{dir}/in.csv", "-o", "{dir}/out.csv"))
+	require.Equal(t, "amount\n1200,50\n", run("run", "large", "-f", "{dir}/in.csv", "-o", "{dir}/out.csv", "select amount"))
+
+	pipelines, err := csvconv.LoadPipelines(config)
+	require.NoError(t, err)
+	require.Equal(t, []string{"bank-cleanup", "large"}, pipelines.Names())
+	_, _, err = pipelines.Query("unknown")
+	require.ErrorIs(t, err, csvconv.ErrUnknownPipeline)
+	require.ErrorContains(t, err, "available pipelines: bank-cleanup, large")
+}
+
+func TestInputs(t *testing.T) {
+	dir := testFiles(t, map[string]string{
+		"exports/2024-01.csv": "date,amount\n2024-01-05,10\n",
+		"exports/2024-02.csv": "amount,date\n20,2024-02-05\n",
+		"exports/notes.txt":   "not an export\n",
+		"extra.csv":           "date,amount,memo\n2024-03-05,30,rent\n",
+		"other/0-empty.csv":   "",
+		"other/short.csv":     "a;b\n1\n",
+		"ragged.csv":          "a;b\n1;2;3\n",
+		"pipelines.yaml":      "pipelines:\n  all:\n    query: select date, amount\n",
+	})
+	months := "date,amount\n2024-01-05,10\n2024-02-05,20\n"
+
+	tests := []struct {
+		name string
+		args []string // "{dir}" is replaced with the test dir
+		want string   // "{dir}" is replaced with the test dir
+		err  bool
+	}{
+		{"directory", []string{"-f", "{dir}/exports"}, months, false},
+		{"glob", []string{"-f", "{dir}/exports/*.csv"}, months, false},
+		{"files", []string{"-f", "{dir}/exports/2024-01.csv", "-f", "{dir}/exports/2024-02.csv"}, months, false},
+		{"buffered", []string{"-f", "{dir}/exports", "sort date"}, months, false},
+		{"pipeline", []string{"-f", "{dir}/exports/2024-01.csv", "-f", "{dir}/exports/2024-02.csv", "--pipelines", "{dir}/pipelines.yaml", "run", "all"}, months, false},
+		{"source column", []string{"-f", "{dir}/exports", "--source-column", "_source", "select amount, _source"},
+			"amount,_source\n10,{dir}/exports/2024-01.csv\n20,{dir}/exports/2024-02.csv\n", false},
+		{"mismatch", []string{"-f", "{dir}/exports", "-f", "{dir}/extra.csv"}, "", true},
+		{"union", []string{"--union", "-f", "{dir}/extra.csv", "-f", "{dir}/exports"},
+			"date,amount,memo\n2024-03-05,30,rent\n2024-01-05,10,\n2024-02-05,20,\n", false},
+		{"no match", []string{"-f", "{dir}/*.tsv"}, "", true},
+		// each input is sniffed on its own, the output uses the settings of the first non-empty input
+		{"skip empty", []string{"-f", "{dir}/other", "--source-column", "_source", "select b, _source"}, "b;_source\n;{dir}/other/short.csv\n", false},
+		{"long record", []string{"-f", "{dir}/ragged.csv"}, "a;b\n1;2;3\n", false},
+		{"long record source", []string{"-f", "{dir}/ragged.csv", "--source-column", "_source"}, "", true},
+		{"empty", []string{"-f", "{dir}/other/0-empty.csv"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := dir + "/out.csv"
+			defer os.Remove(dst)
+			_, _, err := runApp(dir, append([]string{"-o", dst}, tt.args...)...)
+			if tt.err {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			data, err := os.ReadFile(dst)
+			require.NoError(t, err)
+			require.Equal(t, strings.ReplaceAll(tt.want, "{dir}", dir), string(data))
+		})
+	}
+
+	_, err := csvconv.ReadInputFile(dir + "/other/0-empty.csv")
+	require.ErrorIs(t, err, csvconv.ErrInvalidInput)
+	_, err = csvconv.ReadInputFile(dir+"/ragged.csv", csvconv.WithDelimiters(';', 0), csvconv.WithSourceColumn("_source"))
+	require.ErrorIs(t, err, csvconv.ErrInvalidInput)
+	require.ErrorContains(t, err, "ragged.csv: row 2 has 3 fields")
+	files, err := csvconv.ExpandInputs(dir+"/exports", dir+"/extra.csv")
+	require.NoError(t, err)
+	require.Equal(t, []string{dir + "/exports/2024-01.csv", dir + "/exports/2024-02.csv", dir + "/extra.csv"}, files)
+	_, err = csvconv.ExpandInputs("-", dir+"/exports")
+	require.ErrorIs(t, err, csvconv.ErrInvalidInput)
+}
+
+func TestRepl(t *testing.T) {
+	records := converters.Records{
+		{"name", "Last Name", "amount"},
+		{"Ada", "Lovelace", "120"},
+		{"Alan", "Turing", "80"},
+		{"Grace", "Hopper", "300"},
+	}
+	repl := csvconv.NewRepl(records, 2)
+	typing := func(text string) {
+		for _, b := range []byte(text) {
+			require.False(t, repl.Key(tui.KeyPress{Keycode: int(b), Char: string([]byte{b})}))
+		}
+	}
+	key := func(char string) bool {
+		return repl.Key(tui.KeyPress{Keycode: int(char[len(char)-1]), Char: char})
+	}
+
+	typing("se\t")
+	require.Equal(t, "se", repl.Line())
+	require.Equal(t, []string{"select", "sel", "set"}, repl.Candidates())
+	typing("l\t")
+	require.Equal(t, "sel", repl.Line(), "sel is a keyword and a prefix of select")
+	typing("ect na\t")
+	require.Equal(t, "select name", repl.Line())
+	typing(", La\t")
+	require.Equal(t, []string{"Last Name", "last"}, repl.Candidates())
+	typing("\x7f\x7f\"La\t, am\t")
+	require.Equal(t, `select name, "Last Name", amount`, repl.Line())
+	typing(" | set cents = amount * 100 | if ce\t")
+	require.Equal(t, `select name, "Last Name", amount | set cents = amount * 100 | if cents`, repl.Line(), "columns of previous statements")
+	typing(" > 10000")
+
+	require.Equal(t, strings.Join([]string{
+		"name   Last Name  amount  cents",
+		"Ada    Lovelace   120     12000",
+		"Grace  Hopper     300     30000",
+		"(2 of 2 rows)",
+		"",
+	}, "\n"), repl.Preview(repl.Line()))
+	require.Contains(t, repl.Preview("select foo"), "column not found")
+	require.Contains(t, repl.Preview("select a | foo b"), "select a | foo b\n           ^")
+	require.Equal(t, records[1], []string{"Ada", "Lovelace", "120"}, "previews do not change the records")
+
+	require.True(t, key("\r"))
+	repl.SetLine("")
+	typing("head 1")
+	key("\x1b[A")
+	require.Equal(t, repl.History[0], repl.Line())
+	key("\x1b[B")
+	require.Equal(t, "head 1", repl.Line())
+
+	key("\x1b[D")
+	key("\x1b[D")
+	key("\x1b[3")
+	key("~")
+	require.Equal(t, "head1", repl.Line())
+	key("\x01")
+	typing("\x0b\xc3")
+	require.Equal(t, "", repl.Line(), "incomplete UTF-8 sequences are not inserted")
+	typing("\xbc ber")
+	require.Equal(t, "ü ber", repl.Line())
+	typing("\x17\x7f")
+	require.Equal(t, "ü", repl.Line())
+
+	dir := testFiles(t, map[string]string{"in.csv": "a,b\n1,2\n"})
+	for _, args := range [][]string{
+		{"repl", "-f", "{dir}/in.csv", "--history", ""},
+		{"-f", "{dir}/in.csv", "repl", "--history", "{dir}/history"},
+	} {
+		_, _, err := runApp(dir, args...)
+		require.ErrorIs(t, err, csvconv.ErrNotTerminal, "tests do not run in a terminal")
+	}
+
+	history := dir + "/history"
+	require.NoError(t, csvconv.AppendHistory(history, "select a"))
+	require.NoError(t, csvconv.AppendHistory(history, "head 1"))
+	got, err := csvconv.ReadHistory(history)
+	require.NoError(t, err)
+	require.Equal(t, []string{"select a", "head 1"}, got)
+}
@@ -0,0 +1,208 @@
+package converters
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidAggregate = errors.New("invalid aggregate")
+
+// Aggregate computes a value over all records of a group, e.g., "sum(amount) as total".
+type Aggregate struct {
+	Func   string // count, sum, avg, min, or max
+	Column string // aggregated column, "*" for count(*)
+	As     string // name of the result column, defaults to "func_column"
+}
+
+// Name returns the name of the result column.
+func (a Aggregate) Name() string {
+	switch {
+	case a.As != "":
+		return a.As
+	case a.Column == "*" || a.Column == "":
+		return a.Func
+	default:
+		return a.Func + "_" + a.Column
+	}
+}
+
+// Aggregates lists all supported aggregate functions.
+var Aggregates = []string{"count", "sum", "avg", "min", "max"}
+
+// IsAggregate reports whether fn is a supported aggregate function.
+func IsAggregate(fn string) bool {
+	return slices.Contains(Aggregates, strings.ToLower(fn))
+}
+
+// accumulator collects the values of one aggregate for one group.
+type accumulator struct {
+	count int
+	sum   float64
+	value string // current min or max value
+}
+
+func (a *accumulator) add(fn, value string, format NumberFormat) error {
+	if value == "" {
+		return nil // empty values are ignored
+	}
+	switch fn {
+	case "sum", "avg":
+		n, err := parseNumberArg(value, format)
+		if err != nil {
+			return err
+		}
+		a.sum += n
+	case "min", "max":
+		if a.count > 0 {
+			cmp := compareValues(value, a.value, format)
+			if (fn == "min" && cmp >= 0) || (fn == "max" && cmp <= 0) {
+				break
+			}
+		}
+		a.value = value
+	}
+	a.count++
+	return nil
+}
+
+func (a *accumulator) result(fn string, format NumberFormat) string {
+	switch fn {
+	case "count":
+		return fmt.Sprint(a.count)
+	case "sum":
+		return FormatNumber(a.sum, format)
+	case "avg":
+		if a.count == 0 {
+			return ""
+		}
+		return FormatNumber(a.sum/float64(a.count), format)
+	default:
+		return a.value
+	}
+}
+
+// compareValues compares two values as numbers, dates, or strings, in that order.
+func compareValues(a, b string, format NumberFormat) int {
+	if na, ok := ParseNumber(a, format); ok {
+		if nb, ok := ParseNumber(b, format); ok {
+			switch {
+			case na < nb:
+				return -1
+			case na > nb:
+				return 1
+			}
+			return 0
+		}
+	}
+	if da, err := ParseDate(a, DateAny); err == nil {
+		if db, err := ParseDate(b, DateAny); err == nil {
+			return da.Compare(db)
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+// group holds the key fields and accumulators of one group.
+type group struct {
+	key  []string
+	accs []accumulator
+}
+
+// groupRows implements the [RowConverter] for [GroupRows].
+type groupRows struct {
+	by      []string
+	aggs    []Aggregate
+	format  NumberFormat
+	keys    []Column
+	cols    []int // index of the aggregated column, -1 for count(*)
+	groups  map[string]*group
+	ordered []*group // groups in order of appearance
+}
+
+// GroupRows returns a [RowConverter] that groups records by the given columns
+// and emits one record per group with the key fields and the aggregated values.
+// Groups are emitted in order of their first appearance after the last record.
+// Numbers are summed and averaged using the given format.
+func GroupRows(by []string, aggs []Aggregate, format NumberFormat) RowConverter {
+	return &groupRows{by: by, aggs: aggs, format: format}
+}
+
+// GroupBy groups and aggregates all records, see [GroupRows].
+func GroupBy(records Records, by []string, aggs []Aggregate, format NumberFormat) (Records, error) {
+	return ApplyRows(records, GroupRows(by, aggs, format))
+}
+
+func (g *groupRows) Header(header []string) ([]string, error) {
+	var err error
+	g.keys = []Column{}
+	if len(g.by) > 0 {
+		g.keys, err = ColumnIndex(header, Cols(g.by...)...)
+		if err != nil {
+			return nil, err
+		}
+	}
+	g.cols = make([]int, len(g.aggs))
+	result := ColNames(g.keys)
+	for i, agg := range g.aggs {
+		if !IsAggregate(agg.Func) {
+			return nil, fmt.Errorf("%w: unknown function %q", ErrInvalidAggregate, agg.Func)
+		}
+		g.cols[i] = -1
+		if agg.Column != "*" {
+			cols, err := ColumnIndex(header, Col(agg.Column))
+			if err != nil {
+				return nil, err
+			}
+			if len(cols) != 1 {
+				return nil, ErrInvalidColumnIndex
+			}
+			g.cols[i] = cols[0].Index
+		} else if !strings.EqualFold(agg.Func, "count") {
+			return nil, fmt.Errorf("%w: %s(*) is not supported", ErrInvalidAggregate, agg.Func)
+		}
+		result = append(result, agg.Name())
+	}
+	g.groups = map[string]*group{}
+	return result, nil
+}
+
+func (g *groupRows) Row(record []string, emit Emit) error {
+	key := make([]string, len(g.keys))
+	for i, col := range g.keys {
+		key[i] = Field(record, col.Index)
+	}
+	id := strings.Join(key, "\x00")
+	grp, ok := g.groups[id]
+	if !ok {
+		grp = &group{key: key, accs: make([]accumulator, len(g.aggs))}
+		g.groups[id] = grp
+		g.ordered = append(g.ordered, grp)
+	}
+	for i, agg := range g.aggs {
+		if g.cols[i] < 0 {
+			grp.accs[i].count++
+			continue
+		}
+		value := Field(record, g.cols[i])
+		if err := grp.accs[i].add(strings.ToLower(agg.Func), value, g.format); err != nil {
+			return fmt.Errorf("%w: %s(%s): %w", ErrInvalidAggregate, agg.Func, agg.Column, err)
+		}
+	}
+	return nil
+}
+
+func (g *groupRows) Flush(emit Emit) error {
+	for _, grp := range g.ordered {
+		record := slices.Clone(grp.key)
+		for i, agg := range g.aggs {
+			record = append(record, grp.accs[i].result(strings.ToLower(agg.Func), g.format))
+		}
+		if err := emit(record); err != nil {
+			return err
+		}
+	}
+	g.groups, g.ordered = nil, nil
+	return nil
+}
@@ -0,0 +1,40 @@
+package converters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupBy(t *testing.T) {
+	records := Records{
+		{"cat", "amount", "date"},
+		{"food", "1.000,50", "02.01.2024"},
+		{"rent", "800", "01.01.2024"},
+		{"food", "2,25", "2023-12-31"},
+		{"food", "", "15.01.2024"},
+	}
+	aggs := []Aggregate{
+		{Func: "sum", Column: "amount", As: "total"},
+		{Func: "count", Column: "*"},
+		{Func: "count", Column: "amount"},
+		{Func: "avg", Column: "amount"},
+		{Func: "min", Column: "date"},
+		{Func: "max", Column: "amount"},
+	}
+
+	got, err := GroupBy(records, []string{"cat"}, aggs, NumberComma)
+	require.NoError(t, err)
+	require.Equal(t, Records{
+		{"cat", "total", "count", "count_amount", "avg_amount", "min_date", "max_amount"},
+		{"food", "1002,75", "3", "2", "501,375", "2023-12-31", "1.000,50"},
+		{"rent", "800", "1", "1", "800", "01.01.2024", "800"},
+	}, got)
+
+	_, err = GroupBy(records, []string{"cat"}, []Aggregate{{Func: "sum", Column: "date"}}, NumberComma)
+	require.ErrorIs(t, err, ErrInvalidAggregate)
+	require.ErrorIs(t, err, ErrInvalidNumber)
+
+	_, err = GroupBy(records, []string{"nope"}, aggs, NumberComma)
+	require.ErrorIs(t, err, ErrColumnNotFound)
+}
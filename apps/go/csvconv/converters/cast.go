@@ -0,0 +1,193 @@
+package converters
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidCast = errors.New("invalid cast")
+
+// ErrorMode defines how converters handle values that cannot be converted.
+type ErrorMode string
+
+const (
+	ErrorFail  ErrorMode = ""      // fail on the first invalid value
+	ErrorSkip  ErrorMode = "skip"  // drop records with invalid values
+	ErrorBlank ErrorMode = "blank" // replace invalid values with empty fields
+	ErrorKeep  ErrorMode = "keep"  // keep invalid values as is
+)
+
+func ParseErrorMode(s string) (ErrorMode, error) {
+	switch mode := ErrorMode(strings.ToLower(strings.TrimSpace(s))); mode {
+	case "fail", "":
+		return ErrorFail, nil
+	case ErrorSkip, ErrorBlank, ErrorKeep:
+		return mode, nil
+	default:
+		return ErrorFail, fmt.Errorf("%w: invalid error mode %q, expected fail, skip, blank, or keep", ErrInvalidCast, s)
+	}
+}
+
+// Caster converts a single non-empty value.
+type Caster func(value string) (string, error)
+
+// ParseCast returns a [Caster] for the type and cast of a [Column], such as
+// "num" and "comma", or "date" and "dot:iso". Casts are target formats
+// or pairs of source and target formats. Numbers are read in dot format
+// and dates in any format by default. Without type, the type is inferred
+// from the cast, e.g., "comma" is a number format and "iso" a date format.
+// It returns nil if the column has neither type nor cast.
+func ParseCast(typ, cast string) (Caster, error) {
+	if typ == "" && cast == "" {
+		return nil, nil
+	}
+	if _, err := ParseValueType(cast); typ == "" && err == nil {
+		typ, cast = cast, "" // type without cast, e.g., "id:int"
+	}
+	valueType, err := castType(typ, cast)
+	if err != nil {
+		return nil, err
+	}
+
+	switch valueType {
+	case TypeFloat, TypeInt:
+		from, to, err := castNumberFormats(cast)
+		if err != nil {
+			return nil, err
+		}
+		if valueType == TypeInt {
+			return func(value string) (string, error) { return castInt(value, from) }, nil
+		}
+		return func(value string) (string, error) {
+			result, ok := ReformatNumber(value, from, to)
+			if !ok {
+				return "", fmt.Errorf("%w: %q", ErrInvalidNumber, value)
+			}
+			return result, nil
+		}, nil
+	case TypeDate:
+		from, to, err := castDateFormats(cast)
+		if err != nil {
+			return nil, err
+		}
+		return func(value string) (string, error) {
+			date, err := ParseDate(value, from)
+			if err != nil {
+				return "", err
+			}
+			return FormatDate(date, to), nil
+		}, nil
+	case TypeBool:
+		if cast != "" {
+			return nil, fmt.Errorf("%w: bool has no cast %q", ErrInvalidCast, cast)
+		}
+		return castBool, nil
+	default:
+		if cast != "" {
+			return nil, fmt.Errorf("%w: string has no cast %q", ErrInvalidCast, cast)
+		}
+		return func(value string) (string, error) { return value, nil }, nil
+	}
+}
+
+// castType returns the value type of a cast, inferring it from the cast if typ is empty.
+func castType(typ, cast string) (ValueType, error) {
+	if typ != "" {
+		valueType, err := ParseValueType(typ)
+		if err != nil {
+			return valueType, fmt.Errorf("%w: %w", ErrInvalidCast, err)
+		}
+		return valueType, nil
+	}
+	if _, _, err := castNumberFormats(cast); err == nil {
+		return TypeFloat, nil
+	}
+	if _, _, err := castDateFormats(cast); err == nil {
+		return TypeDate, nil
+	}
+	return TypeString, fmt.Errorf("%w: unknown cast %q", ErrInvalidCast, cast)
+}
+
+func castNumberFormats(cast string) (from, to NumberFormat, err error) {
+	from, to = NumberDot, NumberDot
+	opts := []string{}
+	if cast != "" {
+		opts = strings.Split(cast, ":")
+	}
+	switch len(opts) {
+	case 0:
+	case 1:
+		to, err = ParseNumberFormat(opts[0])
+	case 2:
+		if from, err = ParseNumberFormat(opts[0]); err == nil {
+			to, err = ParseNumberFormat(opts[1])
+		}
+	default:
+		err = ErrInvalidNumberFormat
+	}
+	if err != nil {
+		return from, to, fmt.Errorf("%w: %w %q", ErrInvalidCast, err, cast)
+	}
+	return from, to, nil
+}
+
+func castDateFormats(cast string) (from, to DateFormat, err error) {
+	from, to = DateAny, DateISO
+	switch opts := SplitDateFormats(cast); len(opts) {
+	case 0:
+	case 1:
+		to, err = ParseDateFormat(opts[0])
+	case 2:
+		if from, err = ParseDateFormat(opts[0]); err == nil {
+			to, err = ParseDateFormat(opts[1])
+		}
+	default:
+		err = ErrInvalidDateFormat
+	}
+	if err != nil {
+		return from, to, fmt.Errorf("%w: %w %q", ErrInvalidCast, err, cast)
+	}
+	return from, to, nil
+}
+
+func castInt(value string, from NumberFormat) (string, error) {
+	n, ok := ParseNumber(value, from)
+	if !ok || n != math.Trunc(n) || math.Abs(n) > 1<<53 {
+		return "", fmt.Errorf("%w: %q is not an integer", ErrInvalidNumber, value)
+	}
+	return strconv.FormatInt(int64(n), 10), nil
+}
+
+func castBool(value string) (string, error) {
+	switch v := strings.ToLower(value); {
+	case slices.Contains([]string{"true", "yes", "y", "1"}, v):
+		return "true", nil
+	case slices.Contains([]string{"false", "no", "n", "0"}, v):
+		return "false", nil
+	}
+	return "", fmt.Errorf("%w: %q is not a bool", ErrInvalidValue, value)
+}
+
+// castField applies the caster to a non-empty field using the error mode.
+// It returns a [*FieldError] for the fail and skip modes.
+func castField(value string, col Column, out int, cast Caster, onError ErrorMode) (string, error) {
+	if cast == nil || value == "" {
+		return value, nil
+	}
+	result, err := cast(value)
+	if err == nil {
+		return result, nil
+	}
+	switch onError {
+	case ErrorBlank:
+		return "", nil
+	case ErrorKeep:
+		return value, nil
+	default:
+		return "", &FieldError{Column: col, Output: out, Value: value, Err: err}
+	}
+}
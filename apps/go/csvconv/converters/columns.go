@@ -1,17 +1,22 @@
 package converters
 
 import (
+	"errors"
 	"fmt"
 	"log/slog"
+	"regexp"
+	"slices"
 	"strconv"
+	"sync"
 )
 
 type Column struct {
-	Name   string
-	Rename string
-	Type   string
-	Cast   string
-	Index  int
+	Name    string
+	Rename  string
+	Type    string
+	Cast    string
+	Index   int
+	Exclude bool // removes the column from the columns before it, e.g., in "* except id"
 }
 
 var zeroCol Column
@@ -39,6 +44,32 @@ func ColNames(cols []Column) []string {
 	return names
 }
 
+var ErrInvalidColumnPattern = errors.New("invalid column pattern")
+
+// IsColumnPattern reports whether a column name is a regular expression
+// that selects all matching columns, e.g., "/^amount_/".
+func IsColumnPattern(name string) bool {
+	return len(name) > 2 && name[0] == '/' && name[len(name)-1] == '/'
+}
+
+var columnPatterns sync.Map // string -> *regexp.Regexp
+
+func columnPattern(name string) (*regexp.Regexp, error) {
+	if re, ok := columnPatterns.Load(name); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(name[1 : len(name)-1])
+	if err != nil {
+		return nil, fmt.Errorf("%w %s: %w", ErrInvalidColumnPattern, name, err)
+	}
+	columnPatterns.Store(name, re)
+	return re, nil
+}
+
+// ColumnIndex resolves the columns by name, 1-based index, "*" for all columns,
+// or pattern, see [IsColumnPattern]. The renames of pattern columns are replacements
+// of the matches, e.g., "$1". Excluded columns remove the matching columns from
+// the columns before them, or from all columns if the first column is excluded.
 func ColumnIndex(header []string, columns ...Column) ([]Column, error) {
 	indices := []Column{}
 	notFound := []string{}
@@ -48,54 +79,247 @@ func ColumnIndex(header []string, columns ...Column) ([]Column, error) {
 		return nil, ErrColumnNotFound
 	}
 
-	for _, col := range columns {
-		// special case for selecting all columns
-		if col.Name == "*" {
-			indices = append(indices, Cols(header...)...)
+	for i, col := range columns {
+		if col.Exclude && i == 0 {
+			indices = Cols(header...)
+		}
+		matches, err := matchColumn(header, col)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			slog.Error("Column not found", "not_found", col, "found", indices, "select", columns)
+			notFound = append(notFound, col.Name)
 			continue
 		}
+		if col.Exclude {
+			indices = slices.DeleteFunc(indices, func(c Column) bool {
+				return slices.ContainsFunc(matches, func(m Column) bool { return m.Index == c.Index })
+			})
+			continue
+		}
+		indices = append(indices, matches...)
+	}
 
-		if col.Name == "" {
-			slog.Error("Empty column name")
-			return nil, ErrColumnNotFound
+	if len(notFound) > 0 {
+		slog.Error("Columns not found", "not_found", notFound, "found", indices, "select", columns)
+		return nil, ErrColumnNotFound
+	}
+	return indices, nil
+}
+
+// uniqueColumns removes repeated columns by index, keeping the first occurrence.
+func uniqueColumns(columns []Column) []Column {
+	seen := map[int]bool{}
+	return slices.DeleteFunc(columns, func(c Column) bool {
+		if seen[c.Index] {
+			return true
 		}
+		seen[c.Index] = true
+		return false
+	})
+}
+
+// matchColumn returns the columns of the header that match the column.
+func matchColumn(header []string, col Column) ([]Column, error) {
+	// special case for selecting all columns
+	if col.Name == "*" {
+		return Cols(header...), nil
+	}
+
+	if col.Name == "" {
+		slog.Error("Empty column name")
+		return nil, ErrColumnNotFound
+	}
 
-		// find the index for every selected column name
-		found := false
+	matches := []Column{}
+	if IsColumnPattern(col.Name) {
+		re, err := columnPattern(col.Name)
+		if err != nil {
+			return nil, err
+		}
 		for j, colName := range header {
-			logicalIndex := fmt.Sprint(j + 1) // 1-based index
-			if col.Name == colName || col.Name == logicalIndex {
-				col.Index = j
-				col.Name = colName
-				indices = append(indices, col)
-				found = true
+			if re.MatchString(colName) {
+				m := col
+				m.Index, m.Name = j, colName
+				if col.Rename != "" {
+					m.Rename = re.ReplaceAllString(colName, col.Rename)
+				}
+				matches = append(matches, m)
 			}
 		}
-		if found {
-			continue
+		return matches, nil
+	}
+
+	// find the index for every selected column name
+	for j, colName := range header {
+		logicalIndex := fmt.Sprint(j + 1) // 1-based index
+		if col.Name == colName || col.Name == logicalIndex {
+			col.Index = j
+			col.Name = colName
+			matches = append(matches, col)
 		}
+	}
+	if len(matches) > 0 {
+		return matches, nil
+	}
 
-		// allow over-indexing, when header row is shorter than data rows
-		idx, err := strconv.Atoi(col.Name)
-		if err == nil {
-			if idx < 1 {
-				slog.Error("Invalid column index", "index", idx)
-				return nil, ErrColumnNotFound
-			}
-			col.Index = idx - 1
-			indices = append(indices, col) // append index eventhough it might be out of range
-			continue
+	// allow over-indexing, when header row is shorter than data rows
+	idx, err := strconv.Atoi(col.Name)
+	if err == nil {
+		if idx < 1 {
+			slog.Error("Invalid column index", "index", idx)
+			return nil, ErrColumnNotFound
 		}
+		col.Index = idx - 1
+		matches = append(matches, col) // append index eventhough it might be out of range
+	}
+	return matches, nil
+}
 
-		if !found {
-			slog.Error("Column not found", "not_found", col, "found", indices, "select", columns)
-			notFound = append(notFound, col.Name)
+// DropRows returns a [RowConverter] that removes the given columns.
+func DropRows(columns []Column) RowConverter {
+	excluded := make([]Column, len(columns))
+	for i, col := range columns {
+		col.Exclude = true
+		excluded[i] = col
+	}
+	return SelectRows(excluded)
+}
+
+// RenameRows returns a [RowConverter] that renames the given columns to their Rename.
+// The matches of pattern columns are replaced in all column names, e.g., "/\s+/" with "_".
+func RenameRows(columns []Column) RowConverter {
+	return &MapRows{
+		HeaderFunc: func(header []string) ([]string, error) {
+			result := slices.Clone(header)
+			for _, col := range columns {
+				if IsColumnPattern(col.Name) {
+					re, err := columnPattern(col.Name)
+					if err != nil {
+						return nil, err
+					}
+					for i, name := range result {
+						result[i] = re.ReplaceAllString(name, col.Rename)
+					}
+					continue
+				}
+				cols, err := ColumnIndex(result, Col(col.Name))
+				if err != nil {
+					return nil, err
+				}
+				for _, c := range cols {
+					if c.Index < len(result) {
+						result[c.Index] = col.Rename
+					}
+				}
+			}
+			return result, nil
+		},
+		RowFunc: func(record []string) ([]string, error) { return record, nil },
+	}
+}
+
+// Placement defines where [MoveRows] moves the columns to.
+type Placement string
+
+const (
+	PlaceFirst  Placement = "first"
+	PlaceLast   Placement = "last"
+	PlaceBefore Placement = "before"
+	PlaceAfter  Placement = "after"
+)
+
+// MoveRows returns a [RowConverter] that moves the given columns to the first or last
+// position, or before or after the anchor column. Fields without header are kept at the end.
+func MoveRows(columns []Column, place Placement, anchor string) RowConverter {
+	var order []Column
+	return &MapRows{
+		HeaderFunc: func(header []string) ([]string, error) {
+			moved, err := ColumnIndex(header, columns...)
+			if err != nil {
+				return nil, err
+			}
+			isMoved := func(c Column) bool {
+				return slices.ContainsFunc(moved, func(m Column) bool { return m.Index == c.Index })
+			}
+			moved = uniqueColumns(moved)
+			rest := slices.DeleteFunc(Cols(header...), isMoved)
+
+			pos := 0
+			switch place {
+			case PlaceFirst:
+			case PlaceLast:
+				pos = len(rest)
+			case PlaceBefore, PlaceAfter:
+				cols, err := ColumnIndex(header, Col(anchor))
+				if err != nil {
+					return nil, err
+				}
+				if len(cols) != 1 || isMoved(cols[0]) {
+					return nil, fmt.Errorf("%w: cannot move columns %s %q", ErrInvalidColumnIndex, place, anchor)
+				}
+				pos = slices.IndexFunc(rest, func(c Column) bool { return c.Index == cols[0].Index })
+				if place == PlaceAfter {
+					pos++
+				}
+			default:
+				return nil, fmt.Errorf("%w: unknown placement %q", ErrInvalidColumnIndex, place)
+			}
+			order = slices.Concat(rest[:pos], moved, rest[pos:])
+			return selectFields(header, order), nil
+		},
+		RowFunc: func(record []string) ([]string, error) {
+			result := selectFields(record, order)
+			if len(record) > len(order) {
+				result = append(result, record[len(order):]...)
+			}
+			return result, nil
+		},
+	}
+}
+
+// headerRows implements the [RowConverter] for [HeaderRows].
+type headerRows struct {
+	names   []string
+	insert  bool
+	pending []string // input header that is emitted as the first record
+}
+
+// HeaderRows returns a [RowConverter] that replaces the names of the first columns
+// of the header. If insert is set, the input header is the first record, and the
+// columns without names are named by their 1-based index.
+func HeaderRows(names []string, insert bool) RowConverter {
+	return &headerRows{names: names, insert: insert}
+}
+
+func (h *headerRows) Header(header []string) ([]string, error) {
+	result := slices.Clone(h.names)
+	for i := len(h.names); i < len(header); i++ {
+		name := header[i]
+		if h.insert {
+			name = strconv.Itoa(i + 1)
 		}
+		result = append(result, name)
 	}
+	if h.insert {
+		h.pending = header
+	}
+	return result, nil
+}
 
-	if len(notFound) > 0 {
-		slog.Error("Columns not found", "not_found", notFound, "found", indices, "select", columns)
-		return nil, ErrColumnNotFound
+func (h *headerRows) Row(record []string, emit Emit) error {
+	if err := h.Flush(emit); err != nil {
+		return err
 	}
-	return indices, nil
+	return emit(record)
+}
+
+func (h *headerRows) Flush(emit Emit) error {
+	if h.pending == nil {
+		return nil
+	}
+	pending := h.pending
+	h.pending = nil
+	return emit(pending)
 }
@@ -0,0 +1,113 @@
+package converters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnIndexPatterns(t *testing.T) {
+	header := []string{"id", "amount_net", "amount_tax", "name"}
+	tests := []struct {
+		name    string
+		columns []Column
+		want    []string
+		renames []string
+		err     error
+	}{
+		{"pattern", Cols("/^amount_/"), []string{"amount_net", "amount_tax"}, []string{"", ""}, nil},
+		{"pattern rename", []Column{{Name: "/^amount_(.*)/", Rename: "$1"}}, []string{"amount_net", "amount_tax"}, []string{"net", "tax"}, nil},
+		{"except", []Column{Col("*"), {Name: "id", Exclude: true}}, []string{"amount_net", "amount_tax", "name"}, nil, nil},
+		{"except pattern", []Column{Col("name"), Col("/amount/"), {Name: "/tax/", Exclude: true}}, []string{"name", "amount_net"}, nil, nil},
+		{"exclude first", []Column{{Name: "/^amount_/", Exclude: true}}, []string{"id", "name"}, nil, nil},
+		{"no match", Cols("/^total/"), nil, nil, ErrColumnNotFound},
+		{"invalid pattern", Cols("/(/"), nil, nil, ErrInvalidColumnPattern},
+		{"exclude unknown", []Column{Col("*"), {Name: "total", Exclude: true}}, nil, nil, ErrColumnNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cols, err := ColumnIndex(header, tt.columns...)
+			if tt.err != nil {
+				require.ErrorIs(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, ColNames(cols))
+			for i, rename := range tt.renames {
+				require.Equal(t, rename, cols[i].Rename)
+			}
+		})
+	}
+}
+
+func TestColumnRows(t *testing.T) {
+	records := Records{
+		{"id", "First Name", "Last  Name", "total"},
+		{"1", "Ada", "Lovelace", "7", "extra"},
+	}
+	tests := []struct {
+		name  string
+		stage RowConverter
+		want  Records
+	}{
+		{
+			"drop",
+			DropRows(Cols("id", "/Name$/")),
+			Records{{"total"}, {"7"}},
+		},
+		{
+			"rename",
+			RenameRows([]Column{{Name: `/\s+/`, Rename: "_"}, {Name: "id", Rename: "ID"}}),
+			Records{{"ID", "First_Name", "Last_Name", "total"}, records[1]},
+		},
+		{
+			"move first",
+			MoveRows(Cols("total"), PlaceFirst, ""),
+			Records{{"total", "id", "First Name", "Last  Name"}, {"7", "1", "Ada", "Lovelace", "extra"}},
+		},
+		{
+			"move last",
+			MoveRows(Cols("id", "/First/"), PlaceLast, ""),
+			Records{{"Last  Name", "total", "id", "First Name"}, {"Lovelace", "7", "1", "Ada", "extra"}},
+		},
+		{
+			"move repeated",
+			MoveRows(Cols("id", "total", "id"), PlaceLast, ""),
+			Records{{"First Name", "Last  Name", "id", "total"}, {"Ada", "Lovelace", "1", "7", "extra"}},
+		},
+		{
+			"move before",
+			MoveRows(Cols("total"), PlaceBefore, "First Name"),
+			Records{{"id", "total", "First Name", "Last  Name"}, {"1", "7", "Ada", "Lovelace", "extra"}},
+		},
+		{
+			"move after",
+			MoveRows(Cols("id"), PlaceAfter, "3"),
+			Records{{"First Name", "Last  Name", "id", "total"}, {"Ada", "Lovelace", "1", "7", "extra"}},
+		},
+		{
+			"header",
+			HeaderRows([]string{"a", "b"}, false),
+			Records{{"a", "b", "Last  Name", "total"}, records[1]},
+		},
+		{
+			"header insert",
+			HeaderRows([]string{"a", "b"}, true),
+			Records{{"a", "b", "3", "4"}, records[0], records[1]},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyRows(records, tt.stage)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := ApplyRows(records, MoveRows(Cols("id"), PlaceAfter, "id"))
+	require.ErrorIs(t, err, ErrInvalidColumnIndex)
+
+	got, err := ApplyRows(records[:1], HeaderRows([]string{"a"}, true))
+	require.NoError(t, err)
+	require.Equal(t, Records{{"a", "2", "3", "4"}, records[0]}, got)
+}
@@ -0,0 +1,121 @@
+package converters
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var ErrHeaderMismatch = errors.New("header mismatch")
+
+// columnKeys returns the column names of the header, where repeated names are numbered
+// by their occurrence, e.g., ["a", "b", "a#2"], so that they can be matched across headers.
+func columnKeys(header []string) []string {
+	keys := make([]string, len(header))
+	seen := map[string]int{}
+	for i, name := range header {
+		seen[name]++
+		keys[i] = name
+		if n := seen[name]; n > 1 {
+			keys[i] = name + "#" + strconv.Itoa(n)
+		}
+	}
+	return keys
+}
+
+// UnifyHeaders returns the header of the concatenation of records with the given headers.
+// Columns are matched by name, so that their order may differ, and are ordered by their
+// first appearance. Unless union is set, all headers must have the same columns.
+func UnifyHeaders(union bool, headers ...[]string) ([]string, error) {
+	if len(headers) == 0 {
+		return nil, nil
+	}
+	unified := slices.Clone(headers[0])
+	keys := columnKeys(unified)
+	for _, header := range headers[1:] {
+		other := columnKeys(header)
+		var missing, extra []string
+		for i, key := range other {
+			if !slices.Contains(keys, key) {
+				extra = append(extra, header[i])
+			}
+		}
+		for i, key := range keys {
+			if !slices.Contains(other, key) {
+				missing = append(missing, unified[i])
+			}
+		}
+		if !union && (len(missing) > 0 || len(extra) > 0) {
+			diff := []string{}
+			if len(missing) > 0 {
+				diff = append(diff, fmt.Sprintf("missing columns %q", missing))
+			}
+			if len(extra) > 0 {
+				diff = append(diff, fmt.Sprintf("extra columns %q", extra))
+			}
+			return nil, fmt.Errorf("%w: %s", ErrHeaderMismatch, strings.Join(diff, ", "))
+		}
+		for i, key := range other {
+			if !slices.Contains(keys, key) {
+				keys = append(keys, key)
+				unified = append(unified, header[i])
+			}
+		}
+	}
+	return unified, nil
+}
+
+// AlignFields returns the index of each column of the target header in the header,
+// or -1 if the header has no such column, see [AlignRecord].
+func AlignFields(header, target []string) []int {
+	keys := columnKeys(header)
+	indices := make([]int, len(target))
+	for i, key := range columnKeys(target) {
+		indices[i] = slices.Index(keys, key)
+	}
+	return indices
+}
+
+// AlignRecord returns the fields of the record at the indices, see [AlignFields].
+// Missing fields are empty.
+func AlignRecord(record []string, indices []int) []string {
+	aligned := make([]string, len(indices))
+	for i, idx := range indices {
+		if idx >= 0 {
+			aligned[i] = Field(record, idx)
+		}
+	}
+	return aligned
+}
+
+// ConcatRecords concatenates the records and aligns their fields by column name,
+// see [UnifyHeaders]. Records without a header are skipped.
+func ConcatRecords(union bool, records ...Records) (Records, error) {
+	headers := [][]string{}
+	for _, r := range records {
+		if len(r) > 0 {
+			headers = append(headers, r.HeaderRow())
+		}
+	}
+	header, err := UnifyHeaders(union, headers...)
+	if err != nil || header == nil {
+		return nil, err
+	}
+	result := Records{header}
+	for _, r := range records {
+		if len(r) == 0 {
+			continue
+		}
+		if slices.Equal(r.HeaderRow(), header) {
+			result = append(result, r.Data()...)
+			continue
+		}
+		indices := AlignFields(r.HeaderRow(), header)
+		for _, record := range r.Data() {
+			result = append(result, AlignRecord(record, indices))
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,30 @@
+package converters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcatRecords(t *testing.T) {
+	jan := Records{{"date", "amount"}, {"01.01.", "10"}}
+	feb := Records{{"amount", "date"}, {"20", "01.02."}}
+	mar := Records{{"date", "amount", "memo"}, {"01.03.", "30", "rent"}}
+
+	got, err := ConcatRecords(false, jan, feb, Records{})
+	require.NoError(t, err)
+	require.Equal(t, Records{{"date", "amount"}, {"01.01.", "10"}, {"01.02.", "20"}}, got)
+
+	_, err = ConcatRecords(false, jan, mar)
+	require.ErrorIs(t, err, ErrHeaderMismatch)
+	require.ErrorContains(t, err, `extra columns ["memo"]`)
+
+	got, err = ConcatRecords(true, mar, feb)
+	require.NoError(t, err)
+	require.Equal(t, Records{{"date", "amount", "memo"}, {"01.03.", "30", "rent"}, {"01.02.", "20", ""}}, got)
+
+	header, err := UnifyHeaders(true, []string{"a", "a"}, []string{"a", "b", "a", "a"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "a", "b", "a"}, header, "repeated columns are matched by occurrence")
+	require.Equal(t, []int{1, -1, 0}, AlignFields([]string{"b", "a"}, []string{"a", "c", "b"}))
+}
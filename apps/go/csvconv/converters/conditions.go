@@ -0,0 +1,160 @@
+package converters
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"slices"
+)
+
+// Predicate reports whether a record matches a condition.
+type Predicate func(record []string) (bool, error)
+
+// Condition is a boolean expression over the fields of a record.
+// Conditions are stateless and can be compiled for multiple tables.
+type Condition interface {
+	// Compile resolves the columns of the condition using the header
+	// and returns a predicate that evaluates the condition for a record.
+	Compile(header []string) (Predicate, error)
+}
+
+// And matches if all conditions match.
+type And []Condition
+
+// Or matches if any condition matches.
+type Or []Condition
+
+// Not matches if the condition does not match.
+type Not struct {
+	Cond Condition
+}
+
+// Compare matches if the value of the column compares to the value using the operator.
+type Compare struct {
+	Column string
+	Op     string
+	Value  string
+	Values []string     // list of values for the "in" operator
+	Number NumberFormat // format of numbers in fields and values, dot if empty
+}
+
+// SetNumberFormat sets the number format of all comparisons of the condition.
+func SetNumberFormat(cond Condition, format NumberFormat) {
+	switch c := cond.(type) {
+	case And:
+		for _, sub := range c {
+			SetNumberFormat(sub, format)
+		}
+	case Or:
+		for _, sub := range c {
+			SetNumberFormat(sub, format)
+		}
+	case *Not:
+		SetNumberFormat(c.Cond, format)
+	case *Compare:
+		c.Number = format
+	}
+}
+
+func compileAll(header []string, conds []Condition) ([]Predicate, error) {
+	preds := make([]Predicate, len(conds))
+	for i, cond := range conds {
+		pred, err := cond.Compile(header)
+		if err != nil {
+			return nil, err
+		}
+		preds[i] = pred
+	}
+	return preds, nil
+}
+
+func (c And) Compile(header []string) (Predicate, error) {
+	preds, err := compileAll(header, c)
+	if err != nil {
+		return nil, err
+	}
+	return func(record []string) (bool, error) {
+		for _, pred := range preds {
+			ok, err := pred(record)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+	}, nil
+}
+
+func (c Or) Compile(header []string) (Predicate, error) {
+	preds, err := compileAll(header, c)
+	if err != nil {
+		return nil, err
+	}
+	return func(record []string) (bool, error) {
+		for _, pred := range preds {
+			ok, err := pred(record)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+	}, nil
+}
+
+func (c *Not) Compile(header []string) (Predicate, error) {
+	pred, err := c.Cond.Compile(header)
+	if err != nil {
+		return nil, err
+	}
+	return func(record []string) (bool, error) {
+		ok, err := pred(record)
+		return !ok, err
+	}, nil
+}
+
+func (c *Compare) Compile(header []string) (Predicate, error) {
+	cols, err := ColumnIndex(header, Col(c.Column))
+	if err != nil {
+		return nil, err
+	}
+	if len(cols) != 1 {
+		slog.Error("invalid column indices for filter", "indices", cols, "column", c.Column)
+		return nil, ErrInvalidColumnIndex
+	}
+	idx := cols[0].Index
+
+	if !IsOperator(c.Op) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidOperator, c.Op)
+	}
+
+	switch c.Op {
+	case "in":
+		values := c.Values
+		if values == nil {
+			values = Trims(Unquotes(TrimSplit(Unwrap(Trim(c.Value), "(", ")"), ",")))
+		}
+		return func(record []string) (bool, error) {
+			return slices.Contains(values, Unquote(Field(record, idx))), nil
+		}, nil
+	case "~", "regexp", "!~", "notregexp", "like":
+		pattern := Unquote(c.Value)
+		if c.Op == "like" {
+			pattern = likePattern(pattern)
+		}
+		exp, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid pattern %q: %w", ErrInvalidOperator, c.Value, err)
+		}
+		want := c.Op != "!~" && c.Op != "notregexp"
+		return func(record []string) (bool, error) {
+			return exp.MatchString(Field(record, idx)) == want, nil
+		}, nil
+	case "is":
+		if _, ok := compareIs("", c.Value); !ok {
+			return nil, fmt.Errorf("%w: unknown value %q for 'is'", ErrInvalidOperator, c.Value)
+		}
+	}
+
+	return func(record []string) (bool, error) {
+		return compare(Field(record, idx), c.Value, c.Op, c.Number), nil
+	}, nil
+}
@@ -2,125 +2,378 @@ package converters
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
-	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// DateFormat is a named date format, a Go layout such as "02.01.2006 15:04",
+// or [DateAny]. A zone suffix such as "@Europe/Berlin" sets the time zone,
+// see [ParseDateFormat].
 type DateFormat string
 
 const (
-	DateAny    DateFormat = ""
-	DateDot    DateFormat = "dd.mm.yyyy"
-	DateDot2   DateFormat = "dd.mm.yy"
-	DateSlash  DateFormat = "dd/mm/yyyy"
-	DateSlash2 DateFormat = "dd/mm/yy"
-	DateISO    DateFormat = "yyyy-mm-dd"
+	DateAny       DateFormat = ""
+	DateDot       DateFormat = "dd.mm.yyyy"
+	DateDot2      DateFormat = "dd.mm.yy"
+	DateSlash     DateFormat = "dd/mm/yyyy"
+	DateSlash2    DateFormat = "dd/mm/yy"
+	DateISO       DateFormat = "yyyy-mm-dd"
+	DateUS        DateFormat = "mm/dd/yyyy"
+	DateUS2       DateFormat = "mm/dd/yy"
+	DateTime      DateFormat = "datetime" // yyyy-mm-dd hh:mm:ss
+	DateRFC3339   DateFormat = "rfc3339"  // ISO 8601 date and time with zone
+	DateUnix      DateFormat = "unix"     // seconds since 1970-01-01 UTC
+	DateUnixMilli DateFormat = "unix-ms"  // milliseconds since 1970-01-01 UTC
 )
 
-var ErrInvalidDateFormat = errors.New("invalid date format")
+var (
+	ErrInvalidDateFormat = errors.New("invalid date format")
+	ErrAmbiguousDate     = errors.New("ambiguous date")
+)
+
+// dateNames maps names and aliases to date formats.
+var dateNames = map[string]DateFormat{
+	"any": DateAny, "auto": DateAny,
+	"dd.mm.yyyy": DateDot, "dot": DateDot, ".": DateDot,
+	"dd.mm.yy": DateDot2, "dot2": DateDot2,
+	"dd/mm/yyyy": DateSlash, "slash": DateSlash, "/": DateSlash,
+	"dd/mm/yy": DateSlash2, "slash2": DateSlash2,
+	"yyyy-mm-dd": DateISO, "iso": DateISO, "-": DateISO,
+	"mm/dd/yyyy": DateUS, "us": DateUS,
+	"mm/dd/yy": DateUS2, "us2": DateUS2,
+	"datetime": DateTime,
+	"rfc3339":  DateRFC3339, "iso-datetime": DateRFC3339, "iso8601": DateRFC3339,
+	"unix": DateUnix, "epoch": DateUnix,
+	"unix-ms": DateUnixMilli, "unixms": DateUnixMilli, "epoch-ms": DateUnixMilli,
+}
 
-func MustParseDateFormat(s string) DateFormat { return MustParse(s, ParseDateFormat) }
+// dateLayouts maps the named formats to Go layouts.
+var dateLayouts = map[DateFormat]string{
+	DateDot:     "02.01.2006",
+	DateDot2:    "02.01.06",
+	DateSlash:   "02/01/2006",
+	DateSlash2:  "02/01/06",
+	DateISO:     "2006-01-02",
+	DateUS:      "01/02/2006",
+	DateUS2:     "01/02/06",
+	DateTime:    "2006-01-02 15:04:05",
+	DateRFC3339: time.RFC3339,
+}
+
+// anyLayouts are tried by [DateAny] in order.
+var anyLayouts = []string{
+	"02.01.2006", "02.01.06", "2006-01-02",
+	"02.01.2006 15:04:05", "02.01.2006 15:04",
+	time.RFC3339, "2006-01-02T15:04:05Z0700", "2006-01-02 15:04:05Z07:00",
+	"2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02 15:04",
+}
+
+// slashLayouts are pairs of day-first and month-first layouts tried by [DateAny].
+// A date that is valid in both layouts, such as 01/02/2024, is ambiguous.
+var slashLayouts = [][2]string{
+	{"02/01/2006", "01/02/2006"},
+	{"02/01/06", "01/02/06"},
+}
+
+// ParseDateFormat parses a named format, a strftime format such as "%d.%m.%Y %H:%M",
+// or a Go layout such as "02.01.2006 15:04". A suffix such as "@Europe/Berlin"
+// sets the time zone for parsing times without zone, and for formatting.
 func ParseDateFormat(s string) (DateFormat, error) {
-	s = strings.TrimSpace(strings.ToLower(s))
-	switch s {
-	case "dd.mm.yyyy", "dot", ".":
-		return DateDot, nil
-	case "dd.mm.yy", "dot2":
-		return DateDot2, nil
-	case "dd/mm/yyyy", "slash", "/":
-		return DateSlash, nil
-	case "dd/mm/yy", "slash2":
-		return DateSlash2, nil
-	case "yyyy-mm-dd", "iso", "-":
-		return DateISO, nil
+	s = strings.TrimSpace(s)
+	s, zone, hasZone := cutZone(s)
+	format, ok := dateNames[strings.ToLower(s)]
+	switch {
+	case ok:
+	case strings.Contains(s, "%"):
+		layout, err := strftimeLayout(s)
+		if err != nil {
+			return DateAny, err
+		}
+		format = DateFormat(layout)
+	case isLayout(s):
+		format = DateFormat(s)
 	default:
-		return DateAny, ErrInvalidDateFormat
+		return DateAny, fmt.Errorf("%w: %q", ErrInvalidDateFormat, s)
 	}
+	if hasZone {
+		if _, err := loadZone(zone); err != nil {
+			return DateAny, fmt.Errorf("%w: %w", ErrInvalidDateFormat, err)
+		}
+		format += DateFormat("@" + zone)
+	}
+	return format, nil
 }
 
-func ConvertDate(records Records, column string, from, to DateFormat) (Records, error) {
-	idx, err := records.Columns(column)
-	if err != nil {
-		return nil, err
-	}
-	if len(idx) != 1 {
-		return nil, ErrInvalidColumnIndex
+func cutZone(s string) (format, zone string, ok bool) {
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		return s[:i], s[i+1:], true
 	}
+	return s, "", false
+}
+
+// isLayout reports whether s contains elements of a Go layout.
+func isLayout(s string) bool {
+	return time.Date(1999, 12, 31, 23, 59, 58, 0, time.UTC).Format(s) != s
+}
+
+// strftimeDirectives maps strftime directives to Go layout elements.
+// Fractional seconds "%f" must follow a dot, as in "%S.%f".
+var strftimeDirectives = map[string]string{
+	"Y": "2006", "y": "06", "m": "01", "-m": "1", "d": "02", "-d": "2", "e": "_2",
+	"H": "15", "I": "03", "-I": "3", "M": "04", "S": "05", "f": "000000", "p": "PM",
+	"z": "-0700", ":z": "-07:00", "Z": "MST",
+	"b": "Jan", "h": "Jan", "B": "January", "a": "Mon", "A": "Monday", "j": "002",
+	"F": "2006-01-02", "T": "15:04:05", "D": "01/02/06", "%": "%",
+}
 
-	result := Records{}
-	for _, record := range records.Data() {
-		if len(record) <= idx[0].Index {
+// strftimeLayout converts a strftime format to a Go layout.
+func strftimeLayout(format string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			sb.WriteByte(format[i])
 			continue
 		}
-		val := record[idx[0].Index]
-		date, err := ParseDate(val, from)
-		if err != nil {
-			return nil, err
+		rest := format[i+1:]
+		if elem, ok := strftimeDirectives[rest[:min(2, len(rest))]]; ok && len(rest) >= 2 {
+			sb.WriteString(elem)
+			i += 2
+			continue
 		}
-		newRecord := slices.Clone(record)
-		newRecord[idx[0].Index] = FormatDate(date, to)
-		result = append(result, newRecord)
+		if elem, ok := strftimeDirectives[rest[:min(1, len(rest))]]; ok && len(rest) >= 1 {
+			sb.WriteString(elem)
+			i++
+			continue
+		}
+		return "", fmt.Errorf("%w: unknown directive in %q", ErrInvalidDateFormat, format)
+	}
+	return sb.String(), nil
+}
+
+// SplitDateFormats splits options such as "%d.%m.%Y %H:%M:iso" into date formats.
+// Colons between time elements of a layout, as in "%H:%M" or "15:04", do not split.
+func SplitDateFormats(s string) []string {
+	parts := []string{}
+	start := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != ':' || isTimeColon(s, i) {
+			continue
+		}
+		parts = append(parts, strings.TrimSpace(s[start:i]))
+		start = i + 1
+	}
+	if s = strings.TrimSpace(s[start:]); s != "" || len(parts) > 0 {
+		parts = append(parts, s)
+	}
+	return parts
+}
+
+func isTimeColon(s string, i int) bool {
+	isDigit := func(c byte) bool { return c >= '0' && c <= '9' }
+	if i == 0 || i+1 >= len(s) {
+		return false
+	}
+	prev, next := s[i-1], s[i+1]
+	switch {
+	case prev == '%': // %:z
+		return true
+	case isDigit(prev): // 15:04
+		return isDigit(next)
+	case i >= 2 && s[i-2] == '%': // %H:%M
+		return next == '%' || isDigit(next)
+	}
+	return false
+}
+
+// zones caches loaded time zones.
+var zones sync.Map
+
+func loadZone(name string) (*time.Location, error) {
+	if loc, ok := zones.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
 	}
+	zones.Store(name, loc)
+	return loc, nil
+}
 
-	return records.Header().Concat(result), nil
+// split returns the format without zone and the zone, or nil if no zone is set.
+func (f DateFormat) split() (DateFormat, *time.Location) {
+	format, zone, ok := cutZone(string(f))
+	if !ok {
+		return DateFormat(format), nil
+	}
+	loc, err := loadZone(zone)
+	if err != nil {
+		slog.Warn("invalid time zone", "zone", zone, "error", err)
+		return DateFormat(format), nil
+	}
+	return DateFormat(format), loc
+}
+
+// layout returns the Go layout of a named format or layout.
+func (f DateFormat) layout() string {
+	if layout, ok := dateLayouts[f]; ok {
+		return layout
+	}
+	return string(f)
+}
+
+func ConvertDate(records Records, column string, from, to DateFormat) (Records, error) {
+	if len(records) == 0 {
+		return nil, ErrColumnNotFound
+	}
+	return ApplyRows(records, DateRows(column, from, to))
 }
 
 func ConvertDates(records Records, from, to DateFormat) (Records, error) {
-	result := Records{}
-	for _, record := range records {
-		newRecord := make([]string, len(record))
-		for j, field := range record {
-			slog.Debug("converting date", "date", field, "from", from, "to", to)
-			if date, err := ParseDate(field, from); err == nil {
-				newRecord[j] = FormatDate(date, to)
-			} else {
-				newRecord[j] = field
+	return ApplyRows(records, DatesRows(from, to))
+}
+
+// DateRows returns a [RowConverter] that converts the dates of a column.
+// Records without a value for the column are dropped.
+func DateRows(column string, from, to DateFormat) RowConverter {
+	var col Column
+	return &MapRows{
+		HeaderFunc: func(header []string) ([]string, error) {
+			cols, err := ColumnIndex(header, Col(column))
+			if err != nil {
+				return nil, err
+			}
+			if len(cols) != 1 {
+				return nil, ErrInvalidColumnIndex
+			}
+			col = cols[0]
+			return header, nil
+		},
+		RowFunc: func(record []string) ([]string, error) {
+			if len(record) <= col.Index {
+				return nil, nil
 			}
+			if record[col.Index] == "" {
+				return record, nil // empty field, keep the row as is
+			}
+			date, err := ParseDate(record[col.Index], from)
+			if err != nil {
+				return nil, &FieldError{Column: col, Output: col.Index, Value: record[col.Index], Err: err}
+			}
+			return replaceField(record, col.Index, FormatDate(date, to)), nil
+		},
+	}
+}
+
+// DatesRows returns a [RowConverter] that converts all dates, including the header.
+// Fields that are not dates in the source format are kept as is.
+func DatesRows(from, to DateFormat) RowConverter {
+	return &MapRows{
+		HeaderFunc: func(header []string) ([]string, error) {
+			return convertDates(header, from, to), nil
+		},
+		RowFunc: func(record []string) ([]string, error) {
+			return convertDates(record, from, to), nil
+		},
+	}
+}
+
+func convertDates(record []string, from, to DateFormat) []string {
+	newRecord := make([]string, len(record))
+	for j, field := range record {
+		slog.Debug("converting date", "date", field, "from", from, "to", to)
+		date, err := ParseDate(field, from)
+		switch {
+		case err == nil:
+			newRecord[j] = FormatDate(date, to)
+		case errors.Is(err, ErrAmbiguousDate):
+			slog.Warn("Keeping ambiguous date", "error", err)
+			newRecord[j] = field
+		default:
+			newRecord[j] = field
 		}
-		result = append(result, newRecord)
 	}
-	return result, nil
+	return newRecord
 }
 
+// ParseDate parses a date in the given format. Times without a zone are in UTC,
+// or in the zone of the format. [DateAny] tries all known day-first, ISO,
+// and month-first layouts, and returns [ErrAmbiguousDate] for dates such as 01/02/2024.
 func ParseDate(s string, format DateFormat) (time.Time, error) {
+	format, loc := format.split()
+	if loc == nil {
+		loc = time.UTC
+	}
 	switch format {
 	case DateAny:
-		for _, f := range []DateFormat{DateDot, DateSlash, DateISO, DateDot2, DateSlash2} {
-			if t, err := ParseDate(s, f); err == nil {
-				return t, nil
-			}
+		return parseAnyDate(s, loc)
+	case DateUnix, DateUnixMilli:
+		return parseEpoch(s, format, loc)
+	}
+	return time.ParseInLocation(format.layout(), s, loc)
+}
+
+func parseAnyDate(s string, loc *time.Location) (time.Time, error) {
+	for _, layout := range anyLayouts {
+		if t, err := time.ParseInLocation(layout, s, loc); err == nil {
+			return t, nil
+		}
+	}
+	for _, pair := range slashLayouts {
+		dayFirst, dayErr := time.ParseInLocation(pair[0], s, loc)
+		monthFirst, monthErr := time.ParseInLocation(pair[1], s, loc)
+		switch {
+		case dayErr == nil && monthErr == nil && !dayFirst.Equal(monthFirst):
+			return time.Time{}, fmt.Errorf("%w: %q is %s or %s", ErrAmbiguousDate, s,
+				dayFirst.Format(time.DateOnly), monthFirst.Format(time.DateOnly))
+		case dayErr == nil:
+			return dayFirst, nil
+		case monthErr == nil:
+			return monthFirst, nil
 		}
-		return time.Time{}, ErrInvalidDateFormat
-	case DateDot:
-		return time.Parse("02.01.2006", s)
-	case DateDot2:
-		return time.Parse("02.01.06", s)
-	case DateSlash:
-		return time.Parse("02/01/2006", s)
-	case DateSlash2:
-		return time.Parse("02/01/06", s)
-	case DateISO:
-		return time.Parse("2006-01-02", s)
-	default:
-		return time.Time{}, ErrInvalidDateFormat
 	}
+	return time.Time{}, ErrInvalidDateFormat
 }
 
+// parseEpoch parses unix seconds, with optional fraction, or unix milliseconds.
+func parseEpoch(s string, format DateFormat, loc *time.Location) (time.Time, error) {
+	if format == DateUnixMilli {
+		ms, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%w: %q is not unix milliseconds", ErrInvalidDateFormat, s)
+		}
+		return time.UnixMilli(ms).In(loc), nil
+	}
+	sec, frac, _ := strings.Cut(s, ".")
+	n, err := strconv.ParseInt(sec, 10, 64)
+	if err != nil || strings.Trim(frac, "0123456789") != "" {
+		return time.Time{}, fmt.Errorf("%w: %q is not unix seconds", ErrInvalidDateFormat, s)
+	}
+	nsec, _ := strconv.ParseInt((frac + "000000000")[:9], 10, 64)
+	if strings.HasPrefix(sec, "-") {
+		nsec = -nsec
+	}
+	return time.Unix(n, nsec).In(loc), nil
+}
+
+// FormatDate formats a date in the given format.
+// Dates are converted to the zone of the format, if set.
 func FormatDate(d time.Time, format DateFormat) string {
+	format, loc := format.split()
+	if loc != nil {
+		d = d.In(loc)
+	}
 	switch format {
-	case DateDot:
-		return d.Format("02.01.2006")
-	case DateDot2:
-		return d.Format("02.01.06")
-	case DateSlash:
-		return d.Format("02/01/2006")
-	case DateSlash2:
-		return d.Format("02/01/06")
-	case DateISO:
-		return d.Format("2006-01-02")
-	default:
-		return d.Format("2006-01-02")
+	case DateAny:
+		return d.Format(dateLayouts[DateISO])
+	case DateUnix:
+		return strconv.FormatInt(d.Unix(), 10)
+	case DateUnixMilli:
+		return strconv.FormatInt(d.UnixMilli(), 10)
 	}
+	return d.Format(format.layout())
 }
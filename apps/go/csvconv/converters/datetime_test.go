@@ -0,0 +1,108 @@
+package converters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDateFormat(t *testing.T) {
+	tests := []struct {
+		format string
+		want   DateFormat
+	}{
+		{"dot", DateDot},
+		{"ISO", DateISO},
+		{"us", DateUS},
+		{"epoch-ms", DateUnixMilli},
+		{"%d.%m.%Y %H:%M", "02.01.2006 15:04"},
+		{"%Y-%m-%dT%H:%M:%S.%f%:z", "2006-01-02T15:04:05.000000-07:00"},
+		{"%-d. %B %Y", "2. January 2006"},
+		{"02.01.2006 15:04", "02.01.2006 15:04"},
+		{"iso@Europe/Berlin", "yyyy-mm-dd@Europe/Berlin"},
+		{"%H:%M@UTC", "15:04@UTC"},
+	}
+	for _, tt := range tests {
+		got, err := ParseDateFormat(tt.format)
+		require.NoError(t, err, tt.format)
+		require.Equal(t, tt.want, got, tt.format)
+	}
+
+	for _, format := range []string{"", "dd.mm.yyy", "%Q", "iso@Mars/Olympus"} {
+		_, err := ParseDateFormat(format)
+		require.ErrorIs(t, err, ErrInvalidDateFormat, format)
+	}
+}
+
+func TestConvertDate(t *testing.T) {
+	tests := []struct {
+		value    string
+		from, to string
+		want     string
+	}{
+		{"24.12.2023", "dot", "iso", "2023-12-24"},
+		{"12/24/2023", "us", "dot", "24.12.2023"},
+		{"24.12.2023 18:30", "%d.%m.%Y %H:%M", "rfc3339", "2023-12-24T18:30:00Z"},
+		{"24.12.2023 18:30", "%d.%m.%Y %H:%M@Europe/Berlin", "rfc3339@UTC", "2023-12-24T17:30:00Z"},
+		{"2023-07-01T12:00:00+02:00", "rfc3339", "datetime@UTC", "2023-07-01 10:00:00"},
+		{"2023-07-01T12:00:00Z", "any", "%d.%m.%Y %H:%M@America/New_York", "01.07.2023 08:00"},
+		{"1700000000", "unix", "rfc3339", "2023-11-14T22:13:20Z"},
+		{"1700000000.5", "unix", "unix-ms", "1700000000500"},
+		{"1700000000123", "unix-ms", "%H:%M:%S.%f", "22:13:20.123000"},
+		{"2023-11-14", "iso@Europe/Berlin", "unix", "1699916400"},
+	}
+	for _, tt := range tests {
+		from, err := ParseDateFormat(tt.from)
+		require.NoError(t, err)
+		to, err := ParseDateFormat(tt.to)
+		require.NoError(t, err)
+		date, err := ParseDate(tt.value, from)
+		require.NoError(t, err, tt.value)
+		require.Equal(t, tt.want, FormatDate(date, to), tt.value)
+	}
+}
+
+func TestParseAnyDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"24.12.2023", "2023-12-24"},
+		{"24.12.23", "2023-12-24"},
+		{"2023-12-24", "2023-12-24"},
+		{"24/12/2023", "2023-12-24"},
+		{"12/24/2023", "2023-12-24"},
+		{"02/02/2024", "2024-02-02"},
+		{"2023-12-24T18:30:00+01:00", "2023-12-24"},
+		{"2023-12-24 18:30", "2023-12-24"},
+	}
+	for _, tt := range tests {
+		date, err := ParseDate(tt.value, DateAny)
+		require.NoError(t, err, tt.value)
+		require.Equal(t, tt.want, FormatDate(date, DateAny), tt.value)
+	}
+
+	_, err := ParseDate("01/02/2024", DateAny)
+	require.ErrorIs(t, err, ErrAmbiguousDate)
+	require.ErrorContains(t, err, "2024-02-01 or 2024-01-02")
+
+	_, err = ParseDate("yesterday", DateAny)
+	require.ErrorIs(t, err, ErrInvalidDateFormat)
+}
+
+func TestSplitDateFormats(t *testing.T) {
+	tests := []struct {
+		opts string
+		want []string
+	}{
+		{"", []string{}},
+		{"dot:iso", []string{"dot", "iso"}},
+		{"%d.%m.%Y %H:%M:iso", []string{"%d.%m.%Y %H:%M", "iso"}},
+		{"02.01.2006 15:04:05:rfc3339@UTC", []string{"02.01.2006 15:04:05", "rfc3339@UTC"}},
+		{"iso:02.01.2006", []string{"iso", "02.01.2006"}},
+		{"rfc3339:%H:%M%:z", []string{"rfc3339", "%H:%M%:z"}},
+	}
+	for _, tt := range tests {
+		require.Equal(t, tt.want, SplitDateFormats(tt.opts), tt.opts)
+	}
+}
@@ -0,0 +1,307 @@
+package converters
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidExpression = errors.New("invalid expression")
+	ErrUnknownFunction   = errors.New("unknown function")
+)
+
+// Evaluator computes a value from a record.
+type Evaluator func(record []string) (string, error)
+
+// Scope provides the header and formats used for compiling expressions.
+type Scope struct {
+	Header []string
+	Number NumberFormat // format of numbers in fields and results
+}
+
+// Expr is a value expression over the fields of a record.
+// Expressions are stateless and can be compiled for multiple tables.
+type Expr interface {
+	// Compile resolves the columns of the expression using the scope
+	// and returns an evaluator that computes the value for a record.
+	Compile(scope *Scope) (Evaluator, error)
+}
+
+// Literal is a constant value.
+type Literal string
+
+// Ref is a reference to the value of a column.
+type Ref string
+
+// Binary applies an arithmetic operator "+", "-", "*", "/", "%",
+// or the string concatenation operator "&" to two values.
+type Binary struct {
+	Op    string
+	Left  Expr
+	Right Expr
+}
+
+// Call calls a function, see [Functions].
+type Call struct {
+	Func string
+	Args []Expr
+}
+
+func (e Literal) Compile(scope *Scope) (Evaluator, error) {
+	return func([]string) (string, error) { return string(e), nil }, nil
+}
+
+func (e Ref) Compile(scope *Scope) (Evaluator, error) {
+	cols, err := ColumnIndex(scope.Header, Col(string(e)))
+	if err != nil {
+		return nil, err
+	}
+	if len(cols) != 1 {
+		return nil, ErrInvalidColumnIndex
+	}
+	idx := cols[0].Index
+	return func(record []string) (string, error) { return Field(record, idx), nil }, nil
+}
+
+func (e *Binary) Compile(scope *Scope) (Evaluator, error) {
+	left, err := e.Left.Compile(scope)
+	if err != nil {
+		return nil, err
+	}
+	right, err := e.Right.Compile(scope)
+	if err != nil {
+		return nil, err
+	}
+	if e.Op == "&" {
+		return func(record []string) (string, error) {
+			a, b, err := evalPair(left, right, record)
+			return a + b, err
+		}, nil
+	}
+
+	op, ok := arithmetic[e.Op]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown operator %q", ErrInvalidExpression, e.Op)
+	}
+	return func(record []string) (string, error) {
+		a, b, err := evalPair(left, right, record)
+		if err != nil || a == "" || b == "" {
+			return "", err // empty values propagate
+		}
+		fa, err := parseNumberArg(a, scope.Number)
+		if err != nil {
+			return "", err
+		}
+		fb, err := parseNumberArg(b, scope.Number)
+		if err != nil {
+			return "", err
+		}
+		n := op(fa, fb)
+		if math.IsInf(n, 0) || math.IsNaN(n) {
+			return "", fmt.Errorf("%w: %s %s %s is not a number", ErrInvalidExpression, a, e.Op, b)
+		}
+		return FormatNumber(n, scope.Number), nil
+	}, nil
+}
+
+var arithmetic = map[string]func(a, b float64) float64{
+	"+": func(a, b float64) float64 { return a + b },
+	"-": func(a, b float64) float64 { return a - b },
+	"*": func(a, b float64) float64 { return a * b },
+	"/": func(a, b float64) float64 { return a / b },
+	"%": math.Mod,
+}
+
+func (e *Call) Compile(scope *Scope) (Evaluator, error) {
+	fn, ok := Functions[strings.ToLower(e.Func)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownFunction, e.Func)
+	}
+	if len(e.Args) < fn.MinArgs || (fn.MaxArgs >= 0 && len(e.Args) > fn.MaxArgs) {
+		return nil, fmt.Errorf("%w: wrong number of arguments for %s: %d", ErrInvalidExpression, e.Func, len(e.Args))
+	}
+	args := make([]Evaluator, len(e.Args))
+	for i, arg := range e.Args {
+		eval, err := arg.Compile(scope)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = eval
+	}
+	return func(record []string) (string, error) {
+		values := make([]string, len(args))
+		for i, eval := range args {
+			v, err := eval(record)
+			if err != nil {
+				return "", err
+			}
+			values[i] = v
+		}
+		return fn.Call(scope, values)
+	}, nil
+}
+
+func evalPair(left, right Evaluator, record []string) (string, string, error) {
+	a, err := left(record)
+	if err != nil {
+		return "", "", err
+	}
+	b, err := right(record)
+	return a, b, err
+}
+
+func parseNumberArg(s string, format NumberFormat) (float64, error) {
+	n, ok := ParseNumber(s, format)
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidNumber, s)
+	}
+	return n, nil
+}
+
+// Function is a function that can be called in expressions.
+type Function struct {
+	MinArgs int
+	MaxArgs int // -1 for variadic functions
+	Call    func(scope *Scope, args []string) (string, error)
+}
+
+// Functions lists all functions that can be used in expressions.
+var Functions = map[string]Function{
+	"upper": {1, 1, func(_ *Scope, args []string) (string, error) { return strings.ToUpper(args[0]), nil }},
+	"lower": {1, 1, func(_ *Scope, args []string) (string, error) { return strings.ToLower(args[0]), nil }},
+	"trim":  {1, 1, func(_ *Scope, args []string) (string, error) { return strings.TrimSpace(args[0]), nil }},
+	"concat": {0, -1, func(_ *Scope, args []string) (string, error) {
+		return strings.Join(args, ""), nil
+	}},
+	"replace": {3, 3, func(_ *Scope, args []string) (string, error) {
+		return strings.ReplaceAll(args[0], args[1], args[2]), nil
+	}},
+	"coalesce": {1, -1, func(_ *Scope, args []string) (string, error) {
+		idx := slices.IndexFunc(args, func(s string) bool { return s != "" })
+		if idx < 0 {
+			return "", nil
+		}
+		return args[idx], nil
+	}},
+	"substr": {2, 3, substr},
+	"round":  {1, 2, round},
+	"year":   {1, 1, datePart(func(y, m, d int) int { return y })},
+	"month":  {1, 1, datePart(func(y, m, d int) int { return m })},
+	"day":    {1, 1, datePart(func(y, m, d int) int { return d })},
+}
+
+// substr returns the substring of args[0] starting at the 1-based position args[1]
+// with an optional length args[2]. Positions are counted in characters.
+func substr(scope *Scope, args []string) (string, error) {
+	s := []rune(args[0])
+	start, err := strconv.Atoi(args[1])
+	if err != nil || start < 1 {
+		return "", fmt.Errorf("%w: invalid start position %q", ErrInvalidExpression, args[1])
+	}
+	start = min(start-1, len(s))
+	end := len(s)
+	if len(args) > 2 {
+		n, err := strconv.Atoi(args[2])
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("%w: invalid length %q", ErrInvalidExpression, args[2])
+		}
+		end = min(start+n, len(s))
+	}
+	return string(s[start:end]), nil
+}
+
+// round rounds the number args[0] to args[1] decimal places (default 0).
+func round(scope *Scope, args []string) (string, error) {
+	if args[0] == "" {
+		return "", nil
+	}
+	n, err := parseNumberArg(args[0], scope.Number)
+	if err != nil {
+		return "", err
+	}
+	digits := 0
+	if len(args) > 1 {
+		digits, err = strconv.Atoi(args[1])
+		if err != nil || digits < 0 {
+			return "", fmt.Errorf("%w: invalid number of digits %q", ErrInvalidExpression, args[1])
+		}
+	}
+	pow := math.Pow10(digits)
+	s := strconv.FormatFloat(math.Round(n*pow)/pow, 'f', digits, 64)
+	return ReplaceSeparator(s, NumberDot.Sep(), scope.Number.Sep()), nil
+}
+
+func datePart(part func(y, m, d int) int) func(*Scope, []string) (string, error) {
+	return func(_ *Scope, args []string) (string, error) {
+		if args[0] == "" {
+			return "", nil
+		}
+		date, err := ParseDate(args[0], DateAny)
+		if err != nil {
+			return "", fmt.Errorf("%w: %q", err, args[0])
+		}
+		return strconv.Itoa(part(date.Year(), int(date.Month()), date.Day())), nil
+	}
+}
+
+// Assignment sets a column to the value of an expression.
+type Assignment struct {
+	Column string
+	Expr   Expr
+}
+
+// SetRows returns a [RowConverter] that sets columns to the values of expressions.
+// Existing columns are overwritten and new columns are appended.
+// If new columns are appended, fields without header are dropped, see [AlignRecord].
+// Assignments are applied in order and can use the results of previous assignments.
+func SetRows(format NumberFormat, assignments ...Assignment) RowConverter {
+	indices := make([]int, len(assignments))
+	evals := make([]Evaluator, len(assignments))
+	width, added := 0, false
+	return &MapRows{
+		HeaderFunc: func(header []string) ([]string, error) {
+			inputWidth := len(header)
+			header = slices.Clone(header)
+			for i, a := range assignments {
+				eval, err := a.Expr.Compile(&Scope{Header: header, Number: format})
+				if err != nil {
+					return nil, err
+				}
+				evals[i] = eval
+				indices[i] = slices.Index(header, a.Column)
+				if indices[i] < 0 {
+					indices[i] = len(header)
+					header = append(header, a.Column)
+				}
+			}
+			width, added = len(header), len(header) > inputWidth
+			return header, nil
+		},
+		RowFunc: func(record []string) ([]string, error) {
+			size := max(width, len(record))
+			if added {
+				// the new columns take the place of fields without header
+				size = width
+			}
+			result := make([]string, size)
+			copy(result, record)
+			for i, eval := range evals {
+				value, err := eval(result)
+				if err != nil {
+					return nil, err
+				}
+				result[indices[i]] = value
+			}
+			return result, nil
+		},
+	}
+}
+
+// SetColumns sets columns to the values of expressions, see [SetRows].
+func SetColumns(records Records, format NumberFormat, assignments ...Assignment) (Records, error) {
+	return ApplyRows(records, SetRows(format, assignments...))
+}
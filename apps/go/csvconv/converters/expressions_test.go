@@ -0,0 +1,79 @@
+package converters
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpressions(t *testing.T) {
+	header := []string{"name", "price", "qty", "date"}
+	record := []string{" Bob ", "1.234,50", "2", "03.03.2023"}
+
+	tests := []struct {
+		expr    Expr
+		want    string
+		wantErr error
+	}{
+		{Literal("x"), "x", nil},
+		{Ref("qty"), "2", nil},
+		{&Binary{Op: "*", Left: Ref("price"), Right: Ref("qty")}, "2469", nil},
+		{&Binary{Op: "/", Left: Ref("qty"), Right: Literal("0,5")}, "4", nil},
+		{&Binary{Op: "&", Left: Ref("qty"), Right: Literal("x")}, "2x", nil},
+		{&Binary{Op: "-", Left: Literal(""), Right: Ref("qty")}, "", nil},
+		{&Binary{Op: "/", Left: Ref("qty"), Right: Literal("0")}, "", ErrInvalidExpression},
+		{&Binary{Op: "+", Left: Ref("name"), Right: Ref("qty")}, "", ErrInvalidNumber},
+		{&Call{Func: "upper", Args: []Expr{&Call{Func: "trim", Args: []Expr{Ref("name")}}}}, "BOB", nil},
+		{&Call{Func: "substr", Args: []Expr{Literal("äöü"), Literal("2")}}, "öü", nil},
+		{&Call{Func: "replace", Args: []Expr{Ref("name"), Literal("o"), Literal("0")}}, " B0b ", nil},
+		{&Call{Func: "coalesce", Args: []Expr{Literal(""), Ref("qty")}}, "2", nil},
+		{&Call{Func: "round", Args: []Expr{Ref("price")}}, "1235", nil},
+		{&Call{Func: "round", Args: []Expr{Literal("2,345"), Literal("2")}}, "2,35", nil},
+		{&Call{Func: "year", Args: []Expr{Ref("date")}}, "2023", nil},
+		{&Call{Func: "nope"}, "", ErrUnknownFunction},
+		{Ref("missing"), "", ErrColumnNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v", tt.expr), func(t *testing.T) {
+			eval, err := tt.expr.Compile(&Scope{Header: header, Number: NumberComma})
+			if err == nil {
+				var got string
+				got, err = eval(record)
+				require.Equal(t, tt.want, got)
+			}
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestSetColumns(t *testing.T) {
+	records := Records{
+		{"a", "b"},
+		{"1", "2"},
+		{"3"},
+	}
+	got, err := SetColumns(records, NumberDot,
+		Assignment{Column: "b", Expr: &Binary{Op: "+", Left: Ref("a"), Right: Literal("1")}},
+		Assignment{Column: "c", Expr: &Binary{Op: "*", Left: Ref("b"), Right: Literal("10")}},
+	)
+	require.NoError(t, err)
+	require.Equal(t, Records{
+		{"a", "b", "c"},
+		{"1", "2", "20"},
+		{"3", "4", "40"},
+	}, got)
+
+	ragged := Records{{"a"}, {"1", "x", "y"}}
+	got, err = SetColumns(ragged, NumberDot, Assignment{Column: "a", Expr: Literal("2")})
+	require.NoError(t, err)
+	require.Equal(t, Records{{"a"}, {"2", "x", "y"}}, got, "fields without header are kept")
+	got, err = SetColumns(ragged, NumberDot, Assignment{Column: "b", Expr: Ref("a")})
+	require.NoError(t, err)
+	require.Equal(t, Records{{"a", "b"}, {"1", "1"}}, got, "fields without header are dropped")
+}
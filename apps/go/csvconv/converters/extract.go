@@ -0,0 +1,194 @@
+package converters
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidPattern = errors.New("invalid pattern")
+
+// compilePattern compiles a regular expression, which may be enclosed in slashes, e.g., "/\s+/".
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if IsColumnPattern(pattern) {
+		pattern = pattern[1 : len(pattern)-1]
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPattern, err)
+	}
+	return re, nil
+}
+
+// splitFunc returns a function that splits a value into at most n parts, or all parts if n < 0.
+// Separators enclosed in slashes are regular expressions, e.g., "/\s*;\s*/".
+func splitFunc(sep string) (func(value string, n int) []string, error) {
+	if sep == "" {
+		return nil, fmt.Errorf("%w: empty separator", ErrInvalidPattern)
+	}
+	if !IsColumnPattern(sep) {
+		return func(value string, n int) []string { return strings.SplitN(value, sep, n) }, nil
+	}
+	re, err := compilePattern(sep)
+	if err != nil {
+		return nil, err
+	}
+	return re.Split, nil
+}
+
+// outputColumns returns the header with the given columns, where existing columns are
+// overwritten and new columns are appended, and the indices of the columns.
+func outputColumns(header []string, columns []string) ([]string, []int) {
+	header = slices.Clone(header)
+	indices := make([]int, len(columns))
+	for i, name := range columns {
+		indices[i] = slices.Index(header, name)
+		if indices[i] < 0 {
+			indices[i] = len(header)
+			header = append(header, name)
+		}
+	}
+	return header, indices
+}
+
+// inputColumn returns the index of a single input column.
+func inputColumn(header []string, column string) (int, error) {
+	cols, err := ColumnIndex(header, Col(column))
+	if err != nil {
+		return 0, err
+	}
+	if len(cols) != 1 {
+		return 0, ErrInvalidColumnIndex
+	}
+	return cols[0].Index, nil
+}
+
+// setFields returns a copy of the record with at least width fields and the values at the indices.
+func setFields(record []string, width int, indices []int, values []string) []string {
+	result := make([]string, max(width, len(record)))
+	copy(result, record)
+	for i, idx := range indices {
+		result[idx] = Field(values, i)
+	}
+	return result
+}
+
+// ExtractRows returns a [RowConverter] that sets the columns named by the named groups
+// of the pattern, e.g., "IBAN: (?P<iban>\S+)", to the groups of the first match in the column.
+// Existing columns are overwritten and new columns are appended.
+// The columns are empty if the pattern does not match.
+func ExtractRows(column, pattern string) RowConverter {
+	var src, width int
+	var re *regexp.Regexp
+	var groups, indices []int // group numbers and output indices of named groups
+	return &MapRows{
+		HeaderFunc: func(header []string) ([]string, error) {
+			var err error
+			if re, err = compilePattern(pattern); err != nil {
+				return nil, err
+			}
+			names := []string{}
+			groups = nil
+			for i, name := range re.SubexpNames() {
+				if name != "" {
+					names = append(names, name)
+					groups = append(groups, i)
+				}
+			}
+			if len(names) == 0 {
+				return nil, fmt.Errorf("%w: %q has no named groups, e.g., (?P<name>...)", ErrInvalidPattern, pattern)
+			}
+			if src, err = inputColumn(header, column); err != nil {
+				return nil, err
+			}
+			header, indices = outputColumns(header, names)
+			width = len(header)
+			return header, nil
+		},
+		RowFunc: func(record []string) ([]string, error) {
+			values := make([]string, len(groups))
+			if match := re.FindStringSubmatch(Field(record, src)); match != nil {
+				for i, group := range groups {
+					values[i] = match[group]
+				}
+			}
+			return setFields(record, width, indices, values), nil
+		},
+	}
+}
+
+// SplitRows returns a [RowConverter] that splits the values of the column at the separator
+// into the given columns. The last column holds the remainder, missing parts are empty.
+// Existing columns are overwritten and new columns are appended.
+func SplitRows(column, sep string, into []string) RowConverter {
+	var src, width int
+	var split func(string, int) []string
+	var indices []int
+	return &MapRows{
+		HeaderFunc: func(header []string) ([]string, error) {
+			var err error
+			if split, err = splitFunc(sep); err != nil {
+				return nil, err
+			}
+			if len(into) == 0 {
+				return nil, fmt.Errorf("%w: no columns to split %q into", ErrInvalidColumnIndex, column)
+			}
+			if src, err = inputColumn(header, column); err != nil {
+				return nil, err
+			}
+			header, indices = outputColumns(header, into)
+			width = len(header)
+			return header, nil
+		},
+		RowFunc: func(record []string) ([]string, error) {
+			value := Field(record, src)
+			var parts []string
+			if value != "" {
+				parts = split(value, len(into))
+			}
+			return setFields(record, width, indices, parts), nil
+		},
+	}
+}
+
+// explodeRows implements the [RowConverter] for [ExplodeRows].
+type explodeRows struct {
+	column, sep string
+	src         int
+	split       func(string, int) []string
+}
+
+// ExplodeRows returns a [RowConverter] that splits the values of the column at the separator
+// and emits one record per part, with the part as the value of the column.
+// Records with an empty value are emitted as is.
+func ExplodeRows(column, sep string) RowConverter {
+	return &explodeRows{column: column, sep: sep}
+}
+
+func (e *explodeRows) Header(header []string) ([]string, error) {
+	var err error
+	if e.split, err = splitFunc(e.sep); err != nil {
+		return nil, err
+	}
+	if e.src, err = inputColumn(header, e.column); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+func (e *explodeRows) Row(record []string, emit Emit) error {
+	value := Field(record, e.src)
+	if value == "" {
+		return emit(record)
+	}
+	for _, part := range e.split(value, -1) {
+		if err := emit(replaceField(record, e.src, part)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *explodeRows) Flush(emit Emit) error { return nil }
@@ -0,0 +1,82 @@
+package converters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractRows(t *testing.T) {
+	records := Records{
+		{"date", "memo"},
+		{"2024-01-02", "SEPA transfer IBAN: DE02120300000000202051 BIC: BYLADEM1001"},
+		{"2024-01-03", "card payment"},
+		{"2024-01-04"},
+	}
+	got, err := ApplyRows(records, ExtractRows("memo", `/IBAN: (?P<iban>\S+)(?: BIC: (?P<bic>\S+))?/`))
+	require.NoError(t, err)
+	require.Equal(t, Records{
+		{"date", "memo", "iban", "bic"},
+		{"2024-01-02", records[1][1], "DE02120300000000202051", "BYLADEM1001"},
+		{"2024-01-03", "card payment", "", ""},
+		{"2024-01-04", "", "", ""},
+	}, got)
+
+	got, err = ApplyRows(records[:3], ExtractRows("memo", `^(?P<memo>\S+)`))
+	require.NoError(t, err)
+	require.Equal(t, Records{{"date", "memo"}, {"2024-01-02", "SEPA"}, {"2024-01-03", "card"}}, got)
+
+	_, err = ApplyRows(records, ExtractRows("memo", `IBAN: (\S+)`))
+	require.ErrorIs(t, err, ErrInvalidPattern)
+	_, err = ApplyRows(records, ExtractRows("memo", `(?P<x>`))
+	require.ErrorIs(t, err, ErrInvalidPattern)
+	_, err = ApplyRows(records, ExtractRows("text", `(?P<x>.)`))
+	require.ErrorIs(t, err, ErrColumnNotFound)
+}
+
+func TestSplitRows(t *testing.T) {
+	records := Records{
+		{"name", "tags"},
+		{"Ada King Lovelace", "math; poetry;code"},
+		{"Turing", ""},
+	}
+	got, err := ApplyRows(records, SplitRows("name", " ", []string{"first", "last"}))
+	require.NoError(t, err)
+	require.Equal(t, Records{
+		{"name", "tags", "first", "last"},
+		{"Ada King Lovelace", "math; poetry;code", "Ada", "King Lovelace"},
+		{"Turing", "", "Turing", ""},
+	}, got)
+
+	got, err = ApplyRows(records, SplitRows("tags", "/;\\s*/", []string{"tags", "more"}))
+	require.NoError(t, err)
+	require.Equal(t, Records{
+		{"name", "tags", "more"},
+		{"Ada King Lovelace", "math", "poetry;code"},
+		{"Turing", "", ""},
+	}, got)
+
+	_, err = ApplyRows(records, SplitRows("name", "", []string{"a"}))
+	require.ErrorIs(t, err, ErrInvalidPattern)
+}
+
+func TestExplodeRows(t *testing.T) {
+	records := Records{
+		{"name", "tags"},
+		{"Ada", "math; poetry;code"},
+		{"Alan", ""},
+	}
+	got, err := ApplyRows(records, ExplodeRows("tags", `/;\s*/`))
+	require.NoError(t, err)
+	require.Equal(t, Records{
+		{"name", "tags"},
+		{"Ada", "math"},
+		{"Ada", "poetry"},
+		{"Ada", "code"},
+		{"Alan", ""},
+	}, got)
+
+	got, err = ApplyRows(records, ExplodeRows("2", ";"), HeadRows(2))
+	require.NoError(t, err)
+	require.Equal(t, Records{{"name", "tags"}, {"Ada", "math"}, {"Ada", " poetry"}}, got)
+}
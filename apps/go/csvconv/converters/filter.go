@@ -1,6 +1,7 @@
 package converters
 
 import (
+	"cmp"
 	"errors"
 	"log/slog"
 	"regexp"
@@ -8,43 +9,78 @@ import (
 	"strings"
 )
 
-var ErrInvalidColumnIndex = errors.New("invalid column indices")
+var (
+	ErrInvalidColumnIndex = errors.New("invalid column indices")
+	ErrInvalidOperator    = errors.New("invalid operator")
+)
+
+// Operators lists all operators supported by [Filter].
+var Operators = []string{
+	"==", "=", "eq", "!=", "<>", "neq", "not",
+	"<", "lt", "<=", "le", "lte", ">", "gt", ">=", "ge", "gte",
+	"contains", "startswith", "endswith", "like",
+	"~", "regexp", "!~", "notregexp", "in", "is",
+}
+
+// IsOperator reports whether op is a supported filter operator.
+func IsOperator(op string) bool {
+	return slices.Contains(Operators, op)
+}
 
 func Filter(records Records, column string, op, value string) (Records, error) {
-	cols, err := records.ColumnIndex(column)
-	if err != nil {
-		return nil, err
-	}
-	if len(cols) != 1 {
-		slog.Error("invalid column indices for filter", "indices", cols, "column", column)
-		return nil, ErrInvalidColumnIndex
-	}
-	return filter(records, cols[0], op, value)
+	return Where(records, &Compare{Column: column, Op: op, Value: value})
 }
 
-func filter(records Records, column Column, op, value string) (Records, error) {
-	result := Records{}
-	result = append(result, records[0]) // header
-	for _, record := range records[1:] {
-		if compare(record[column.Index], value, op) {
-			result = append(result, record)
-		}
+// FilterRows returns a [RowConverter] that keeps the records where
+// the value of the column matches the given value using the operator.
+func FilterRows(column string, op, value string) RowConverter {
+	return WhereRows(&Compare{Column: column, Op: op, Value: value})
+}
+
+// Where returns the records that match the condition.
+func Where(records Records, cond Condition) (Records, error) {
+	if len(records) == 0 {
+		return nil, ErrColumnNotFound
 	}
-	return result, nil
+	return ApplyRows(records, WhereRows(cond))
 }
 
-func compare(a, b, op string) bool {
-	if IsNumber(a, NumberDot) && IsNumber(b, NumberDot) {
-		return compareNumbers(a, b, op)
+// WhereRows returns a [RowConverter] that keeps the records that match the condition.
+func WhereRows(cond Condition) RowConverter {
+	var match Predicate
+	return &MapRows{
+		HeaderFunc: func(header []string) ([]string, error) {
+			var err error
+			match, err = cond.Compile(header)
+			if err != nil {
+				return nil, err
+			}
+			return header, nil
+		},
+		RowFunc: func(record []string) ([]string, error) {
+			ok, err := match(record)
+			if err != nil || !ok {
+				return nil, err
+			}
+			return record, nil
+		},
 	}
-	if IsNumber(a, NumberComma) && IsNumber(b, NumberComma) {
-		panic("numbers must be converted to dot format before comparing")
+}
+
+// compare compares a and b as numbers if both are numbers in the format,
+// or as strings otherwise. The zero format is the dot format.
+func compare(a, b, op string, format NumberFormat) bool {
+	if spec, ok := cmp.Or(format, NumberDot).spec(); ok {
+		na, okA := spec.parse(a)
+		nb, okB := spec.parse(b)
+		if okA && okB {
+			return compareNumbers(spec.value(na), spec.value(nb), op)
+		}
 	}
 	return compareStrings(a, b, op)
 }
 
-func compareNumbers(a, b, op string) bool {
-	fa, fb := ToFloat(a), ToFloat(b)
+func compareNumbers(fa, fb float64, op string) bool {
 	switch op {
 	case "==", "=", "eq":
 		return fa == fb
@@ -85,8 +121,7 @@ func compareStrings(a, b, op string) bool {
 	case "endswith":
 		return strings.HasSuffix(a, b)
 	case "like":
-		b = strings.ReplaceAll(b, ".", "\\.") // escape dots
-		pattern := "^" + strings.ReplaceAll(b, "%", ".*") + "$"
+		pattern := likePattern(b)
 		matched, err := regexp.MatchString(pattern, a)
 		if err != nil {
 			slog.Error("invalid like pattern", "pattern", pattern, "error", err, "a", a, "b", b)
@@ -111,25 +146,41 @@ func compareStrings(a, b, op string) bool {
 		slog.Debug("in", "rhs", b, "lhs", a, "list", list, "search", search)
 		return slices.Contains(list, search)
 	case "is":
-		switch strings.ToLower(b) {
-		case "null", "nil", "none", "empty":
-			return a == ""
-		case "not null", "not nil", "not none", "not empty":
-			return a != ""
-		case "true":
-			return a == "true"
-		case "false":
-			return a == "false"
-		case "not true":
-			return a != "true"
-		case "not false":
-			return a != "false"
-		default:
+		result, ok := compareIs(a, b)
+		if !ok {
 			panic("unknown 'is' operator: " + b)
 		}
+		return result
 	case "not":
-		panic("'not' not implemented")
+		return a != b
 	default:
 		panic("unknown operator: " + op)
 	}
 }
+
+// likePattern converts a SQL-like pattern with "%" wildcards to a regular expression.
+func likePattern(b string) string {
+	b = strings.ReplaceAll(b, ".", "\\.") // escape dots
+	return "^" + strings.ReplaceAll(b, "%", ".*") + "$"
+}
+
+// compareIs evaluates "a is b", where b is a value such as "null" or "not true".
+// It returns false for ok if b is not supported.
+func compareIs(a, b string) (result bool, ok bool) {
+	switch strings.ToLower(b) {
+	case "null", "nil", "none", "empty":
+		return a == "", true
+	case "not null", "not nil", "not none", "not empty":
+		return a != "", true
+	case "true":
+		return a == "true", true
+	case "false":
+		return a == "false", true
+	case "not true":
+		return a != "true", true
+	case "not false":
+		return a != "false", true
+	default:
+		return false, false
+	}
+}
@@ -100,6 +100,41 @@ func TestFilter(t *testing.T) {
 	}
 }
 
+func TestWhere(t *testing.T) {
+	records := Records{
+		{"name", "age"},
+		{"Alice", "25"},
+		{"Bob", "30"},
+		{"Charlie", ""},
+	}
+
+	got, err := Where(records, Or{
+		&Compare{Column: "age", Op: "is", Value: "empty"},
+		And{
+			&Not{Cond: &Compare{Column: "name", Op: "in", Values: []string{"Alice"}}},
+			&Compare{Column: "age", Op: ">=", Value: "30"},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, Records{{"name", "age"}, {"Bob", "30"}, {"Charlie", ""}}, got)
+
+	got, err = Filter(records, "name", "not", "Bob")
+	require.NoError(t, err)
+	require.Equal(t, Records{{"name", "age"}, {"Alice", "25"}, {"Charlie", ""}}, got)
+
+	amounts := Records{{"amount"}, {"1.000,50"}, {"999,9"}, {"n/a"}}
+	cond := And{&Compare{Column: "amount", Op: ">", Value: "1.000"}}
+	SetNumberFormat(cond, NumberComma)
+	got, err = Where(amounts, cond)
+	require.NoError(t, err)
+	require.Equal(t, Records{{"amount"}, {"1.000,50"}, {"n/a"}}, got, "strings compare after numbers")
+
+	_, err = Where(records, &Compare{Column: "name", Op: "~", Value: "[a-"})
+	require.ErrorIs(t, err, ErrInvalidOperator)
+	_, err = Where(records, &Compare{Column: "age", Op: "is", Value: "maybe"})
+	require.ErrorIs(t, err, ErrInvalidOperator)
+}
+
 // func equalRecords(a, b Records) bool {
 // 	if len(a) != len(b) {
 // 		return false
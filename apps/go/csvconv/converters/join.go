@@ -0,0 +1,192 @@
+package converters
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidJoin = errors.New("invalid join")
+
+// JoinKind defines which records are emitted by a join.
+type JoinKind string
+
+const (
+	JoinInner JoinKind = "inner" // records with a match, combined with each match
+	JoinLeft  JoinKind = "left"  // all records, combined with each match or padded with empty fields
+	JoinAnti  JoinKind = "anti"  // records without a match, unchanged
+)
+
+func ParseJoinKind(s string) (JoinKind, error) {
+	switch kind := JoinKind(strings.ToLower(s)); kind {
+	case JoinInner, JoinLeft, JoinAnti:
+		return kind, nil
+	default:
+		return "", fmt.Errorf("%w: unknown join kind %q", ErrInvalidJoin, s)
+	}
+}
+
+// JoinKey is a pair of columns that must be equal for two records to match.
+type JoinKey struct {
+	Left  string // column of the input records
+	Right string // column of the joined table
+}
+
+// joinRows implements the [RowConverter] for [JoinRows].
+type joinRows struct {
+	load  func() (Records, error)
+	keys  []JoinKey
+	kind  JoinKind
+	name  string
+	left  []int          // key columns of the input records
+	right []int          // non-key columns of the joined table
+	index map[string]int // maps a key to its matches in table
+	table [][][]string   // records of the joined table grouped by key
+	width int            // width of the input header
+	empty []string       // padding for left joins
+}
+
+// JoinRows returns a [RowConverter] that joins the input records with the table
+// returned by load using a hash join. The table is loaded when the header is read.
+// Columns of the joined table that clash with input columns are prefixed with the name
+// of the table, e.g., "owners.name". The key columns of the joined table are dropped.
+func JoinRows(load func() (Records, error), keys []JoinKey, kind JoinKind, name string) RowConverter {
+	return &joinRows{load: load, keys: keys, kind: kind, name: name}
+}
+
+// Join joins the records with the right table, see [JoinRows].
+func Join(records, right Records, keys []JoinKey, kind JoinKind, name string) (Records, error) {
+	load := func() (Records, error) { return right, nil }
+	return ApplyRows(records, JoinRows(load, keys, kind, name))
+}
+
+func (j *joinRows) Header(header []string) ([]string, error) {
+	if len(j.keys) == 0 {
+		return nil, fmt.Errorf("%w: no join keys", ErrInvalidJoin)
+	}
+	if _, err := ParseJoinKind(string(j.kind)); err != nil {
+		return nil, err
+	}
+	table, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+	if len(table) == 0 {
+		return nil, fmt.Errorf("%w: joined table %q is empty", ErrInvalidJoin, j.name)
+	}
+
+	rightKeys := make([]int, len(j.keys))
+	j.left = make([]int, len(j.keys))
+	for i, key := range j.keys {
+		if j.left[i], err = singleColumn(header, key.Left); err != nil {
+			return nil, err
+		}
+		if rightKeys[i], err = singleColumn(table.HeaderRow(), key.Right); err != nil {
+			return nil, fmt.Errorf("%w: column %q of %q: %w", ErrInvalidJoin, key.Right, j.name, err)
+		}
+	}
+
+	j.index = map[string]int{}
+	j.table = nil
+	for _, record := range table.Data() {
+		id := joinID(record, rightKeys)
+		idx, ok := j.index[id]
+		if !ok {
+			idx = len(j.table)
+			j.index[id] = idx
+			j.table = append(j.table, nil)
+		}
+		j.table[idx] = append(j.table[idx], record)
+	}
+
+	j.width = len(header)
+	if j.kind == JoinAnti {
+		return header, nil
+	}
+
+	result := slices.Clone(header)
+	j.right = nil
+	for idx, col := range table.HeaderRow() {
+		if slices.Contains(rightKeys, idx) {
+			continue
+		}
+		j.right = append(j.right, idx)
+		result = append(result, uniqueName(result, col, j.name))
+	}
+	j.empty = make([]string, len(j.right))
+	return result, nil
+}
+
+func (j *joinRows) Row(record []string, emit Emit) error {
+	idx, ok := j.index[joinID(record, j.left)]
+	switch {
+	case j.kind == JoinAnti:
+		if ok {
+			return nil
+		}
+		return emit(record)
+	case !ok && j.kind == JoinLeft:
+		return emit(j.combine(record, nil))
+	case !ok:
+		return nil
+	}
+	for _, match := range j.table[idx] {
+		if err := emit(j.combine(record, match)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (j *joinRows) Flush(emit Emit) error { return nil }
+
+// combine appends the non-key fields of the match to the record.
+// A nil match appends empty fields.
+func (j *joinRows) combine(record, match []string) []string {
+	result := make([]string, max(j.width, len(record)), max(j.width, len(record))+len(j.right))
+	copy(result, record)
+	if match == nil {
+		return append(result, j.empty...)
+	}
+	for _, idx := range j.right {
+		result = append(result, Field(match, idx))
+	}
+	return result
+}
+
+// singleColumn returns the index of a single column of the header.
+func singleColumn(header []string, name string) (int, error) {
+	cols, err := ColumnIndex(header, Col(name))
+	if err != nil {
+		return 0, err
+	}
+	if len(cols) != 1 {
+		return 0, ErrInvalidColumnIndex
+	}
+	return cols[0].Index, nil
+}
+
+func joinID(record []string, cols []int) string {
+	key := make([]string, len(cols))
+	for i, idx := range cols {
+		key[i] = Field(record, idx)
+	}
+	return strings.Join(key, "\x00")
+}
+
+// uniqueName returns a column name that does not clash with the header.
+// Clashing names are prefixed with the table name and numbered if needed.
+func uniqueName(header []string, name, table string) string {
+	if !slices.Contains(header, name) {
+		return name
+	}
+	if table != "" {
+		name = table + "." + name
+	}
+	unique := name
+	for i := 2; slices.Contains(header, unique); i++ {
+		unique = fmt.Sprintf("%s_%d", name, i)
+	}
+	return unique
+}
@@ -0,0 +1,62 @@
+package converters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJoin(t *testing.T) {
+	accounts := Records{
+		{"id", "bank", "name"},
+		{"1", "A", "checking"},
+		{"2", "B", "savings"},
+		{"3", "A", "depot"},
+	}
+	owners := Records{
+		{"account", "bank", "name", "name"},
+		{"1", "A", "Alice", "x"},
+		{"2", "B", "Bob", "y"},
+		{"2", "B", "Carol", "z"},
+		{"3", "B", "Dave", "w"},
+	}
+	keys := []JoinKey{{Left: "id", Right: "account"}, {Left: "bank", Right: "bank"}}
+
+	tests := []struct {
+		kind JoinKind
+		want Records
+	}{
+		{JoinInner, Records{
+			{"id", "bank", "name", "owners.name", "owners.name_2"},
+			{"1", "A", "checking", "Alice", "x"},
+			{"2", "B", "savings", "Bob", "y"},
+			{"2", "B", "savings", "Carol", "z"},
+		}},
+		{JoinLeft, Records{
+			{"id", "bank", "name", "owners.name", "owners.name_2"},
+			{"1", "A", "checking", "Alice", "x"},
+			{"2", "B", "savings", "Bob", "y"},
+			{"2", "B", "savings", "Carol", "z"},
+			{"3", "A", "depot", "", ""},
+		}},
+		{JoinAnti, Records{
+			{"id", "bank", "name"},
+			{"3", "A", "depot"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.kind), func(t *testing.T) {
+			got, err := Join(accounts, owners, keys, tt.kind, "owners")
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := Join(accounts, owners, []JoinKey{{Left: "id", Right: "nope"}}, JoinInner, "owners")
+	require.ErrorIs(t, err, ErrInvalidJoin)
+	require.ErrorIs(t, err, ErrColumnNotFound)
+
+	_, err = Join(accounts, owners, keys, "outer", "owners")
+	require.ErrorIs(t, err, ErrInvalidJoin)
+}
@@ -0,0 +1,229 @@
+package converters
+
+import (
+	"math/rand/v2"
+	"slices"
+	"strings"
+)
+
+// headRows implements the [RowConverter] for [HeadRows].
+type headRows struct {
+	MapRows
+	n int
+}
+
+// HeadRows returns a [RowConverter] that emits the first n records.
+// It returns [ErrStop] after the n-th record, so that readers can stop early.
+func HeadRows(n int) RowConverter {
+	return &headRows{n: n}
+}
+
+func (h *headRows) Row(record []string, emit Emit) error {
+	if h.n <= 0 {
+		return ErrStop
+	}
+	h.n--
+	if err := emit(record); err != nil {
+		return err
+	}
+	if h.n == 0 {
+		return ErrStop
+	}
+	return nil
+}
+
+// tailRows implements the [RowConverter] for [TailRows].
+type tailRows struct {
+	MapRows
+	n    int
+	rows [][]string // ring buffer of the last n records
+	next int        // index of the oldest record once the buffer is full
+}
+
+// TailRows returns a [RowConverter] that emits the last n records on Flush.
+func TailRows(n int) RowConverter {
+	return &tailRows{n: n}
+}
+
+func (t *tailRows) Row(record []string, emit Emit) error {
+	switch {
+	case t.n <= 0:
+	case len(t.rows) < t.n:
+		t.rows = append(t.rows, record)
+	default:
+		t.rows[t.next] = record
+		t.next = (t.next + 1) % t.n
+	}
+	return nil
+}
+
+func (t *tailRows) Flush(emit Emit) error {
+	rows := slices.Concat(t.rows[t.next:], t.rows[:t.next])
+	t.rows, t.next = nil, 0
+	for _, record := range rows {
+		if err := emit(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SkipRows returns a [RowConverter] that drops the first n records.
+func SkipRows(n int) RowConverter {
+	return &MapRows{RowFunc: func(record []string) ([]string, error) {
+		if n > 0 {
+			n--
+			return nil, nil
+		}
+		return record, nil
+	}}
+}
+
+// rowKeys builds the keys of records from a set of columns.
+type rowKeys struct {
+	columns []string // all columns if empty
+	cols    []Column
+}
+
+func (k *rowKeys) Header(header []string) ([]string, error) {
+	k.cols = nil
+	if len(k.columns) > 0 {
+		cols, err := ColumnIndex(header, Cols(k.columns...)...)
+		if err != nil {
+			return nil, err
+		}
+		k.cols = cols
+	}
+	return header, nil
+}
+
+func (k *rowKeys) key(record []string) string {
+	if k.cols == nil {
+		return strings.Join(record, "\x00")
+	}
+	key := make([]string, len(k.cols))
+	for i, col := range k.cols {
+		key[i] = Field(record, col.Index)
+	}
+	return strings.Join(key, "\x00")
+}
+
+// distinctRows implements the [RowConverter] for [DistinctRows].
+type distinctRows struct {
+	rowKeys
+	seen map[string]bool
+}
+
+// DistinctRows returns a [RowConverter] that emits the first record of each set
+// of records with equal values in the given columns, or in all columns if none are given.
+func DistinctRows(columns ...string) RowConverter {
+	return &distinctRows{rowKeys: rowKeys{columns: columns}, seen: map[string]bool{}}
+}
+
+func (d *distinctRows) Row(record []string, emit Emit) error {
+	key := d.key(record)
+	if d.seen[key] {
+		return nil
+	}
+	d.seen[key] = true
+	return emit(record)
+}
+
+func (d *distinctRows) Flush(emit Emit) error {
+	d.seen = map[string]bool{}
+	return nil
+}
+
+// dedupeRows implements the [RowConverter] for [DedupeRows] when keeping the last record.
+type dedupeRows struct {
+	rowKeys
+	last map[string]int // index of the last record of each key
+	rows [][]string     // records in input order, replaced records are nil
+}
+
+// DedupeRows returns a [RowConverter] that emits one record of each set of records
+// with equal values in the given columns. It keeps the first record as it arrives,
+// or the last record, in which case records are buffered until Flush and emitted
+// in the order of the kept records.
+func DedupeRows(columns []string, keepLast bool) RowConverter {
+	if !keepLast {
+		return DistinctRows(columns...)
+	}
+	return &dedupeRows{rowKeys: rowKeys{columns: columns}, last: map[string]int{}}
+}
+
+func (d *dedupeRows) Row(record []string, emit Emit) error {
+	key := d.key(record)
+	if i, ok := d.last[key]; ok {
+		d.rows[i] = nil
+	}
+	d.last[key] = len(d.rows)
+	d.rows = append(d.rows, record)
+	return nil
+}
+
+func (d *dedupeRows) Flush(emit Emit) error {
+	rows := d.rows
+	d.rows, d.last = nil, map[string]int{}
+	for _, record := range rows {
+		if record == nil {
+			continue
+		}
+		if err := emit(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sampleRows implements the [RowConverter] for [SampleRows].
+type sampleRows struct {
+	MapRows
+	n    int
+	rand *rand.Rand
+	seen int
+	rows []sampledRow
+}
+
+type sampledRow struct {
+	index  int
+	record []string
+}
+
+// SampleRows returns a [RowConverter] that emits a uniform random sample of n records
+// in their input order on Flush. The sample is reproducible for any given seed,
+// a nil seed uses a random seed.
+func SampleRows(n int, seed *uint64) RowConverter {
+	s := rand.Uint64()
+	if seed != nil {
+		s = *seed
+	}
+	return &sampleRows{n: n, rand: rand.New(rand.NewPCG(s, s))}
+}
+
+func (s *sampleRows) Row(record []string, emit Emit) error {
+	// reservoir sampling, each record replaces a sampled record with probability n/seen
+	s.seen++
+	switch {
+	case s.n <= 0:
+	case len(s.rows) < s.n:
+		s.rows = append(s.rows, sampledRow{s.seen, record})
+	default:
+		if i := s.rand.IntN(s.seen); i < s.n {
+			s.rows[i] = sampledRow{s.seen, record}
+		}
+	}
+	return nil
+}
+
+func (s *sampleRows) Flush(emit Emit) error {
+	rows := s.rows
+	s.rows, s.seen = nil, 0
+	slices.SortFunc(rows, func(a, b sampledRow) int { return a.index - b.index })
+	for _, row := range rows {
+		if err := emit(row.record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
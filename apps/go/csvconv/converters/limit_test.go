@@ -0,0 +1,95 @@
+package converters
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitRows(t *testing.T) {
+	records := Records{
+		{"id", "name"},
+		{"1", "a"},
+		{"2", "b"},
+		{"1", "c"},
+		{"3", "a"},
+		{"2", "b"},
+	}
+	tests := []struct {
+		name   string
+		stages []RowConverter
+		expect Records
+	}{
+		{"head", []RowConverter{HeadRows(2)}, Records{{"id", "name"}, {"1", "a"}, {"2", "b"}}},
+		{"head 0", []RowConverter{HeadRows(0)}, Records{{"id", "name"}}},
+		{"head all", []RowConverter{HeadRows(10)}, records},
+		{"tail", []RowConverter{TailRows(2)}, Records{{"id", "name"}, {"3", "a"}, {"2", "b"}}},
+		{"tail 0", []RowConverter{TailRows(0)}, Records{{"id", "name"}}},
+		{"tail all", []RowConverter{TailRows(10)}, records},
+		{"skip", []RowConverter{SkipRows(3)}, Records{{"id", "name"}, {"3", "a"}, {"2", "b"}}},
+		{"skip head", []RowConverter{SkipRows(1), HeadRows(2)}, Records{{"id", "name"}, {"2", "b"}, {"1", "c"}}},
+		{"head tail", []RowConverter{HeadRows(4), TailRows(1)}, Records{{"id", "name"}, {"3", "a"}}},
+		{"distinct", []RowConverter{DistinctRows()}, records[:5]},
+		{"distinct id", []RowConverter{DistinctRows("id")}, Records{{"id", "name"}, {"1", "a"}, {"2", "b"}, {"3", "a"}}},
+		{"dedupe first", []RowConverter{DedupeRows([]string{"name"}, false)}, Records{{"id", "name"}, {"1", "a"}, {"2", "b"}, {"1", "c"}}},
+		{"dedupe last", []RowConverter{DedupeRows([]string{"name"}, true)}, Records{{"id", "name"}, {"1", "c"}, {"3", "a"}, {"2", "b"}}},
+		{"dedupe head", []RowConverter{DedupeRows([]string{"id"}, true), HeadRows(1)}, Records{{"id", "name"}, {"1", "c"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ApplyRows(records, tt.stages...)
+			require.NoError(t, err)
+			require.Equal(t, tt.expect, got)
+		})
+	}
+
+	_, err := ApplyRows(records, DistinctRows("unknown"))
+	require.ErrorIs(t, err, ErrColumnNotFound)
+}
+
+func TestHeadRowsStop(t *testing.T) {
+	result := Records{}
+	p := NewPipeline(func(record []string) error {
+		result = append(result, record)
+		return nil
+	}, HeadRows(2))
+
+	require.NoError(t, p.Push([]string{"a"}))
+	require.NoError(t, p.Push([]string{"1"}))
+	require.ErrorIs(t, p.Push([]string{"2"}), ErrStop)
+	require.ErrorIs(t, p.Push([]string{"3"}), ErrStop)
+	require.NoError(t, p.Flush())
+	require.Equal(t, Records{{"a"}, {"1"}, {"2"}}, result)
+}
+
+func TestSampleRows(t *testing.T) {
+	records := Records{{"digit", "letter"}}
+	for i := range 100 {
+		records = append(records, []string{string(rune('0' + i%10)), string(rune('a' + i/10))})
+	}
+	sample := func(n int, seed uint64) Records {
+		got, err := ApplyRows(records, SampleRows(n, &seed))
+		require.NoError(t, err)
+		return got
+	}
+
+	got := sample(5, 42)
+	require.Len(t, got, 6)
+	require.Equal(t, got, sample(5, 42), "seeded samples are reproducible")
+	require.NotEqual(t, got, sample(5, 43))
+	require.Equal(t, sample(5, 0), sample(5, 0), "zero is a seed")
+
+	// sampled records keep their input order
+	last := 0
+	for _, record := range got[1:] {
+		i := slices.IndexFunc(records, func(r []string) bool { return slices.Equal(r, record) })
+		require.Greater(t, i, last)
+		last = i
+	}
+
+	got, err := ApplyRows(records, SampleRows(200, nil))
+	require.NoError(t, err)
+	require.Equal(t, records, got)
+	require.Equal(t, records.Header(), sample(0, 1))
+}
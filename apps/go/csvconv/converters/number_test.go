@@ -167,3 +167,90 @@ func TestChangeNumberSeparators(t *testing.T) {
 		})
 	}
 }
+
+func TestParseNumberFormat(t *testing.T) {
+	tests := []struct {
+		spec string
+		want NumberFormat
+	}{
+		{"dot", NumberDot},
+		{"EN", NumberDot},
+		{"de", NumberComma},
+		{",", NumberComma},
+		{"ch", NumberSwiss},
+		{"de+2+group", "comma+group+2"},
+		{"en+$+acct", "dot+usd+acct"},
+		{"fr+€+pct+trail+nogroup", "fr+nogroup+eur+pct+trail"},
+	}
+	for _, tt := range tests {
+		got, err := ParseNumberFormat(tt.spec)
+		require.NoError(t, err, tt.spec)
+		require.Equal(t, tt.want, got, tt.spec)
+	}
+	for _, spec := range []string{"", "xx", "de+", "de+10", "en+btc"} {
+		_, err := ParseNumberFormat(spec)
+		require.ErrorIs(t, err, ErrInvalidNumberFormat, spec)
+	}
+}
+
+func TestReformatNumber(t *testing.T) {
+	tests := []struct {
+		from, to string
+		value    string
+		want     string
+	}{
+		{"en", "de", "1,454,345.12", "1.454.345,12"},
+		{"en", "de", "-1.0", "-1,0"},
+		{"en", "ch", "1,234.5", "1'234.5"},
+		{"de", "fr", "1.234,5", "1 234,5"},
+		{"de", "en+group", "1234567,891", "1,234,567.891"},
+		{"de", "en+nogroup", "1.234,5", "1234.5"},
+		{"en", "de+2", "1234.567", "1234,57"},
+		{"en", "de+0", "-0.4", "0"},
+		{"de+eur", "en+usd", "-1.234,50 €", "-$1,234.50"},
+		{"de+eur", "en", "1.234,50EUR", "1,234.50"},
+		{"en", "ch+chf+2", "12.5", "CHF 12.50"},
+		{"en+acct", "de+trail", "(123.45)", "123,45-"},
+		{"de+trail", "en+acct+usd", "123,45-", "($123.45)"},
+		{"en+pct", "de", "12.5%", "0,125"},
+		{"en", "de+pct", "0.125", "12,5%"},
+		{"en+pct", "de+pct", "1,250.5 %", "1.250,5%"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value+" to "+tt.to, func(t *testing.T) {
+			got, ok := ReformatNumber(tt.value, MustParseNumberFormat(tt.from), MustParseNumberFormat(tt.to))
+			require.True(t, ok)
+			require.Equal(t, tt.want, got)
+		})
+	}
+
+	for _, value := range []string{"-", "€", "(12)", "12-", "12%", "1.2.3"} {
+		_, ok := ReformatNumber(value, "de+eur", NumberDot)
+		require.False(t, ok, value)
+	}
+}
+
+func TestParseFormatNumber(t *testing.T) {
+	n, ok := ParseNumber("(1.234,50 €)", "de+eur+acct")
+	require.True(t, ok)
+	require.Equal(t, -1234.5, n)
+	n, ok = ParseNumber("12,5%", "de+pct")
+	require.True(t, ok)
+	require.Equal(t, 0.125, n)
+	n, ok = ParseNumber("1'234.5", NumberSwiss)
+	require.True(t, ok)
+	require.Equal(t, 1234.5, n)
+	n, ok = ParseNumber("0.5", NumberComma)
+	require.True(t, ok, "plain numbers are accepted in any format")
+	require.Equal(t, 0.5, n)
+	for _, value := range []string{"NaN", "Inf", "-inf", "0x10", "1e3"} {
+		_, ok = ParseNumber(value, NumberDot)
+		require.False(t, ok, value)
+	}
+
+	require.Equal(t, "1234,5", FormatNumber(1234.5, NumberComma))
+	require.Equal(t, "1.234,50 €", FormatNumber(1234.5, "comma+group+2+eur"))
+	require.Equal(t, "(1,234.50)", FormatNumber(-1234.5, "dot+group+2+acct"))
+	require.Equal(t, "12.5%", FormatNumber(0.125, "dot+pct"))
+	require.Equal(t, "0.3", FormatNumber(0.1+0.2, NumberDot))
+}
@@ -4,39 +4,48 @@ import (
 	"errors"
 	"log/slog"
 	"regexp"
-	"slices"
 	"strconv"
 	"strings"
 )
 
+// NumberFormat describes how numbers are written. It is a locale with optional modifiers separated by "+",
+// e.g., "de+group+2+eur" for "1.234,50 €" or "en+acct" for "(1,234.50)".
+//
+// Locales:
+//
+//	dot, en, us     1,234.5
+//	comma, de, eu   1.234,5
+//	ch              1'234.5
+//	fr              1 234,5
+//
+// Modifiers:
+//
+//	group, nogroup  add or strip thousands grouping, existing grouping is kept by default
+//	0-9             round to a fixed number of decimals
+//	eur, usd, ...   currency symbol or code, also "€", "$", "£", "¥"
+//	pct             percent values, e.g., "12.5%" is the number 0.125
+//	acct            accounting negatives, e.g., "(12.5)"
+//	trail           trailing minus, e.g., "12.5-"
 type NumberFormat string
 
 const (
 	NumberInvalid NumberFormat = ""
 	NumberComma   NumberFormat = "comma"
 	NumberDot     NumberFormat = "dot"
+	NumberSwiss   NumberFormat = "ch"
+	NumberFrench  NumberFormat = "fr"
 )
 
+// Sep returns the decimal separator, or 0 if the format is invalid.
 func (f NumberFormat) Sep() rune {
-	switch f {
-	case NumberComma:
-		return ','
-	case NumberDot:
-		return '.'
-	default:
-		return 0
-	}
+	spec, _ := f.spec()
+	return spec.decimal
 }
 
+// Fmt returns the thousands separator, or 0 if the format is invalid.
 func (f NumberFormat) Fmt() rune {
-	switch f {
-	case NumberComma:
-		return '.'
-	case NumberDot:
-		return ','
-	default:
-		return 0
-	}
+	spec, _ := f.spec()
+	return spec.group
 }
 
 var ErrInvalidNumber = errors.New("invalid number")
@@ -44,16 +53,15 @@ var ErrInvalidNumber = errors.New("invalid number")
 var ErrInvalidNumberFormat = errors.New("invalid number format")
 
 func MustParseNumberFormat(s string) NumberFormat { return MustParse(s, ParseNumberFormat) }
+
+// ParseNumberFormat parses a locale with optional modifiers, such as "de+2+eur",
+// and returns its canonical form, e.g., "comma+2+eur".
 func ParseNumberFormat(s string) (NumberFormat, error) {
-	s = strings.TrimSpace(strings.ToLower(s))
-	switch s {
-	case "comma", ",":
-		return NumberComma, nil
-	case "dot", ".":
-		return NumberDot, nil
-	default:
-		return NumberInvalid, ErrInvalidNumberFormat
+	spec, err := parseNumberSpec(s)
+	if err != nil {
+		return NumberInvalid, err
 	}
+	return NumberFormat(spec.name()), nil
 }
 
 func CleanNumbers(records Records, fmt NumberFormat) (Records, error) {
@@ -69,31 +77,28 @@ func CleanNumbers(records Records, fmt NumberFormat) (Records, error) {
 	return records, nil
 }
 
-// CleanNumber removes formatting characters "." or "," from a number string.
-// And returns the cleaned number string if the input is a valid number for the given separator.
+// CleanNumber removes grouping and decorations, such as currency symbols, from a number string.
+// And returns the cleaned number string if the input is a valid number for the given format.
 func CleanNumber(field string, fmt NumberFormat) (string, bool) {
-	if !IsNumber(field, fmt) {
+	spec, ok := fmt.spec()
+	if !ok {
 		return "", false
 	}
-	switch fmt {
-	case NumberComma: // remove all dot formatting from comma-separated number
-		field = strings.ReplaceAll(field, ".", "")
-		number := ReplaceSeparator(field, ',', '.') // make it valid for ParseFloat
-		_, err := strconv.ParseFloat(number, 64)
-		if err != nil {
-			panic(err) // should not happen, because IsNumber was true
-		}
-		return field, true
-	case NumberDot: // remove all comma formatting from dot-separated number (should be a valid number already)
-		field = strings.ReplaceAll(field, ",", "")
-		_, err := strconv.ParseFloat(field, 64)
-		if err != nil {
-			panic(err) // should not happen, because IsNumber was true
-		}
-		return field, true
-	default:
+	n, ok := spec.parse(field)
+	if !ok {
 		return "", false
 	}
+	clean := n.int
+	if n.point {
+		clean += string(spec.decimal) + n.frac
+	}
+	switch {
+	case n.neg:
+		clean = "-" + clean
+	case n.plus:
+		clean = "+" + clean
+	}
+	return clean, true
 }
 
 func ReplaceSeparator(value string, srcSep, dstSep rune) string {
@@ -108,89 +113,126 @@ func ReplaceSeparator(value string, srcSep, dstSep rune) string {
 }
 
 func ConvertNumber(records Records, column string, from, to NumberFormat) (Records, error) {
-	idx, err := records.Columns(column)
-	if err != nil {
-		return nil, err
+	if len(records) == 0 {
+		return nil, ErrColumnNotFound
 	}
-	if len(idx) != 1 {
-		return nil, ErrInvalidColumnIndex
-	}
-	return convertNumber(records, idx[0], from, to)
+	return ApplyRows(records, NumberRows(column, from, to))
 }
 
 func ConvertNumbers(records Records, from, to NumberFormat) (Records, error) {
-	result := Records{}
-	for _, record := range records {
-		newRecord := make([]string, len(record))
-		for j, field := range record {
-			slog.Debug("try converting number", "number", field, "from", from, "to", to)
-			if IsNumber(field, from) {
-				repl := ReplaceSeparator(field, from.Sep(), to.Sep())
-				slog.Debug("converted number", "number", field, "from", from, "to", to, "result", repl)
-				newRecord[j] = repl
-			} else {
-				newRecord[j] = field
+	return ApplyRows(records, NumbersRows(from, to))
+}
+
+// NumberRows returns a [RowConverter] that converts the numbers of a column.
+// It fails on the first field that is not a number in the source format.
+func NumberRows(column string, from, to NumberFormat) RowConverter {
+	var col Column
+	return &MapRows{
+		HeaderFunc: func(header []string) ([]string, error) {
+			cols, err := ColumnIndex(header, Col(column))
+			if err != nil {
+				return nil, err
 			}
-		}
-		result = append(result, newRecord)
+			if len(cols) != 1 {
+				return nil, ErrInvalidColumnIndex
+			}
+			col = cols[0]
+			return header, nil
+		},
+		RowFunc: func(record []string) ([]string, error) {
+			return convertNumber(record, col, from, to)
+		},
 	}
-	return result, nil
 }
 
-func convertNumber(records Records, col Column, from, to NumberFormat) (Records, error) {
-	result := records.Header().Clone()
-	for _, record := range records.Data() {
-		if len(record) <= col.Index {
-			// unset field, keep the row as is
-			result = append(result, record)
-			continue
-		}
-		val := Unquote(record[col.Index])
-		if val == "" {
-			// empty field, keep the row as is
-			result = append(result, record)
-			continue
-		}
+// NumbersRows returns a [RowConverter] that converts all numbers, including the header.
+// Fields that are not numbers in the source format are kept as is.
+func NumbersRows(from, to NumberFormat) RowConverter {
+	return &MapRows{
+		HeaderFunc: func(header []string) ([]string, error) {
+			return convertNumbers(header, from, to), nil
+		},
+		RowFunc: func(record []string) ([]string, error) {
+			return convertNumbers(record, from, to), nil
+		},
+	}
+}
 
-		if !IsNumber(val, from) {
-			slog.Error("invalid number", "number", record[col.Index])
-			return nil, ErrInvalidNumber
+func convertNumbers(record []string, from, to NumberFormat) []string {
+	newRecord := make([]string, len(record))
+	for j, field := range record {
+		slog.Debug("try converting number", "number", field, "from", from, "to", to)
+		if repl, ok := ReformatNumber(field, from, to); ok {
+			slog.Debug("converted number", "number", field, "from", from, "to", to, "result", repl)
+			newRecord[j] = repl
+		} else {
+			newRecord[j] = field
 		}
-		slog.Debug("converting number", "number", val, "from", from, "to", to)
-
-		newRecord := slices.Clone(record)
-		newRecord[col.Index] = ReplaceSeparator(val, from.Sep(), to.Sep())
-		result = append(result, newRecord)
 	}
-	return result, nil
+	return newRecord
 }
 
-var (
-	expDotFracNumber   = regexp.MustCompile(`^[-+]?[0-9]{0,3}((,[0-9]{3})*|[0-9]*)(\.|\.\d+)?$`)
-	expCommaFracNumber = regexp.MustCompile(`^[-+]?[0-9]{0,3}((\.[0-9]{3})*|[0-9]*)(,|,\d+)?$`)
-)
-
-func IsNumber(field string, srcSep NumberFormat) bool {
-	if field == "" || field == "." || field == "," {
-		return false
+func convertNumber(record []string, col Column, from, to NumberFormat) ([]string, error) {
+	if len(record) <= col.Index {
+		// unset field, keep the row as is
+		return record, nil
 	}
-	if srcSep == NumberDot {
-		return expDotFracNumber.MatchString(field)
+	val := Unquote(record[col.Index])
+	if val == "" {
+		// empty field, keep the row as is
+		return record, nil
 	}
-	if srcSep == NumberComma {
-		return expCommaFracNumber.MatchString(field)
+
+	repl, ok := ReformatNumber(val, from, to)
+	if !ok {
+		slog.Debug("invalid number", "number", record[col.Index])
+		return nil, &FieldError{Column: col, Output: col.Index, Value: record[col.Index], Err: ErrInvalidNumber}
 	}
-	return false
+	slog.Debug("converting number", "number", val, "from", from, "to", to)
+
+	return replaceField(record, col.Index, repl), nil
 }
 
-func ToFloat(field string) float64 {
-	if field == "" || field == "." || field == "," {
-		return 0
+// IsNumber reports whether the field is a number in the given format.
+func IsNumber(field string, format NumberFormat) bool {
+	spec, ok := format.spec()
+	if !ok {
+		return false
 	}
+	_, ok = spec.parse(field)
+	return ok
+}
 
+// plainNumber matches plain decimal numbers such as "-0.5", but not "NaN", "Inf", "0x10", or "1e3".
+var plainNumber = regexp.MustCompile(`^[-+]?(\d+\.?\d*|\.\d+)$`)
+
+// ParseNumber parses a number in the given format. Plain decimal numbers such as "0.5"
+// are also accepted if they are not valid in the given format.
+// Percent values are divided by 100, e.g., "12.5%" is 0.125.
+func ParseNumber(field string, format NumberFormat) (float64, bool) {
+	field = strings.TrimSpace(field)
+	if spec, ok := format.spec(); ok {
+		if n, ok := spec.parse(field); ok {
+			return spec.value(n), true
+		}
+	}
+	if !plainNumber.MatchString(field) {
+		return 0, false
+	}
 	n, err := strconv.ParseFloat(field, 64)
-	if err != nil {
-		panic("invalid number: " + field + ", error:" + err.Error())
+	return n, err == nil
+}
+
+// FormatNumber formats a number in the given format, without grouping unless
+// the format adds it. The number is rounded to 10 decimal places to hide
+// floating point artifacts.
+func FormatNumber(n float64, format NumberFormat) string {
+	spec, ok := format.spec()
+	if !ok {
+		spec, _ = NumberDot.spec()
+	}
+	if spec.percent {
+		n *= 100
 	}
-	return n
+	return spec.format(toDecimal(n))
 }
@@ -0,0 +1,328 @@
+package converters
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// numberLocales maps locale names to the canonical locale and its separators.
+var numberLocales = map[string]struct {
+	name           string
+	decimal, group rune
+}{
+	"dot":   {"dot", '.', ','},
+	".":     {"dot", '.', ','},
+	"en":    {"dot", '.', ','},
+	"us":    {"dot", '.', ','},
+	"comma": {"comma", ',', '.'},
+	",":     {"comma", ',', '.'},
+	"de":    {"comma", ',', '.'},
+	"eu":    {"comma", ',', '.'},
+	"ch":    {"ch", '.', '\''},
+	"fr":    {"fr", ',', ' '},
+}
+
+// currencies lists the supported currency codes and their symbols.
+var currencies = [][2]string{
+	{"eur", "€"},
+	{"usd", "$"},
+	{"gbp", "£"},
+	{"jpy", "¥"},
+	{"chf", "CHF"},
+}
+
+const (
+	groupKeep  = 0
+	groupAdd   = 1
+	groupStrip = -1
+)
+
+// numberSpec is the parsed form of a [NumberFormat].
+type numberSpec struct {
+	locale         string
+	decimal, group rune
+	grouping       int    // groupKeep, groupAdd, or groupStrip
+	decimals       int    // fixed number of decimals, or -1 to keep them
+	currency       string // currency code, e.g., "eur"
+	percent        bool
+	accounting     bool // negative numbers in parentheses
+	trailing       bool // negative numbers with trailing minus
+	core           *regexp.Regexp
+}
+
+var numberSpecs sync.Map // NumberFormat -> numberSpec
+
+// spec returns the parsed format, or false if the format is invalid.
+func (f NumberFormat) spec() (numberSpec, bool) {
+	if s, ok := numberSpecs.Load(f); ok {
+		return s.(numberSpec), true
+	}
+	s, err := parseNumberSpec(string(f))
+	if err != nil {
+		return s, false
+	}
+	numberSpecs.Store(f, s)
+	return s, true
+}
+
+func parseNumberSpec(s string) (numberSpec, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(s)), "+")
+	locale, ok := numberLocales[strings.TrimSpace(parts[0])]
+	if !ok {
+		return numberSpec{}, fmt.Errorf("%w: unknown locale %q", ErrInvalidNumberFormat, parts[0])
+	}
+	spec := numberSpec{locale: locale.name, decimal: locale.decimal, group: locale.group, decimals: -1}
+	for _, mod := range parts[1:] {
+		mod = strings.TrimSpace(mod)
+		if n, err := strconv.Atoi(mod); err == nil && n >= 0 && n < 10 {
+			spec.decimals = n
+			continue
+		}
+		switch mod {
+		case "group", "grouped":
+			spec.grouping = groupAdd
+		case "nogroup", "plain":
+			spec.grouping = groupStrip
+		case "pct", "percent", "%":
+			spec.percent = true
+		case "acct", "accounting":
+			spec.accounting = true
+		case "trail", "trailing", "-":
+			spec.trailing = true
+		default:
+			i := slices.IndexFunc(currencies, func(c [2]string) bool { return mod == c[0] || mod == strings.ToLower(c[1]) })
+			if i < 0 {
+				return numberSpec{}, fmt.Errorf("%w: unknown modifier %q", ErrInvalidNumberFormat, mod)
+			}
+			spec.currency = currencies[i][0]
+		}
+	}
+	spec.core = regexp.MustCompile(fmt.Sprintf(`^[0-9]{0,3}((%[1]s[0-9]{3})*|[0-9]*)(%[2]s|%[2]s[0-9]+)?$`,
+		regexp.QuoteMeta(string(spec.group)), regexp.QuoteMeta(string(spec.decimal))))
+	return spec, nil
+}
+
+// name returns the canonical name of the format, e.g., "comma+group+2".
+func (s numberSpec) name() string {
+	parts := []string{s.locale}
+	switch s.grouping {
+	case groupAdd:
+		parts = append(parts, "group")
+	case groupStrip:
+		parts = append(parts, "nogroup")
+	}
+	if s.decimals >= 0 {
+		parts = append(parts, strconv.Itoa(s.decimals))
+	}
+	if s.currency != "" {
+		parts = append(parts, s.currency)
+	}
+	if s.percent {
+		parts = append(parts, "pct")
+	}
+	if s.accounting {
+		parts = append(parts, "acct")
+	}
+	if s.trailing {
+		parts = append(parts, "trail")
+	}
+	return strings.Join(parts, "+")
+}
+
+// symbol returns the currency symbol, or an empty string.
+func (s numberSpec) symbol() string {
+	for _, c := range currencies {
+		if c[0] == s.currency {
+			return c[1]
+		}
+	}
+	return ""
+}
+
+// decimalNumber is a number split into its textual parts.
+type decimalNumber struct {
+	neg, plus bool   // sign
+	int, frac string // digits before and after the decimal separator
+	point     bool   // has a decimal separator
+	grouped   bool   // has thousands grouping
+}
+
+// parse splits a formatted number into its parts.
+// Decorations are optional, e.g., "12,50" is valid for "de+eur".
+func (s numberSpec) parse(field string) (decimalNumber, bool) {
+	n := decimalNumber{}
+	v := field
+	if s.accounting && len(v) > 2 && v[0] == '(' && v[len(v)-1] == ')' {
+		n.neg, v = true, strings.TrimSpace(v[1:len(v)-1])
+	}
+	if s.trailing && !n.neg && len(v) > 1 && v[len(v)-1] == '-' {
+		n.neg, v = true, strings.TrimSpace(v[:len(v)-1])
+	}
+	v = n.cutSign(v)
+	if sym := s.symbol(); sym != "" {
+		for _, affix := range []string{sym, strings.ToUpper(s.currency)} {
+			if c, ok := strings.CutPrefix(v, affix); ok {
+				v = strings.TrimSpace(c)
+				break
+			}
+			if c, ok := strings.CutSuffix(v, affix); ok {
+				v = strings.TrimSpace(c)
+				break
+			}
+		}
+		v = n.cutSign(v)
+	}
+	if s.percent {
+		if c, ok := strings.CutSuffix(v, "%"); ok {
+			v = strings.TrimRightFunc(c, unicode.IsSpace)
+		}
+	}
+	if !strings.ContainsAny(v, "0123456789") || !s.core.MatchString(v) {
+		return n, false
+	}
+	n.int, n.frac, n.point = strings.Cut(v, string(s.decimal))
+	n.grouped = strings.ContainsRune(n.int, s.group)
+	n.int = strings.ReplaceAll(n.int, string(s.group), "")
+	return n, true
+}
+
+// cutSign removes a leading sign, unless the number already has a sign.
+func (n *decimalNumber) cutSign(v string) string {
+	if n.neg || n.plus || v == "" {
+		return v
+	}
+	switch v[0] {
+	case '-':
+		n.neg = true
+	case '+':
+		n.plus = true
+	default:
+		return v
+	}
+	return strings.TrimSpace(v[1:])
+}
+
+// format joins the parts of a number in the format.
+func (s numberSpec) format(n decimalNumber) string {
+	if s.decimals >= 0 {
+		n = n.round(s.decimals)
+	}
+	v := n.int
+	if s.grouping == groupAdd || s.grouping == groupKeep && n.grouped {
+		v = groupDigits(v, s.group)
+	}
+	if n.point {
+		v += string(s.decimal) + n.frac
+	}
+	if s.percent {
+		v += "%"
+	}
+	switch sym := s.symbol(); {
+	case sym == "":
+	case s.decimal == ',':
+		v += " " + sym
+	case strings.EqualFold(sym, s.currency): // code, e.g., "CHF 12.50"
+		v = sym + " " + v
+	default:
+		v = sym + v
+	}
+	switch {
+	case !n.neg:
+		if n.plus {
+			v = "+" + v
+		}
+	case s.accounting:
+		v = "(" + v + ")"
+	case s.trailing:
+		v += "-"
+	default:
+		v = "-" + v
+	}
+	return v
+}
+
+// value returns the number, where percent values are divided by 100.
+func (s numberSpec) value(n decimalNumber) float64 {
+	f := n.float()
+	if s.percent {
+		f /= 100
+	}
+	return f
+}
+
+func (n decimalNumber) float() float64 {
+	f, err := strconv.ParseFloat(n.int+"."+n.frac, 64)
+	if err != nil {
+		return 0 // only empty parts, e.g., "0." is "0" and ".5" is "0.5"
+	}
+	if n.neg {
+		return -f
+	}
+	return f
+}
+
+// round rounds the number to the given number of decimals.
+func (n decimalNumber) round(decimals int) decimalNumber {
+	f := math.Abs(n.float())
+	n.int, n.frac, _ = strings.Cut(strconv.FormatFloat(f, 'f', decimals, 64), ".")
+	n.point = decimals > 0
+	n.neg = n.neg && strings.Trim(n.int+n.frac, "0") != ""
+	return n
+}
+
+// toDecimal converts a float to its parts, rounded to 10 decimal places
+// to hide floating point artifacts.
+func toDecimal(f float64) decimalNumber {
+	f = math.Round(f*1e10) / 1e10
+	n := decimalNumber{neg: f < 0}
+	n.int, n.frac, n.point = strings.Cut(strconv.FormatFloat(math.Abs(f), 'f', -1, 64), ".")
+	return n
+}
+
+// groupDigits inserts the group separator between every three digits.
+func groupDigits(digits string, sep rune) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+	b := strings.Builder{}
+	for i, r := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteRune(sep)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// ReformatNumber converts a number from one format to another.
+// It returns false if the value is not a number in the source format.
+func ReformatNumber(value string, from, to NumberFormat) (string, bool) {
+	src, ok := from.spec()
+	if !ok {
+		return "", false
+	}
+	dst, ok := to.spec()
+	if !ok {
+		return "", false
+	}
+	n, ok := src.parse(value)
+	if !ok {
+		return "", false
+	}
+	if src.percent != dst.percent {
+		f := src.value(n)
+		if dst.percent {
+			f *= 100
+		}
+		grouped, plus := n.grouped, n.plus
+		n = toDecimal(f)
+		n.grouped, n.plus = grouped, plus
+	}
+	return dst.format(n), true
+}
@@ -0,0 +1,172 @@
+package converters
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// pivotRow holds the key fields and the accumulators of one output row of a pivot.
+type pivotRow struct {
+	key  []string
+	accs map[string]*accumulator // by pivot column
+}
+
+// pivotRows implements the [RowConverter] for [PivotRows].
+type pivotRows struct {
+	keys      []string
+	by, value string
+	fn        string
+	format    NumberFormat
+	keyCols   []Column
+	byCol     int
+	valueCol  int
+	columns   []string // pivot columns in order of appearance
+	rows      map[string]*pivotRow
+	ordered   []*pivotRow // rows in order of appearance
+}
+
+// PivotRows returns a [RowConverter] that converts records from long to wide format.
+// It emits one record per set of key values, with one column per value of the by column,
+// which holds the aggregate of the value column, e.g., the sum of the amounts of a category.
+// Records and columns are emitted in order of their first appearance after the last record.
+// Numbers are aggregated using the given format, missing values are empty.
+func PivotRows(keys []string, by, value, fn string, format NumberFormat) RowConverter {
+	return &pivotRows{keys: keys, by: by, value: value, fn: strings.ToLower(fn), format: format}
+}
+
+// Pivot converts the records from long to wide format, see [PivotRows].
+func Pivot(records Records, keys []string, by, value, fn string, format NumberFormat) (Records, error) {
+	return ApplyRows(records, PivotRows(keys, by, value, fn, format))
+}
+
+func (p *pivotRows) Header(header []string) ([]string, error) {
+	if !IsAggregate(p.fn) {
+		return nil, fmt.Errorf("%w: unknown function %q", ErrInvalidAggregate, p.fn)
+	}
+	var err error
+	p.keyCols = []Column{}
+	if len(p.keys) > 0 {
+		if p.keyCols, err = ColumnIndex(header, Cols(p.keys...)...); err != nil {
+			return nil, err
+		}
+	}
+	cols, err := ColumnIndex(header, Col(p.by), Col(p.value))
+	if err != nil {
+		return nil, err
+	}
+	if len(cols) != 2 {
+		return nil, ErrInvalidColumnIndex
+	}
+	p.byCol, p.valueCol = cols[0].Index, cols[1].Index
+	p.columns, p.rows, p.ordered = nil, map[string]*pivotRow{}, nil
+	return nil, nil // the pivot columns are known after the last record
+}
+
+func (p *pivotRows) Row(record []string, emit Emit) error {
+	key := make([]string, len(p.keyCols))
+	for i, col := range p.keyCols {
+		key[i] = Field(record, col.Index)
+	}
+	id := strings.Join(key, "\x00")
+	row, ok := p.rows[id]
+	if !ok {
+		row = &pivotRow{key: key, accs: map[string]*accumulator{}}
+		p.rows[id] = row
+		p.ordered = append(p.ordered, row)
+	}
+
+	column := Field(record, p.byCol)
+	acc, ok := row.accs[column]
+	if !ok {
+		acc = &accumulator{}
+		row.accs[column] = acc
+		if !slices.Contains(p.columns, column) {
+			p.columns = append(p.columns, column)
+		}
+	}
+	if p.fn == "count" {
+		acc.count++
+		return nil
+	}
+	if err := acc.add(p.fn, Field(record, p.valueCol), p.format); err != nil {
+		return fmt.Errorf("%w: %s(%s): %w", ErrInvalidAggregate, p.fn, p.value, err)
+	}
+	return nil
+}
+
+func (p *pivotRows) Flush(emit Emit) error {
+	if p.rows == nil {
+		return nil // no input, not even a header
+	}
+	if err := emit(slices.Concat(ColNames(p.keyCols), p.columns)); err != nil {
+		return err
+	}
+	for _, row := range p.ordered {
+		record := slices.Clone(row.key)
+		for _, column := range p.columns {
+			value := ""
+			if acc, ok := row.accs[column]; ok {
+				value = acc.result(p.fn, p.format)
+			}
+			record = append(record, value)
+		}
+		if err := emit(record); err != nil {
+			return err
+		}
+	}
+	p.rows, p.ordered = nil, nil
+	return nil
+}
+
+// unpivotRows implements the [RowConverter] for [UnpivotRows].
+type unpivotRows struct {
+	columns     []Column
+	name, value string
+	keep, melt  []Column
+}
+
+// UnpivotRows returns a [RowConverter] that converts records from wide to long format.
+// It emits one record per input record and unpivoted column, with the other columns,
+// and the name and value of the unpivoted column in the name and value columns.
+func UnpivotRows(columns []Column, name, value string) RowConverter {
+	return &unpivotRows{columns: columns, name: name, value: value}
+}
+
+func (u *unpivotRows) Header(header []string) ([]string, error) {
+	melt, err := ColumnIndex(header, u.columns...)
+	if err != nil {
+		return nil, err
+	}
+	u.melt = uniqueColumns(melt)
+	u.keep = slices.DeleteFunc(Cols(header...), func(c Column) bool {
+		return slices.ContainsFunc(u.melt, func(m Column) bool { return m.Index == c.Index })
+	})
+	kept := selectFields(header, u.keep)
+	for _, name := range []string{u.name, u.value} {
+		if slices.Contains(kept, name) {
+			return nil, fmt.Errorf("%w: cannot unpivot into %q, the column is kept", ErrInvalidColumnIndex, name)
+		}
+	}
+	if u.name == u.value {
+		return nil, fmt.Errorf("%w: cannot unpivot names and values into %q", ErrInvalidColumnIndex, u.name)
+	}
+	return append(kept, u.name, u.value), nil
+}
+
+func (u *unpivotRows) Row(record []string, emit Emit) error {
+	fields := selectFields(record, u.keep)
+	for _, col := range u.melt {
+		if err := emit(append(slices.Clip(fields), col.Name, Field(record, col.Index))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (u *unpivotRows) Flush(emit Emit) error { return nil }
+
+// Unpivot converts the records from wide to long format, see [UnpivotRows].
+func Unpivot(records Records, columns []Column, name, value string) (Records, error) {
+	return ApplyRows(records, UnpivotRows(columns, name, value))
+}
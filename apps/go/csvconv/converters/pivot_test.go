@@ -0,0 +1,87 @@
+package converters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPivot(t *testing.T) {
+	long := Records{
+		{"date", "category", "amount"},
+		{"2024-01", "food", "1.200,50"},
+		{"2024-01", "rent", "800"},
+		{"2024-01", "food", "20"},
+		{"2024-02", "rent", "800"},
+		{"2024-02", "fun", ""},
+	}
+
+	got, err := Pivot(long, []string{"date"}, "category", "amount", "sum", NumberComma)
+	require.NoError(t, err)
+	require.Equal(t, Records{
+		{"date", "food", "rent", "fun"},
+		{"2024-01", "1220,5", "800", ""},
+		{"2024-02", "", "800", "0"},
+	}, got)
+
+	got, err = Pivot(long, nil, "category", "amount", "count", NumberComma)
+	require.NoError(t, err)
+	require.Equal(t, Records{{"food", "rent", "fun"}, {"2", "2", "1"}}, got)
+
+	got, err = Pivot(long, []string{"date"}, "category", "amount", "max", NumberComma)
+	require.NoError(t, err)
+	require.Equal(t, Records{
+		{"date", "food", "rent", "fun"},
+		{"2024-01", "1.200,50", "800", ""},
+		{"2024-02", "", "800", ""},
+	}, got)
+
+	_, err = Pivot(long, []string{"date"}, "category", "amount", "median", NumberComma)
+	require.ErrorIs(t, err, ErrInvalidAggregate)
+	_, err = Pivot(long, []string{"date"}, "kind", "amount", "sum", NumberComma)
+	require.ErrorIs(t, err, ErrColumnNotFound)
+	_, err = Pivot(long, []string{"date"}, "category", "amount", "sum", NumberDot)
+	require.ErrorIs(t, err, ErrInvalidAggregate)
+}
+
+func TestUnpivot(t *testing.T) {
+	wide := Records{
+		{"date", "food", "rent"},
+		{"2024-01", "1220.5", "800"},
+		{"2024-02", "", "800"},
+	}
+	long := Records{
+		{"date", "category", "amount"},
+		{"2024-01", "food", "1220.5"},
+		{"2024-01", "rent", "800"},
+		{"2024-02", "food", ""},
+		{"2024-02", "rent", "800"},
+	}
+
+	got, err := Unpivot(wide, Cols("food", "rent"), "category", "amount")
+	require.NoError(t, err)
+	require.Equal(t, long, got)
+
+	got, err = Unpivot(wide, []Column{{Name: "date", Exclude: true}}, "category", "amount")
+	require.NoError(t, err)
+	require.Equal(t, long, got)
+
+	got, err = Unpivot(wide, Cols("food", "rent", "food"), "category", "amount")
+	require.NoError(t, err)
+	require.Equal(t, long, got, "repeated columns are unpivoted once")
+
+	got, err = Pivot(long, []string{"date"}, "category", "amount", "sum", NumberDot)
+	require.NoError(t, err)
+	require.Equal(t, Records{{"date", "food", "rent"}, {"2024-01", "1220.5", "800"}, {"2024-02", "0", "800"}}, got)
+
+	named := Records{{"name", "amount", "date"}, {"Ada", "7", "2024-01"}}
+	got, err = Unpivot(named, Cols("amount", "date"), "field", "value")
+	require.NoError(t, err)
+	require.Equal(t, Records{{"name", "field", "value"}, {"Ada", "amount", "7"}, {"Ada", "date", "2024-01"}}, got)
+	_, err = Unpivot(named, Cols("amount", "date"), "name", "value")
+	require.ErrorIs(t, err, ErrInvalidColumnIndex, "name is kept")
+	_, err = Unpivot(named, Cols("amount"), "category", "date")
+	require.ErrorIs(t, err, ErrInvalidColumnIndex, "date is kept")
+	_, err = Unpivot(named, Cols("amount", "date"), "value", "value")
+	require.ErrorIs(t, err, ErrInvalidColumnIndex, "same into columns")
+}
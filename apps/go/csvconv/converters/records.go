@@ -1,6 +1,7 @@
 package converters
 
 import (
+	"cmp"
 	"slices"
 	"strings"
 )
@@ -69,11 +70,13 @@ func (r Records) Tail(numRows int) Records {
 		return r
 	}
 
-	return append(r.Header(), r[idx:]...)
+	// copy the rows, appending to the header would overwrite the input
+	return slices.Concat(r.Header(), r[idx:])
 }
 
 func (r Records) Count() int { return len(r.Data()) }
 
+// Concat appends the records as they are, see [ConcatRecords] for records with different headers.
 func (r Records) Concat(records Records) Records {
 	if len(r) == 0 {
 		return records
@@ -126,7 +129,7 @@ func (r Records) Sort(fn func(r Records, a, b []string) int) Records {
 		return r
 	}
 	result := Records{r[0]}
-	data := r.Data()
+	data := slices.Clone(r.Data())
 	slices.SortFunc(data, func(a, b []string) int { return fn(r, a, b) })
 	return append(result, data...)
 }
@@ -143,30 +146,22 @@ func CompareStringCol(r Records, column int, a, b []string) int {
 	return strings.Compare(va, vb)
 }
 
+// CompareNumberCol compares the numbers of a column. Invalid numbers
+// are sorted after valid numbers and compared as strings.
 func CompareNumberCol(r Records, column int, fmt NumberFormat, a, b []string) int {
-	va := 0.0
-	vb := 0.0
-	if len(a) > column {
-		v, ok := CleanNumber(a[column], fmt)
-		if !ok {
-			panic("invalid number")
-		}
-		va = ToFloat(v)
-	}
-	if len(b) > column {
-		v, ok := CleanNumber(b[column], fmt)
-		if !ok {
-			panic("invalid number")
-		}
-		vb = ToFloat(v)
-	}
-	if va < vb {
+	fa, fb := Field(a, column), Field(b, column)
+	va, okA := ParseNumber(fa, fmt)
+	vb, okB := ParseNumber(fb, fmt)
+	switch {
+	case okA && okB:
+		return cmp.Compare(va, vb)
+	case okA:
 		return -1
-	}
-	if va > vb {
+	case okB:
 		return 1
+	default:
+		return strings.Compare(fa, fb)
 	}
-	return 0
 }
 
 func (r Records) SortByString(column int, asc bool) Records {
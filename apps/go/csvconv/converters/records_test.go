@@ -35,7 +35,8 @@ func TestRecords(t *testing.T) {
 	require.Equal(t, r[:2], head)
 
 	tail := r.Tail(1)
-	require.Equal(t, append(r[:1], r[2:]...), tail)
+	require.Equal(t, Records{r[0], r[2]}, tail)
+	require.Equal(t, []string{"4.1", "5", "6"}, r[1], "input is not modified")
 
 	count := r.Count()
 	require.Equal(t, 2, count)
@@ -48,8 +49,12 @@ func TestRecords(t *testing.T) {
 	require.Equal(t, expected, sorted)
 
 	sorted = r.SortByNumber(1, false, NumberDot)
-	expected = Records{r.HeaderRow(), r[2], r[1]}
+	expected = Records{r.HeaderRow(), r[1], r[2]}
 	require.Equal(t, expected, sorted)
+
+	mixed := Records{{"n"}, {"n/a"}, {"1.000,5"}, {""}, {"-2"}}
+	sorted = mixed.SortByNumber(0, true, NumberComma)
+	require.Equal(t, Records{{"n"}, {"-2"}, {"1.000,5"}, {""}, {"n/a"}}, sorted, "invalid numbers go last")
 }
 
 func TestHeaderOnly(t *testing.T) {
@@ -0,0 +1,147 @@
+package converters
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// FieldError is a row error caused by the value of a single column.
+type FieldError struct {
+	Column Column // input column of the value
+	Output int    // index of the converted value in the output record
+	Value  string
+	Err    error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("column %q: %v", e.Column.Name, e.Err)
+}
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// Reject is a record that failed a statement, see [Rejects].
+type Reject struct {
+	Row       int      // input row number as shown in spreadsheets, 0 if unknown
+	Statement string   // name of the failed statement
+	Err       error    // reason of the rejection
+	Header    []string // header of the record
+	Record    []string // input record, or the record passed to the statement if the input row is unknown
+}
+
+// Rejects applies an [ErrorMode] to the row errors of row converters
+// and reports the failed records:
+//   - [ErrorFail] reports the record and stops with the error.
+//   - [ErrorSkip] reports and drops the record.
+//   - [ErrorKeep] and [ErrorBlank] report the record and retry it until all
+//     invalid values of [FieldError] errors are kept as is or replaced with empty fields.
+//     Records with other errors are dropped.
+//
+// Rejects track the input rows if [Rejects.Input] is the first stage of the pipeline.
+type Rejects struct {
+	Mode   ErrorMode
+	Report func(Reject) error // may be nil
+	Count  int                // number of reported errors
+
+	header []string
+	input  []string
+	row    int
+}
+
+// NewRejects returns [Rejects] for the error mode and report function.
+func NewRejects(mode ErrorMode, report func(Reject) error) *Rejects {
+	return &Rejects{Mode: mode, Report: report}
+}
+
+// Input returns a [RowConverter] that tracks the input rows and passes them on unchanged.
+// Rows emitted after the input, e.g., by the Flush of a buffering stage, have no input row.
+func (r *Rejects) Input() RowConverter {
+	return &inputRows{r}
+}
+
+type inputRows struct{ r *Rejects }
+
+func (in *inputRows) Header(header []string) ([]string, error) {
+	in.r.header, in.r.input, in.r.row = header, nil, 1
+	return header, nil
+}
+
+func (in *inputRows) Row(record []string, emit Emit) error {
+	in.r.input = record
+	in.r.row++
+	return emit(record)
+}
+
+func (in *inputRows) Flush(emit Emit) error {
+	in.r.input, in.r.row = nil, 0
+	return nil
+}
+
+// Wrap returns a [RowConverter] that handles the row errors of the converter.
+// The name identifies the converter in reports.
+func (r *Rejects) Wrap(name string, conv RowConverter) RowConverter {
+	return &rejectRows{r: r, name: name, conv: conv}
+}
+
+type rejectRows struct {
+	r      *Rejects
+	name   string
+	conv   RowConverter
+	header []string
+}
+
+func (s *rejectRows) Header(header []string) ([]string, error) {
+	s.header = header
+	return s.conv.Header(header)
+}
+
+func (s *rejectRows) Flush(emit Emit) error { return s.conv.Flush(emit) }
+
+func (s *rejectRows) Row(record []string, emit Emit) error {
+	var downstream error     // errors of later stages are passed on as is
+	kept := map[int]string{} // invalid values by output index, for the keep mode
+	next := func(result []string) error {
+		for i, value := range kept {
+			if i < len(result) {
+				result = replaceField(result, i, value)
+			}
+		}
+		downstream = emit(result)
+		return downstream
+	}
+
+	input := record
+	for {
+		err := s.conv.Row(input, next)
+		if err == nil || downstream != nil || errors.Is(err, ErrStop) {
+			return err
+		}
+		if err := s.reject(record, err); err != nil {
+			return err
+		}
+		var fieldErr *FieldError
+		switch {
+		case s.r.Mode == ErrorFail:
+			return err
+		case s.r.Mode == ErrorSkip || !errors.As(err, &fieldErr) || Field(input, fieldErr.Column.Index) == "":
+			return nil
+		}
+		// retry with an empty field, each retry empties another field
+		input = replaceField(input, fieldErr.Column.Index, "")
+		if s.r.Mode == ErrorKeep {
+			kept[fieldErr.Output] = fieldErr.Value
+		}
+	}
+}
+
+func (s *rejectRows) reject(record []string, err error) error {
+	s.r.Count++
+	if s.r.Report == nil {
+		return nil
+	}
+	reject := Reject{Row: s.r.row, Statement: s.name, Err: err, Header: s.r.header, Record: s.r.input}
+	if reject.Record == nil {
+		reject.Header, reject.Record = s.header, slices.Clone(record)
+	}
+	return s.r.Report(reject)
+}
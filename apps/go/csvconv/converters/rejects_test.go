@@ -0,0 +1,65 @@
+package converters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRejects(t *testing.T) {
+	input := Records{
+		{"id", "amount", "day"},
+		{"1", "1.5", "24.12.2023"},
+		{"2", "n/a", "soon"},
+		{"3", "", "n/a"},
+	}
+	tests := []struct {
+		mode    ErrorMode
+		want    Records
+		rejects []int
+	}{
+		{ErrorSkip, Records{{"id", "amount", "day"}, {"1", "1,5", "2023-12-24"}}, []int{3, 4}},
+		{ErrorBlank, Records{{"id", "amount", "day"}, {"1", "1,5", "2023-12-24"}, {"2", "", ""}, {"3", "", ""}}, []int{3, 3, 4}},
+		{ErrorKeep, Records{{"id", "amount", "day"}, {"1", "1,5", "2023-12-24"}, {"2", "n/a", "soon"}, {"3", "", "n/a"}}, []int{3, 3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			rows := []int{}
+			rejects := NewRejects(tt.mode, func(r Reject) error {
+				require.Equal(t, input[0], r.Header)
+				require.Equal(t, input[r.Row-1], r.Record)
+				rows = append(rows, r.Row)
+				return nil
+			})
+			got, err := ApplyRows(input,
+				rejects.Input(),
+				rejects.Wrap("number", NumberRows("amount", NumberDot, NumberComma)),
+				rejects.Wrap("date", DateRows("day", DateDot, DateISO)),
+			)
+			require.NoError(t, err)
+			require.Equal(t, tt.want, got)
+			require.Equal(t, tt.rejects, rows)
+			require.Equal(t, len(tt.rejects), rejects.Count)
+		})
+	}
+
+	rejects := NewRejects(ErrorFail, nil)
+	_, err := ApplyRows(input, rejects.Input(), rejects.Wrap("number", NumberRows("amount", NumberDot, NumberComma)))
+	require.ErrorIs(t, err, ErrInvalidNumber)
+	var fieldErr *FieldError
+	require.ErrorAs(t, err, &fieldErr)
+	require.Equal(t, "n/a", fieldErr.Value)
+}
+
+func TestRejectsSelect(t *testing.T) {
+	input := Records{{"a", "b"}, {"x", "1"}, {"2", "y"}}
+	rejects := NewRejects(ErrorKeep, nil)
+	got, err := ApplyRows(input, rejects.Wrap("select", SelectRows([]Column{{Name: "b", Type: "int"}, {Name: "a", Type: "int"}})))
+	require.NoError(t, err)
+	require.Equal(t, Records{{"b", "a"}, {"1", "x"}, {"y", "2"}}, got)
+	require.Equal(t, 2, rejects.Count)
+
+	got, err = ApplyRows(input, SelectCastRows([]Column{{Name: "b", Type: "int"}, {Name: "a", Type: "int"}}, ErrorSkip))
+	require.NoError(t, err)
+	require.Equal(t, Records{{"b", "a"}}, got)
+}
@@ -0,0 +1,195 @@
+package converters
+
+import (
+	"errors"
+	"slices"
+)
+
+// Emit passes a record to the next step of a row pipeline.
+type Emit func(record []string) error
+
+// ErrStop is returned by a [RowConverter] that does not need any more records.
+// It is passed up the pipeline so that readers can stop reading early.
+var ErrStop = errors.New("stop reading records")
+
+// RowConverter converts records one at a time.
+// Row converters are stateful and must not be reused for multiple tables.
+type RowConverter interface {
+	// Header is called once with the input header, before any record.
+	// It returns the output header, or nil if the header is only known after Flush.
+	Header(header []string) ([]string, error)
+
+	// Row converts a single record and emits zero or more records.
+	Row(record []string, emit Emit) error
+
+	// Flush is called after the last record and emits all buffered records.
+	// A converter that returned a nil header must emit the header first.
+	Flush(emit Emit) error
+}
+
+// MapRows is a [RowConverter] that maps each record to at most one record.
+// HeaderFunc may be nil to keep the header. RowFunc returns a nil record to drop it.
+type MapRows struct {
+	HeaderFunc func(header []string) ([]string, error)
+	RowFunc    func(record []string) ([]string, error)
+}
+
+func (m *MapRows) Header(header []string) ([]string, error) {
+	if m.HeaderFunc == nil {
+		return header, nil
+	}
+	return m.HeaderFunc(header)
+}
+
+func (m *MapRows) Row(record []string, emit Emit) error {
+	result, err := m.RowFunc(record)
+	if err != nil || result == nil {
+		return err
+	}
+	return emit(result)
+}
+
+func (m *MapRows) Flush(emit Emit) error { return nil }
+
+// bufferRows adapts a whole-table [Converter] to the [RowConverter] interface.
+type bufferRows struct {
+	conv    Converter
+	records Records
+}
+
+// Buffer returns a [RowConverter] that buffers all records and applies
+// the converter to the full table on Flush.
+func Buffer(conv Converter) RowConverter {
+	return &bufferRows{conv: conv}
+}
+
+func (b *bufferRows) Header(header []string) ([]string, error) {
+	b.records = Records{header}
+	return nil, nil // header is emitted on Flush
+}
+
+func (b *bufferRows) Row(record []string, emit Emit) error {
+	b.records = append(b.records, record)
+	return nil
+}
+
+func (b *bufferRows) Flush(emit Emit) error {
+	if b.records == nil {
+		return nil // no input, not even a header
+	}
+	records, err := b.conv(b.records)
+	b.records = nil
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		if err := emit(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Pipeline chains row converters. Records emitted by a stage are passed to the next stage.
+// The first record passed to a stage is its header; the last stage emits into the sink.
+type Pipeline struct {
+	stages  []RowConverter
+	emits   []Emit // emits[i] passes a record to stage i
+	started []bool // started[i] is true after stage i received its header
+	stopped bool
+}
+
+// NewPipeline creates a pipeline of stages that emits its results into the sink.
+func NewPipeline(sink Emit, stages ...RowConverter) *Pipeline {
+	p := &Pipeline{
+		stages:  stages,
+		emits:   make([]Emit, len(stages)+1),
+		started: make([]bool, len(stages)),
+	}
+	p.emits[len(stages)] = sink
+	for i := len(stages) - 1; i >= 0; i-- {
+		p.emits[i] = p.input(i)
+	}
+	return p
+}
+
+func (p *Pipeline) input(i int) Emit {
+	stage, next := p.stages[i], p.emits[i+1]
+	return func(record []string) error {
+		if p.started[i] {
+			return stage.Row(record, next)
+		}
+		p.started[i] = true
+		header, err := stage.Header(record)
+		if err != nil || header == nil {
+			return err
+		}
+		return next(header)
+	}
+}
+
+// Push passes the next record into the pipeline; the first record must be the header.
+// It returns [ErrStop] once the pipeline does not accept more records.
+func (p *Pipeline) Push(record []string) error {
+	if p.stopped {
+		return ErrStop
+	}
+	err := p.emits[0](record)
+	if errors.Is(err, ErrStop) {
+		p.stopped = true
+	}
+	return err
+}
+
+// Flush flushes all stages in order, so that buffered records of a stage
+// are passed through all downstream stages before they are flushed.
+func (p *Pipeline) Flush() error {
+	for i, stage := range p.stages {
+		err := stage.Flush(p.emits[i+1])
+		if err != nil && !errors.Is(err, ErrStop) {
+			return err
+		}
+	}
+	return nil
+}
+
+// ApplyRows runs the records through a pipeline of row converters.
+func ApplyRows(records Records, stages ...RowConverter) (Records, error) {
+	if len(records) == 0 {
+		return records, nil
+	}
+	result := Records{}
+	p := NewPipeline(func(record []string) error {
+		result = append(result, record)
+		return nil
+	}, stages...)
+
+	for _, record := range records {
+		err := p.Push(record)
+		if errors.Is(err, ErrStop) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := p.Flush(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Field returns the field at the given index or an empty string for short records.
+func Field(record []string, idx int) string {
+	if idx < len(record) {
+		return record[idx]
+	}
+	return ""
+}
+
+// replaceField returns a copy of the record with the field at idx replaced.
+func replaceField(record []string, idx int, value string) []string {
+	result := slices.Clone(record)
+	result[idx] = value
+	return result
+}
@@ -0,0 +1,65 @@
+package converters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// limitRows emits at most n records and then stops the pipeline.
+type limitRows struct {
+	MapRows
+	n int
+}
+
+func (l *limitRows) Row(record []string, emit Emit) error {
+	if l.n <= 0 {
+		return ErrStop
+	}
+	l.n--
+	return emit(record)
+}
+
+func TestApplyRows(t *testing.T) {
+	records := Records{
+		{"name", "age"},
+		{"Bob", "30"},
+		{"Alice", "25"},
+		{"Charlie", "35"},
+	}
+	sortByName := func(r Records) (Records, error) { return Sort(r, "name", true, NumberInvalid) }
+
+	got, err := ApplyRows(records,
+		FilterRows("age", ">", "26"),
+		Buffer(sortByName),
+		SelectRows([]Column{{Name: "name", Rename: "n"}}),
+	)
+	require.NoError(t, err)
+	require.Equal(t, Records{{"n"}, {"Bob"}, {"Charlie"}}, got)
+
+	got, err = ApplyRows(records, &limitRows{n: 1}, SelectRows(Cols("age")))
+	require.NoError(t, err)
+	require.Equal(t, Records{{"age"}, {"30"}}, got)
+
+	got, err = ApplyRows(records, Buffer(sortByName), &limitRows{n: 2})
+	require.NoError(t, err)
+	require.Equal(t, Records{{"name", "age"}, {"Alice", "25"}, {"Bob", "30"}}, got)
+
+	_, err = ApplyRows(records, SelectRows(Cols("unknown")))
+	require.ErrorIs(t, err, ErrColumnNotFound)
+}
+
+func TestPipelineStop(t *testing.T) {
+	result := Records{}
+	p := NewPipeline(func(record []string) error {
+		result = append(result, record)
+		return nil
+	}, &limitRows{n: 1})
+
+	require.NoError(t, p.Push([]string{"a"}))
+	require.NoError(t, p.Push([]string{"1"}))
+	require.ErrorIs(t, p.Push([]string{"2"}), ErrStop)
+	require.ErrorIs(t, p.Push([]string{"3"}), ErrStop)
+	require.NoError(t, p.Flush())
+	require.Equal(t, Records{{"a"}, {"1"}}, result)
+}
@@ -0,0 +1,253 @@
+package converters
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidType    = errors.New("invalid type")
+	ErrInvalidValue   = errors.New("invalid value")
+	ErrSchemaMismatch = errors.New("schema mismatch")
+)
+
+// ValueType is the type of the values of a column.
+type ValueType string
+
+const (
+	TypeString ValueType = "string"
+	TypeInt    ValueType = "int"
+	TypeFloat  ValueType = "float" // number in dot or comma format
+	TypeDate   ValueType = "date"  // date in a [DateFormat]
+	TypeBool   ValueType = "bool"  // true, false, yes, or no
+)
+
+func ParseValueType(s string) (ValueType, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "string", "str", "text":
+		return TypeString, nil
+	case "int", "integer":
+		return TypeInt, nil
+	case "float", "num", "number", "decimal":
+		return TypeFloat, nil
+	case "date", "time", "datetime":
+		return TypeDate, nil
+	case "bool", "boolean":
+		return TypeBool, nil
+	default:
+		return TypeString, fmt.Errorf("%w: %q", ErrInvalidType, s)
+	}
+}
+
+var boolValues = []string{"true", "false", "yes", "no"}
+
+// ColumnSchema describes the values of a column.
+type ColumnSchema struct {
+	Name     string    `json:"name"`
+	Type     ValueType `json:"type"`
+	Format   string    `json:"format,omitempty"` // number format of floats or date format of dates
+	Nullable bool      `json:"nullable,omitempty"`
+}
+
+// Check returns [ErrInvalidValue] if the value does not match the column schema.
+func (c ColumnSchema) Check(value string) error {
+	if value == "" {
+		if c.Nullable {
+			return nil
+		}
+		return fmt.Errorf("%w: empty value", ErrInvalidValue)
+	}
+	ok := true
+	switch c.Type {
+	case TypeInt:
+		_, err := strconv.ParseInt(value, 10, 64)
+		ok = err == nil
+	case TypeFloat:
+		ok = IsNumber(value, NumberFormat(c.Format))
+	case TypeDate:
+		_, err := ParseDate(value, DateFormat(c.Format))
+		ok = err == nil
+	case TypeBool:
+		ok = slices.Contains(boolValues, strings.ToLower(value))
+	}
+	if !ok {
+		return fmt.Errorf("%w: %q is not %s", ErrInvalidValue, value, c.describe())
+	}
+	return nil
+}
+
+func (c ColumnSchema) describe() string {
+	if c.Format != "" {
+		return fmt.Sprintf("%s (%s)", c.Type, c.Format)
+	}
+	return string(c.Type)
+}
+
+// Schema describes the columns of a table.
+type Schema struct {
+	Columns []ColumnSchema `json:"columns"`
+}
+
+// inferCandidates are the column types tried by [InferSchema], in order of preference.
+var inferCandidates = []ColumnSchema{
+	{Type: TypeBool},
+	{Type: TypeInt},
+	{Type: TypeFloat, Format: string(NumberDot)},
+	{Type: TypeFloat, Format: string(NumberComma)},
+	{Type: TypeDate, Format: string(DateISO)},
+	{Type: TypeDate, Format: string(DateRFC3339)},
+	{Type: TypeDate, Format: string(DateTime)},
+	{Type: TypeDate, Format: string(DateDot)},
+	{Type: TypeDate, Format: string(DateDot2)},
+	{Type: TypeDate, Format: string(DateSlash)},
+	{Type: TypeDate, Format: string(DateUS)},
+	{Type: TypeDate, Format: string(DateSlash2)},
+	{Type: TypeDate, Format: string(DateUS2)},
+}
+
+// InferSchema infers the schema of the records. Each column gets the first type
+// of bool, int, float, and date that matches all its values, or string.
+// Columns with empty values are nullable.
+func InferSchema(records Records) *Schema {
+	header := records.HeaderRow()
+	schema := &Schema{Columns: make([]ColumnSchema, len(header))}
+	for i, name := range header {
+		candidates := slices.Clone(inferCandidates)
+		col := ColumnSchema{Name: name, Type: TypeString}
+		values := 0
+		for _, record := range records.Data() {
+			value := Field(record, i)
+			if value == "" {
+				col.Nullable = true
+				continue
+			}
+			values++
+			candidates = slices.DeleteFunc(candidates, func(c ColumnSchema) bool { return c.Check(value) != nil })
+		}
+		if values > 0 && len(candidates) > 0 {
+			col.Type, col.Format = candidates[0].Type, candidates[0].Format
+		}
+		schema.Columns[i] = col
+	}
+	return schema
+}
+
+// ParseSchema parses a JSON schema and checks its types and formats.
+func ParseSchema(data []byte) (*Schema, error) {
+	schema := &Schema{}
+	if err := json.Unmarshal(data, schema); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidType, err)
+	}
+	for i, col := range schema.Columns {
+		typ, err := ParseValueType(string(col.Type))
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %w", col.Name, err)
+		}
+		switch schema.Columns[i].Type = typ; typ {
+		case TypeFloat:
+			format, err := ParseNumberFormat(cmp.Or(col.Format, string(NumberDot)))
+			if err != nil {
+				return nil, fmt.Errorf("column %q: %w", col.Name, err)
+			}
+			schema.Columns[i].Format = string(format)
+		case TypeDate:
+			format, err := ParseDateFormat(cmp.Or(col.Format, "any"))
+			if err != nil {
+				return nil, fmt.Errorf("column %q: %w", col.Name, err)
+			}
+			schema.Columns[i].Format = string(format)
+		}
+	}
+	return schema, nil
+}
+
+// Records returns the schema as a table of names, types, formats, and nullability.
+func (s *Schema) Records() Records {
+	records := Records{{"name", "type", "format", "nullable"}}
+	for _, col := range s.Columns {
+		records = append(records, []string{col.Name, string(col.Type), col.Format, strconv.FormatBool(col.Nullable)})
+	}
+	return records
+}
+
+// CheckHeader returns [ErrSchemaMismatch] if the header does not have
+// the columns of the schema in the same order.
+func (s *Schema) CheckHeader(header []string) error {
+	names := make([]string, len(s.Columns))
+	for i, col := range s.Columns {
+		names[i] = col.Name
+	}
+	if slices.Equal(names, header) {
+		return nil
+	}
+	missing := slices.DeleteFunc(slices.Clone(names), func(name string) bool { return slices.Contains(header, name) })
+	unexpected := slices.DeleteFunc(slices.Clone(header), func(name string) bool { return slices.Contains(names, name) })
+	if len(missing) == 0 && len(unexpected) == 0 {
+		return fmt.Errorf("%w: columns %q are in a different order, expected %q", ErrSchemaMismatch, header, names)
+	}
+	return fmt.Errorf("%w: missing columns %q, unexpected columns %q", ErrSchemaMismatch, missing, unexpected)
+}
+
+// RowError is an invalid value found by [ValidateRows].
+type RowError struct {
+	Row    int // row number as shown in spreadsheets, the header is row 1
+	Column string
+	Err    error
+}
+
+func (e RowError) Error() string {
+	return fmt.Sprintf("row %d, column %q: %v", e.Row, e.Column, e.Err)
+}
+
+func (e RowError) Unwrap() error { return e.Err }
+
+// validateRows implements the [RowConverter] for [ValidateRows].
+type validateRows struct {
+	schema *Schema
+	report func(RowError) error
+	row    int
+	errors int
+}
+
+// ValidateRows returns a [RowConverter] that checks the header and values against
+// the schema and passes the records on unchanged. An invalid header fails immediately.
+// Invalid values are passed to report, which may return an error to stop validation.
+// Flush returns [ErrSchemaMismatch] if any value was invalid.
+func ValidateRows(schema *Schema, report func(RowError) error) RowConverter {
+	return &validateRows{schema: schema, report: report}
+}
+
+func (v *validateRows) Header(header []string) ([]string, error) {
+	v.row, v.errors = 1, 0
+	if err := v.schema.CheckHeader(header); err != nil {
+		return nil, err
+	}
+	return header, nil
+}
+
+func (v *validateRows) Row(record []string, emit Emit) error {
+	v.row++
+	for i, col := range v.schema.Columns {
+		err := col.Check(Field(record, i))
+		if err == nil {
+			continue
+		}
+		v.errors++
+		if err := v.report(RowError{Row: v.row, Column: col.Name, Err: err}); err != nil {
+			return err
+		}
+	}
+	return emit(record)
+}
+
+func (v *validateRows) Flush(emit Emit) error {
+	if v.errors > 0 {
+		return fmt.Errorf("%w: %d invalid values", ErrSchemaMismatch, v.errors)
+	}
+	return nil
+}
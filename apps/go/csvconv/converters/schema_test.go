@@ -0,0 +1,44 @@
+package converters
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInferSchema(t *testing.T) {
+	records := Records{
+		{"id", "price", "rate", "day", "when", "active", "empty", "name"},
+		{"1", "1,234.50", "0,5", "12/24/2023", "2023-12-24T10:00:00Z", "true", "", "a"},
+		{"-2", "7", "", "01/02/2024", "2023-12-25T10:00:00+01:00", "No", "", "1"},
+	}
+	require.Equal(t, &Schema{Columns: []ColumnSchema{
+		{Name: "id", Type: TypeInt},
+		{Name: "price", Type: TypeFloat, Format: "dot"},
+		{Name: "rate", Type: TypeFloat, Format: "comma", Nullable: true},
+		{Name: "day", Type: TypeDate, Format: "mm/dd/yyyy"},
+		{Name: "when", Type: TypeDate, Format: "rfc3339"},
+		{Name: "active", Type: TypeBool},
+		{Name: "empty", Type: TypeString, Nullable: true},
+		{Name: "name", Type: TypeString},
+	}}, InferSchema(records))
+}
+
+func TestParseSchema(t *testing.T) {
+	schema, err := ParseSchema([]byte(`{"columns": [
+		{"name": "a", "type": "num"},
+		{"name": "b", "type": "date", "format": "dot"},
+		{"name": "c", "type": "integer", "nullable": true}
+	]}`))
+	require.NoError(t, err)
+	require.Equal(t, []ColumnSchema{
+		{Name: "a", Type: TypeFloat, Format: "dot"},
+		{Name: "b", Type: TypeDate, Format: "dd.mm.yyyy"},
+		{Name: "c", Type: TypeInt, Nullable: true},
+	}, schema.Columns)
+
+	_, err = ParseSchema([]byte(`{"columns": [{"name": "a", "type": "money"}]}`))
+	require.ErrorIs(t, err, ErrInvalidType)
+	_, err = ParseSchema([]byte(`{"columns": [{"name": "a", "type": "date", "format": "soon"}]}`))
+	require.ErrorIs(t, err, ErrInvalidDateFormat)
+}
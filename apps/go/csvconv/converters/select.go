@@ -2,8 +2,8 @@ package converters
 
 import (
 	"errors"
+	"fmt"
 	"log/slog"
-	"slices"
 )
 
 var ErrColumnNotFound = errors.New("column not found")
@@ -12,45 +12,71 @@ func SelectColumns(records Records, columns []Column) (Records, error) {
 	if len(columns) == 0 {
 		return records, nil
 	}
-
-	indices, err := ColumnIndex(records[0], columns...)
-	if err != nil {
-		return nil, err
-	}
-
-	slog.Debug("Selecting columns", "columns", columns, "indices", indices)
-
-	return selectAndRename(records, indices)
+	return ApplyRows(records, SelectRows(columns))
 }
 
-func selectAndRename(records Records, columns []Column) (Records, error) {
-	result := make(Records, len(records))
+// SelectRows returns a [RowConverter] that selects, renames, and casts the given columns.
+// It fails on the first value that cannot be cast, see [SelectCastRows].
+func SelectRows(columns []Column) RowConverter {
+	return SelectCastRows(columns, ErrorFail)
+}
 
-	for i, record := range records {
-		newRecord := make([]string, len(columns))
-		for j, col := range columns {
-			if col.Index >= len(record) {
-				continue
+// SelectCastRows returns a [RowConverter] that selects and renames the given columns,
+// and casts their values using the type and cast of each column, see [ParseCast].
+// Values that cannot be cast are handled according to onError.
+func SelectCastRows(columns []Column, onError ErrorMode) RowConverter {
+	var indices []Column
+	var casts []Caster
+	return &MapRows{
+		HeaderFunc: func(header []string) ([]string, error) {
+			if len(columns) == 0 {
+				indices = Cols(header...)
+				casts = make([]Caster, len(indices))
+				return header, nil
 			}
-			newRecord[j] = record[col.Index]
-		}
-		result[i] = newRecord
+			var err error
+			indices, err = ColumnIndex(header, columns...)
+			if err != nil {
+				return nil, err
+			}
+			casts = make([]Caster, len(indices))
+			for i, col := range indices {
+				if casts[i], err = ParseCast(col.Type, col.Cast); err != nil {
+					return nil, fmt.Errorf("column %q: %w", col.Name, err)
+				}
+			}
+			slog.Debug("Selecting columns", "columns", columns, "indices", indices)
+			return renameHeader(selectFields(header, indices), indices), nil
+		},
+		RowFunc: func(record []string) ([]string, error) {
+			result := selectFields(record, indices)
+			for i, cast := range casts {
+				var err error
+				if result[i], err = castField(result[i], indices[i], i, cast, onError); err != nil {
+					if onError == ErrorSkip {
+						return nil, nil
+					}
+					return nil, err
+				}
+			}
+			return result, nil
+		},
 	}
-	return renameHeader(result, columns)
 }
 
-func renameHeader(records Records, cols []Column) (Records, error) {
-	if len(cols) == 0 {
-		return records, nil
+func selectFields(record []string, columns []Column) []string {
+	result := make([]string, len(columns))
+	for i, col := range columns {
+		result[i] = Field(record, col.Index)
 	}
-	header := make([]string, len(records[0]))
-	for i := range len(header) {
-		rename := cols[i].Rename
-		if rename != "" {
-			header[i] = rename
-		} else {
-			header[i] = records[0][i]
+	return result
+}
+
+func renameHeader(header []string, cols []Column) []string {
+	for i, col := range cols {
+		if col.Rename != "" {
+			header[i] = col.Rename
 		}
 	}
-	return slices.Concat(Records{header}, records[1:]), nil
+	return header
 }
@@ -39,3 +39,38 @@ func TestSelect(t *testing.T) {
 		})
 	}
 }
+
+func TestSelectCast(t *testing.T) {
+	records := Records{
+		{"amount", "booked", "id", "ok"},
+		{"1.234,50", "24.12.2023", "7", "yes"},
+		{"-3", "12/24/2023", "8,0", "0"},
+		{"n/a", "01/02/2024", "x", ""},
+	}
+	columns := []Column{
+		{Name: "amount", Type: "num", Cast: "comma:dot"},
+		{Name: "booked", Type: "date", Cast: "iso"},
+		{Name: "id", Cast: "int"},
+		{Name: "ok", Type: "bool", Rename: "valid"},
+	}
+
+	got, err := ApplyRows(records, SelectCastRows(columns, ErrorBlank))
+	require.NoError(t, err)
+	require.Equal(t, Records{
+		{"amount", "booked", "id", "valid"},
+		{"1,234.50", "2023-12-24", "7", "true"},
+		{"-3", "2023-12-24", "", "false"},
+		{"", "", "", ""},
+	}, got)
+
+	got, err = ApplyRows(records, SelectCastRows(columns, ErrorKeep))
+	require.NoError(t, err)
+	require.Equal(t, []string{"n/a", "01/02/2024", "x", ""}, got[3])
+
+	_, err = ApplyRows(records, SelectRows(columns))
+	require.ErrorIs(t, err, ErrInvalidNumber)
+	require.ErrorContains(t, err, `column "id"`)
+
+	_, err = ApplyRows(records, SelectRows([]Column{{Name: "id", Type: "num", Cast: "euro"}}))
+	require.ErrorIs(t, err, ErrInvalidCast)
+}
@@ -1,12 +1,395 @@
 package converters
 
+import (
+	"bufio"
+	"cmp"
+	"container/heap"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSortKey = errors.New("invalid sort key")
+
+// DefaultSortMemory is the default memory budget of [SortRows] in bytes.
+const DefaultSortMemory = 256 << 20
+
+// SortKind defines how the values of a [SortKey] are compared.
+type SortKind string
+
+const (
+	SortString  SortKind = ""        // lexical order
+	SortNumber  SortKind = "num"     // numbers in a [NumberFormat]
+	SortDate    SortKind = "date"    // dates in a [DateFormat]
+	SortNatural SortKind = "natural" // numbers in strings in numerical order, e.g., "a2" before "a10"
+)
+
+// SortKey is a column to sort by. Empty values and values that are invalid
+// for the kind are nulls, which are sorted last unless NullsFirst is set.
+type SortKey struct {
+	Column     string
+	Desc       bool
+	Kind       SortKind
+	Number     NumberFormat // format of number keys, dot if empty
+	Date       DateFormat   // format of date keys
+	NullsFirst bool
+}
+
+// SetType sets the kind and format of the key from a type such as "num",
+// "num:comma", "date:%d.%m.%Y", or "natural". The kind is inferred from
+// a format without type, e.g., "comma" is a number and "iso" a date format.
+func (k *SortKey) SetType(typ string) error {
+	kind, format, _ := strings.Cut(typ, ":")
+	var err error
+	switch strings.ToLower(strings.TrimSpace(kind)) {
+	case "", "string", "str", "text":
+		k.Kind = SortString
+	case "natural", "nat":
+		k.Kind = SortNatural
+	case "num", "number", "float", "int", "integer", "decimal":
+		k.Kind = SortNumber
+		k.Number, err = ParseNumberFormat(cmp.Or(format, string(NumberDot)))
+		format = ""
+	case "date", "time", "datetime":
+		k.Kind = SortDate
+		k.Date, err = ParseDateFormat(cmp.Or(format, "any"))
+		format = ""
+	default:
+		if k.Number, err = ParseNumberFormat(typ); err == nil {
+			k.Kind = SortNumber
+		} else if k.Date, err = ParseDateFormat(typ); err == nil {
+			k.Kind = SortDate
+		} else {
+			return fmt.Errorf("%w: unknown type %q", ErrInvalidSortKey, typ)
+		}
+		format = ""
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSortKey, err)
+	}
+	if format != "" {
+		return fmt.Errorf("%w: %s has no format %q", ErrInvalidSortKey, kind, format)
+	}
+	return nil
+}
+
+// sortValue is the parsed value of a sort key.
+type sortValue struct {
+	null bool
+	num  float64
+	time time.Time
+	str  string
+}
+
+func (k SortKey) value(s string) sortValue {
+	v := sortValue{null: s == "", str: s}
+	switch {
+	case v.null:
+	case k.Kind == SortNumber:
+		n, ok := ParseNumber(s, cmp.Or(k.Number, NumberDot))
+		v.num, v.null = n, !ok
+	case k.Kind == SortDate:
+		t, err := ParseDate(s, k.Date) // ambiguous dates are nulls
+		v.time, v.null = t, err != nil
+	}
+	return v
+}
+
+func (k SortKey) compare(a, b sortValue) int {
+	switch {
+	case a.null && b.null:
+		return 0
+	case a.null != b.null:
+		if a.null == k.NullsFirst {
+			return -1
+		}
+		return 1
+	}
+	var c int
+	switch k.Kind {
+	case SortNumber:
+		c = cmp.Compare(a.num, b.num)
+	case SortDate:
+		c = a.time.Compare(b.time)
+	case SortNatural:
+		c = compareNatural(a.str, b.str)
+	default:
+		c = strings.Compare(a.str, b.str)
+	}
+	if k.Desc {
+		return -c
+	}
+	return c
+}
+
+// compareNatural compares strings with runs of digits compared as numbers.
+func compareNatural(a, b string) int {
+	for a != "" && b != "" {
+		ra, rb := cutRun(a), cutRun(b)
+		if isDigit(ra[0]) && isDigit(rb[0]) {
+			na, nb := strings.TrimLeft(ra, "0"), strings.TrimLeft(rb, "0")
+			if c := cmp.Or(cmp.Compare(len(na), len(nb)), strings.Compare(na, nb)); c != 0 {
+				return c
+			}
+		} else if c := strings.Compare(ra, rb); c != 0 {
+			return c
+		}
+		a, b = a[len(ra):], b[len(rb):]
+	}
+	return cmp.Compare(len(a), len(b))
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// cutRun returns the leading run of digits or non-digits of a non-empty string.
+func cutRun(s string) string {
+	digit := isDigit(s[0])
+	for i := 1; i < len(s); i++ {
+		if isDigit(s[i]) != digit {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// sortedRow is a record with the values of its sort keys.
+type sortedRow struct {
+	record []string
+	values []sortValue
+}
+
+// sorter compares records by the sort keys.
+type sorter struct {
+	keys []SortKey
+	cols []int
+}
+
+func newSorter(header []string, keys []SortKey) (*sorter, error) {
+	s := &sorter{keys: keys, cols: make([]int, len(keys))}
+	for i, key := range keys {
+		cols, err := ColumnIndex(header, Col(key.Column))
+		if err != nil {
+			return nil, err
+		}
+		if len(cols) != 1 {
+			return nil, ErrInvalidColumnIndex
+		}
+		s.cols[i] = cols[0].Index
+	}
+	return s, nil
+}
+
+func (s *sorter) row(record []string) sortedRow {
+	values := make([]sortValue, len(s.keys))
+	for i, key := range s.keys {
+		values[i] = key.value(Field(record, s.cols[i]))
+	}
+	return sortedRow{record: record, values: values}
+}
+
+func (s *sorter) compare(a, b sortedRow) int {
+	for i, key := range s.keys {
+		if c := key.compare(a.values[i], b.values[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+// sortRows implements the [RowConverter] for [SortRows].
+type sortRows struct {
+	keys   []SortKey
+	memory int64
+	sorter *sorter
+	rows   []sortedRow
+	size   int64
+	chunks []string // files of sorted chunks
+}
+
+// SortRows returns a [RowConverter] that sorts the records by the keys.
+// The sort is stable, records with equal keys keep their order.
+// Records are buffered until Flush. If the buffered records exceed the memory
+// budget in bytes, they are sorted and spilled to temp files, which are merged
+// on Flush. A memory budget of 0 keeps all records in memory.
+func SortRows(keys []SortKey, memory int64) RowConverter {
+	return &sortRows{keys: keys, memory: memory}
+}
+
+// SortBy returns the records sorted by the keys, see [SortRows].
+func SortBy(records Records, keys ...SortKey) (Records, error) {
+	if len(records) == 0 {
+		return nil, ErrColumnNotFound
+	}
+	return ApplyRows(records, SortRows(keys, 0))
+}
+
+// Sort returns the records sorted by a column, as numbers if a number format is given.
 func Sort(records Records, column string, asc bool, fmt NumberFormat) (Records, error) {
-	col, err := records.Column(column)
+	key := SortKey{Column: column, Desc: !asc}
+	if fmt != NumberInvalid {
+		key.Kind, key.Number = SortNumber, fmt
+	}
+	return SortBy(records, key)
+}
+
+func (s *sortRows) Header(header []string) ([]string, error) {
+	sorter, err := newSorter(header, s.keys)
 	if err != nil {
 		return nil, err
 	}
-	if fmt == NumberInvalid {
-		return records.SortByString(col.Index, asc), nil
+	s.sorter = sorter
+	return header, nil
+}
+
+func (s *sortRows) Row(record []string, emit Emit) error {
+	s.rows = append(s.rows, s.sorter.row(record))
+	s.size += recordSize(record)
+	if s.memory > 0 && s.size > s.memory {
+		return s.spill()
+	}
+	return nil
+}
+
+func (s *sortRows) Flush(emit Emit) error {
+	if len(s.chunks) == 0 {
+		slices.SortStableFunc(s.rows, s.sorter.compare)
+		for _, row := range s.rows {
+			if err := emit(row.record); err != nil {
+				return err
+			}
+		}
+		s.rows = nil
+		return nil
+	}
+	defer s.cleanup()
+	if len(s.rows) > 0 {
+		if err := s.spill(); err != nil {
+			return err
+		}
+	}
+	return s.merge(emit)
+}
+
+// recordSize estimates the memory used by a record.
+func recordSize(record []string) int64 {
+	size := int64(64 + 16*len(record))
+	for _, f := range record {
+		size += int64(len(f))
+	}
+	return size
+}
+
+// spill sorts the buffered records and writes them to a temp file.
+func (s *sortRows) spill() error {
+	slices.SortStableFunc(s.rows, s.sorter.compare)
+	f, err := os.CreateTemp("", "csvconv-sort-*")
+	if err != nil {
+		return err
+	}
+	s.chunks = append(s.chunks, f.Name())
+	slog.Debug("Spilling sorted records", "file", f.Name(), "records", len(s.rows), "bytes", s.size)
+
+	w := bufio.NewWriter(f)
+	enc := gob.NewEncoder(w)
+	for _, row := range s.rows {
+		if err := enc.Encode(row.record); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	s.rows, s.size = nil, 0
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *sortRows) cleanup() {
+	for _, name := range s.chunks {
+		os.Remove(name)
+	}
+	s.chunks = nil
+}
+
+// chunkReader reads the records of a sorted chunk.
+type chunkReader struct {
+	index int
+	dec   *gob.Decoder
+	row   sortedRow
+}
+
+func (c *chunkReader) next(s *sorter) (bool, error) {
+	var record []string
+	if err := c.dec.Decode(&record); err != nil {
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		return false, err
+	}
+	c.row = s.row(record)
+	return true, nil
+}
+
+// chunkHeap orders chunks by their next record. Equal records
+// are taken from earlier chunks first to keep the sort stable.
+type chunkHeap struct {
+	sorter *sorter
+	chunks []*chunkReader
+}
+
+func (h *chunkHeap) Len() int { return len(h.chunks) }
+func (h *chunkHeap) Less(i, j int) bool {
+	a, b := h.chunks[i], h.chunks[j]
+	return cmp.Or(h.sorter.compare(a.row, b.row), cmp.Compare(a.index, b.index)) < 0
+}
+func (h *chunkHeap) Swap(i, j int) { h.chunks[i], h.chunks[j] = h.chunks[j], h.chunks[i] }
+func (h *chunkHeap) Push(x any)    { h.chunks = append(h.chunks, x.(*chunkReader)) }
+func (h *chunkHeap) Pop() any {
+	last := h.chunks[len(h.chunks)-1]
+	h.chunks = h.chunks[:len(h.chunks)-1]
+	return last
+}
+
+// merge emits the records of all chunks in order.
+func (s *sortRows) merge(emit Emit) error {
+	h := &chunkHeap{sorter: s.sorter}
+	for i, name := range s.chunks {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		c := &chunkReader{index: i, dec: gob.NewDecoder(bufio.NewReader(f))}
+		ok, err := c.next(s.sorter)
+		if err != nil {
+			return err
+		}
+		if ok {
+			h.chunks = append(h.chunks, c)
+		}
+	}
+	heap.Init(h)
+	for h.Len() > 0 {
+		c := h.chunks[0]
+		if err := emit(c.row.record); err != nil {
+			return err
+		}
+		ok, err := c.next(s.sorter)
+		if err != nil {
+			return err
+		}
+		if ok {
+			heap.Fix(h, 0)
+		} else {
+			heap.Pop(h)
+		}
 	}
-	return records.SortByNumber(col.Index, asc, fmt), nil
+	return nil
 }
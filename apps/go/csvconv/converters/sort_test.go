@@ -0,0 +1,109 @@
+package converters
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortBy(t *testing.T) {
+	records := Records{
+		{"name", "day", "amount"},
+		{"file10", "24.12.2023", "1.000,5"},
+		{"file2", "01.01.2024", ""},
+		{"file1", "24.12.2023", "-3"},
+		{"File3", "", "n/a"},
+		{"file2", "01.01.2024", "7"},
+	}
+	day := SortKey{Column: "day", Desc: true, Kind: SortDate, Date: DateDot}
+	amount := SortKey{Column: "amount", Kind: SortNumber, Number: NumberComma}
+	tests := []struct {
+		name string
+		keys []SortKey
+		want []string // names in order
+	}{
+		{"string", []SortKey{{Column: "name"}}, []string{"File3", "file1", "file10", "file2", "file2"}},
+		{"natural", []SortKey{{Column: "name", Kind: SortNatural}}, []string{"File3", "file1", "file2", "file2", "file10"}},
+		{"desc", []SortKey{{Column: "name", Kind: SortNatural, Desc: true}}, []string{"file10", "file2", "file2", "file1", "File3"}},
+		{"multi", []SortKey{day, amount}, []string{"file2", "file2", "file1", "file10", "File3"}},
+		{"nulls first", []SortKey{{Column: "amount", Kind: SortNumber, Number: NumberComma, NullsFirst: true}}, []string{"file2", "File3", "file1", "file2", "file10"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SortBy(records, tt.keys...)
+			require.NoError(t, err)
+			require.Equal(t, records[0], got[0])
+			names := []string{}
+			for _, record := range got.Data() {
+				names = append(names, record[0])
+			}
+			require.Equal(t, tt.want, names)
+		})
+	}
+
+	got, err := SortBy(records, day, amount)
+	require.NoError(t, err)
+	require.Equal(t, Records{records[0], records[5], records[2], records[3], records[1], records[4]}, got, "stable and nulls last")
+
+	got, err = SortBy(Records{{"n"}, {"5"}, {"NaN"}, {"0x10"}, {"1e3"}, {"16"}}, SortKey{Column: "n", Kind: SortNumber, Number: NumberDot})
+	require.NoError(t, err)
+	require.Equal(t, Records{{"n"}, {"5"}, {"16"}, {"NaN"}, {"0x10"}, {"1e3"}}, got, "invalid numbers are sorted last")
+
+	_, err = SortBy(records, SortKey{Column: "missing"})
+	require.ErrorIs(t, err, ErrColumnNotFound)
+}
+
+func TestSortKeyType(t *testing.T) {
+	tests := []struct {
+		typ  string
+		want SortKey
+	}{
+		{"", SortKey{}},
+		{"natural", SortKey{Kind: SortNatural}},
+		{"num", SortKey{Kind: SortNumber, Number: NumberDot}},
+		{"num:de", SortKey{Kind: SortNumber, Number: NumberComma}},
+		{"comma", SortKey{Kind: SortNumber, Number: NumberComma}},
+		{"date", SortKey{Kind: SortDate, Date: DateAny}},
+		{"date:%d.%m.%Y %H:%M", SortKey{Kind: SortDate, Date: "02.01.2006 15:04"}},
+		{"iso", SortKey{Kind: SortDate, Date: DateISO}},
+	}
+	for _, tt := range tests {
+		key := SortKey{}
+		require.NoError(t, key.SetType(tt.typ), tt.typ)
+		require.Equal(t, tt.want, key, tt.typ)
+	}
+	for _, typ := range []string{"money", "num:xx", "natural:dot"} {
+		key := SortKey{}
+		require.ErrorIs(t, key.SetType(typ), ErrInvalidSortKey, typ)
+	}
+}
+
+func TestCompareNatural(t *testing.T) {
+	require.Equal(t, -1, compareNatural("a2", "a10"))
+	require.Equal(t, 0, compareNatural("a002b", "a2b"))
+	require.Equal(t, -1, compareNatural("a", "a1"))
+	require.Equal(t, 1, compareNatural("b1", "a2"))
+	require.Equal(t, -1, compareNatural("1.9", "1.10"))
+}
+
+func TestExternalSort(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	records := Records{{"n", "pos"}}
+	for i := range 1000 {
+		records = append(records, []string{fmt.Sprint(rand.Intn(50)), fmt.Sprint(i)})
+	}
+	want, err := SortBy(records, SortKey{Column: "n", Kind: SortNumber})
+	require.NoError(t, err)
+
+	got, err := ApplyRows(records, SortRows([]SortKey{{Column: "n", Kind: SortNumber}}, 4096))
+	require.NoError(t, err)
+	require.Equal(t, want, got, "same order as in-memory sort")
+
+	files, err := filepath.Glob(filepath.Join(os.TempDir(), "csvconv-sort-*"))
+	require.NoError(t, err)
+	require.Empty(t, files, "temp files are removed")
+}
@@ -1,8 +1,11 @@
 package csvconv
 
 import (
+	"fmt"
 	"log/slog"
 	"os"
+
+	"ubunatic.com/dotapps/go/csvconv/converters"
 )
 
 func ConvertCsv(src, dst string, opts ...Opt) error {
@@ -12,7 +15,7 @@ func ConvertCsv(src, dst string, opts ...Opt) error {
 		src = os.Stdin.Name()
 	}
 
-	if o.inline && dst != "" {
+	if o.inline && dst == "" {
 		dst = src
 	}
 
@@ -25,31 +28,27 @@ func ConvertCsv(src, dst string, opts ...Opt) error {
 		"outputMode", o.outputMode,
 	)
 
-	data, nlMode, err := ReadFileRaw(src)
-	records, err := ReadCsv(o.srcDelimiter, data)
+	r, sniffed, in, err := openInput(src, o)
 	if err != nil {
 		return err
 	}
-
-	slog.Debug("Read csv file", "records", len(records), "nlMode", nlMode)
-	// for _, rec := range records {
-	// 	slog.Debug("Record", "record", rec)
-	// }
-
-	for _, converter := range o.converters {
-		records, err = converter(records)
-		if err != nil {
-			return err
-		}
+	defer in.Close()
+	records, err := readAll(r)
+	if err != nil {
+		return err
 	}
 
-	if o.outputMode != AutoCRLF {
-		nlMode = o.outputMode
+	slog.Debug("Read csv file", "records", len(records), "nlMode", sniffed.NL)
+
+	records, err = converters.ApplyRows(records, o.rows...)
+	if err != nil {
+		return err
 	}
 
-	slog.Debug("Converted records", "records", len(records), "nlMode", nlMode)
+	format, cfg := o.writerConfig(dst, sniffed)
+	slog.Debug("Converted records", "records", len(records), "nlMode", cfg.NL, "format", format)
 
-	data, err = WriteCsv(o.dstDelimiter, nlMode, records)
+	data, err := WriteRecords(format, cfg, records)
 	if err != nil {
 		return err
 	}
@@ -64,6 +63,66 @@ func ConvertCsv(src, dst string, opts ...Opt) error {
 
 // ConvertCSVStream converts a CSV file from src to dst using the provided options.
 // It reads the input line-by-line and writes the output line-by-line.
-func ConvertCSVStream(src, dst string, opts ...Opt) error {
+// Row converters are applied to each record as it is read.
+// Buffered converters emit their results after the input is consumed.
+func ConvertCSVStream(src, dst string, opts ...Opt) (err error) {
+	o := newOptions(opts...)
+
+	if src == "-" {
+		src = os.Stdin.Name()
+	}
+
+	if o.inline && dst == "" {
+		dst = src
+	}
+
+	if dst == "" {
+		dst = os.Stdout.Name()
+	}
+
+	slog.Debug("Streaming csv file", "src", src, "dst", dst,
+		"delimiters", []rune{o.srcDelimiter, o.dstDelimiter},
+		"outputMode", o.outputMode,
+		"stages", len(o.rows),
+	)
+
+	r, sniffed, in, err := openInput(src, o)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	format, cfg := o.writerConfig(dst, sniffed)
+	if _, ok := Writers[format]; !ok {
+		return fmt.Errorf("%w: %q", ErrInvalidFormat, format)
+	}
+
+	out, closeOutput, err := createOutput(src, dst)
+	if err != nil {
+		return err
+	}
+	defer func() { err = closeOutput(err) }()
+
+	w, err := NewRecordWriter(format, out, cfg)
+	if err != nil {
+		return err
+	}
+
+	rows, err := streamCsv(r, w, o.rows)
+	if err != nil {
+		return err
+	}
+
+	slog.Debug("Streamed records", "records", rows, "nlMode", cfg.NL)
 	return nil
 }
+
+// ReadInputFile reads all records of src using the input options.
+func ReadInputFile(src string, opts ...Opt) (converters.Records, error) {
+	r, _, in, err := openInput(src, newOptions(opts...))
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	return readAll(r)
+}
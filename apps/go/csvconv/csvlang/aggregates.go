@@ -0,0 +1,107 @@
+package csvlang
+
+import (
+	"fmt"
+	"strings"
+
+	"ubunatic.com/dotapps/go/csvconv/converters"
+)
+
+// Group Grammar
+// =============
+// Group statements summarize records by key columns:
+//
+//	group     := "group" [ "by" ] [ column { "," column } ] "agg" aggregate { "," aggregate }
+//	aggregate := func "(" ( column | "*" ) ")" [ ( "as" | "->" ) name ]
+//	func      := "count" | "sum" | "avg" | "min" | "max"
+//
+// Example:
+//
+//	group by category agg sum(amount) as total, count(*), max(date) as last
+
+// groupParser parses the arguments of a group statement.
+type groupParser struct {
+	tokenParser
+}
+
+// ParseGroup parses the key columns and aggregates of a group statement.
+func ParseGroup(tokens []Token) (by []string, aggs []converters.Aggregate, err error) {
+	p := &groupParser{tokenParser{tokens: tokens}}
+	p.accept(TokenWord, "by")
+
+	by = []string{}
+	for !p.accept(TokenWord, "agg", "aggregate") {
+		col, ok := p.next()
+		if !ok {
+			return nil, nil, p.endError("column or 'agg'")
+		}
+		if !col.IsValue() {
+			return nil, nil, p.unexpected(col, "column")
+		}
+		by = append(by, col.Text)
+		if !p.accept(TokenComma) {
+			if tok, ok := p.peek(); ok && !tok.Is(TokenWord, "agg", "aggregate") {
+				return nil, nil, p.unexpected(tok, "',' or 'agg'")
+			}
+		}
+	}
+
+	aggs = []converters.Aggregate{}
+	for {
+		agg, err := p.parseAggregate()
+		if err != nil {
+			return nil, nil, err
+		}
+		aggs = append(aggs, agg)
+		if p.accept(TokenComma) {
+			continue
+		}
+		if tok, ok := p.peek(); ok {
+			return nil, nil, p.unexpected(tok, "','")
+		}
+		return by, aggs, nil
+	}
+}
+
+func (p *groupParser) parseAggregate() (converters.Aggregate, error) {
+	agg := converters.Aggregate{}
+	fn, ok := p.next()
+	if !ok {
+		return agg, p.endError("aggregate function")
+	}
+	if !fn.Is(TokenWord) || !converters.IsAggregate(fn.Text) {
+		return agg, errorAt(fn.Pos, fmt.Errorf("%w: %q, expected one of %s",
+			converters.ErrInvalidAggregate, fn.Text, strings.Join(converters.Aggregates, ", ")))
+	}
+	agg.Func = strings.ToLower(fn.Text)
+
+	if err := p.expect(TokenLParen, "'('"); err != nil {
+		return agg, err
+	}
+	col, ok := p.next()
+	if !ok {
+		return agg, p.endError("column")
+	}
+	if !col.IsValue() {
+		return agg, p.unexpected(col, "column")
+	}
+	if col.Is(TokenWord, "*") && agg.Func != "count" {
+		return agg, errorAt(col.Pos, fmt.Errorf("%w: %s(*) is not supported", converters.ErrInvalidAggregate, agg.Func))
+	}
+	agg.Column = col.Text
+	if err := p.expect(TokenRParen, "')'"); err != nil {
+		return agg, err
+	}
+
+	if p.accept(TokenWord, "as") || p.accept(TokenOp, "->") {
+		name, ok := p.next()
+		if !ok {
+			return agg, p.endError("name")
+		}
+		if !name.IsValue() {
+			return agg, p.unexpected(name, "name")
+		}
+		agg.As = name.Text
+	}
+	return agg, nil
+}
@@ -0,0 +1,281 @@
+package csvlang
+
+import (
+	"fmt"
+	"strings"
+
+	"ubunatic.com/dotapps/go/csvconv/converters"
+)
+
+// Condition Grammar
+// =================
+// Filter statements use boolean expressions of comparisons:
+//
+//	expr       := and { "or" and }
+//	and        := not { "and" not }
+//	not        := "not" not | "(" expr ")" | comparison
+//	comparison := column [ "not" ] op value
+//	value      := word { word } | "(" value { "," value } ")"
+//
+// Example:
+//
+//	where (amount > 100 or category in (food, rent)) and not name ~ '^test'
+
+// wordOperators are operators that are written as words.
+var wordOperators = []string{
+	"eq", "neq", "lt", "le", "lte", "gt", "ge", "gte", "not",
+	"contains", "startswith", "endswith", "like", "regexp", "notregexp", "in", "is",
+}
+
+// tokenParser provides a cursor over the tokens of a statement.
+type tokenParser struct {
+	tokens []Token
+	pos    int
+}
+
+// conditionParser is a recursive descent parser for filter conditions.
+type conditionParser struct {
+	tokenParser
+}
+
+// ParseCondition parses a boolean expression of comparisons.
+func ParseCondition(tokens []Token) (converters.Condition, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w: filter statement requires a condition", ErrInvalidArgs)
+	}
+	p := &conditionParser{tokenParser{tokens: tokens}}
+	cond, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok, ok := p.peek(); ok {
+		return nil, p.unexpected(tok, "'and' or 'or'")
+	}
+	return cond, nil
+}
+
+func (p *tokenParser) peek() (Token, bool) {
+	if p.pos >= len(p.tokens) {
+		return Token{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *tokenParser) peekOrZero() Token {
+	tok, _ := p.peek()
+	return tok
+}
+
+func (p *tokenParser) next() (Token, bool) {
+	tok, ok := p.peek()
+	if ok {
+		p.pos++
+	}
+	return tok, ok
+}
+
+// accept consumes the next token if it is of the given kind and text.
+func (p *tokenParser) accept(kind TokenKind, texts ...string) bool {
+	tok, ok := p.peek()
+	if ok && tok.Is(kind, texts...) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+// expect consumes the next token, which must be of the given kind.
+func (p *tokenParser) expect(kind TokenKind, expected string) error {
+	if p.accept(kind) {
+		return nil
+	}
+	if tok, ok := p.peek(); ok {
+		return p.unexpected(tok, expected)
+	}
+	return p.endError(expected)
+}
+
+func (p *tokenParser) unexpected(tok Token, expected string) error {
+	return errorAt(tok.Pos, fmt.Errorf("%w: %q, expected %s", ErrUnexpectedToken, tok.Text, expected))
+}
+
+// endError returns an error for an unexpected end of the statement.
+func (p *tokenParser) endError(expected string) error {
+	pos := 0
+	if len(p.tokens) > 0 {
+		pos = p.tokens[len(p.tokens)-1].End
+	}
+	return errorAt(pos, fmt.Errorf("%w: unexpected end of statement, expected %s", ErrInvalidArgs, expected))
+}
+
+// valueArg returns the text of the next token, which must be a column or value.
+func (p *tokenParser) valueArg(expected string) (string, error) {
+	tok, ok := p.next()
+	switch {
+	case !ok:
+		return "", p.endError(expected)
+	case !tok.IsValue():
+		return "", p.unexpected(tok, expected)
+	}
+	return tok.Text, nil
+}
+
+// keywordArg parses a keyword followed by a value, e.g., "by ';'".
+func (p *tokenParser) keywordArg(keyword, expected string) (string, error) {
+	if !p.accept(TokenWord, keyword) {
+		if tok, ok := p.peek(); ok {
+			return "", p.unexpected(tok, "'"+keyword+"'")
+		}
+		return "", p.endError("'" + keyword + "'")
+	}
+	return p.valueArg(expected)
+}
+
+func (p *conditionParser) parseOr() (converters.Condition, error) {
+	conds := converters.Or{}
+	for {
+		cond, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+		if !p.accept(TokenWord, "or") {
+			break
+		}
+	}
+	if len(conds) == 1 {
+		return conds[0], nil
+	}
+	return conds, nil
+}
+
+func (p *conditionParser) parseAnd() (converters.Condition, error) {
+	conds := converters.And{}
+	for {
+		cond, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, cond)
+		if !p.accept(TokenWord, "and") {
+			break
+		}
+	}
+	if len(conds) == 1 {
+		return conds[0], nil
+	}
+	return conds, nil
+}
+
+func (p *conditionParser) parseNot() (converters.Condition, error) {
+	if p.accept(TokenWord, "not") {
+		cond, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return &converters.Not{Cond: cond}, nil
+	}
+	if p.accept(TokenLParen) {
+		cond, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(TokenRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return cond, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *conditionParser) parseComparison() (converters.Condition, error) {
+	col, ok := p.next()
+	if !ok {
+		return nil, p.endError("column")
+	}
+	if !col.IsValue() {
+		return nil, p.unexpected(col, "column")
+	}
+
+	op, ok := p.next()
+	if !ok {
+		return nil, p.endError("operator")
+	}
+	negate := false
+	if op.Is(TokenWord, "not") && p.isOperator(p.peekOrZero()) {
+		negate = true
+		op, _ = p.next()
+	}
+	if !p.isOperator(op) {
+		return nil, errorAt(op.Pos, fmt.Errorf("%w: %q", ErrUnknownOperator, op.Text))
+	}
+
+	cmp := &converters.Compare{
+		Column: col.Text,
+		Op:     strings.ToLower(op.Text),
+	}
+	var err error
+	if cmp.Op == "in" {
+		cmp.Values, err = p.parseList()
+		cmp.Value = "(" + strings.Join(cmp.Values, ", ") + ")"
+	} else {
+		cmp.Value, err = p.parseValue()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if negate {
+		return &converters.Not{Cond: cmp}, nil
+	}
+	return cmp, nil
+}
+
+func (p *conditionParser) isOperator(tok Token) bool {
+	if tok.Is(TokenOp) {
+		return tok.Text != "->"
+	}
+	return tok.Is(TokenWord, wordOperators...)
+}
+
+// parseValue reads a value of one or more words and strings, such as "Bob" or "not null".
+func (p *conditionParser) parseValue() (string, error) {
+	words := []string{}
+	for {
+		tok, ok := p.peek()
+		if !ok || !tok.IsValue() || tok.Is(TokenWord, "and", "or") {
+			break
+		}
+		words = append(words, tok.Text)
+		p.pos++
+	}
+	if len(words) == 0 {
+		if tok, ok := p.peek(); ok {
+			return "", p.unexpected(tok, "value")
+		}
+		return "", p.endError("value")
+	}
+	return strings.Join(words, " "), nil
+}
+
+// parseList reads a list of values, such as "(a, 'b c')" or "a, b".
+func (p *conditionParser) parseList() ([]string, error) {
+	paren := p.accept(TokenLParen)
+	values := []string{}
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if !p.accept(TokenComma) {
+			break
+		}
+	}
+	if paren {
+		if err := p.expect(TokenRParen, "')'"); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
@@ -1,7 +1,11 @@
 package csvlang
 
 import (
+	"fmt"
 	"log/slog"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 
 	"ubunatic.com/dotapps/go/csvconv/converters"
@@ -18,19 +22,99 @@ import (
 // The following column-selection statements are supported:
 // - `select <column1>, <column2>, ...`: selects the column with the given name.
 // - `select <old> as <new>`: renames the column with the given old name to the new name.
+// - `select[:<fail|skip|blank|keep>] <column>:<type>:<cast>, ...`: converts the values of the column,
+//   e.g., `amount:num:comma`, `booked:date:dot:iso`, or `id:int`, see [converters.ParseCast].
+// - `select * except <column>, ...`: selects all columns except the given columns.
+// - `select /<pattern>/ [as <replacement>]`: selects all columns that match the regular expression,
+//   and renames them by replacing the matches, e.g., `select /^amount_(.*)/ as $1`.
+// - `drop <column>, ...`: removes the given columns.
+// - `rename <column> [as] <new>, ...`: renames the given columns.
+// - `rename /<pattern>/ <replacement>`: replaces the matches in all column names, e.g., `rename /\s+/ _`.
+// - `move <column>, ... first|last|before <column>|after <column>`: moves the given columns.
+// - `extract <column> /<pattern>/`: sets the columns named by the named groups of the pattern
+//   to the groups of the first match in the column, e.g., `extract memo /IBAN: (?P<iban>\S+)/`.
+// - `split <column> by <sep> into <column>, ...`: splits the values of the column into the given
+//   columns, the last column holds the remainder, e.g., `split name by ' ' into first, last`.
+// - `explode <column> by <sep>`: emits one row per part of the values of the column.
+//   Separators in slashes are regular expressions, e.g., `explode tags by /\s*;\s*/`.
+// - `header[:insert] <name>, ...`: replaces the names of the first columns, or inserts a header
+//   if the input has none, in which case the input header is the first row.
 // - `number[:<from>:<to>] <column>`: converts the numerical values of the column to the given type.
 // - `date[:<from>:<to>] <column>`: converts the date values of the column to the given type.
 //
 // The following full-line statements are supported:
 // - `numbers <from>:<to>`: converts all the numerical values of the CSV file to the given type.
 // - `dates <from>:<to>`: converts all the date values of the CSV file to the given type.
-// - `if <column> <op> <value>`: filters the rows of the CSV file based on the given condition.
-// - `and <column> <op> <value>`: filters the rows of the CSV file based on the given condition.
+// - `if[:<format>] <condition>`: filters the rows of the CSV file based on the given condition,
+//   numbers are compared in the given format, e.g., `if:de amount > '1.000,50'`.
+// - `and <condition>`: filters the rows of the CSV file based on the given condition.
+// - `set[:<format>] <column> = <expr>, ...`: sets or adds columns computed from expressions.
+//
+// The following aggregating statements are supported:
+// - `group[:<format>] by <column>, ... agg <func>(<column>) [as <name>], ...`: groups the rows
+//   by the given columns and aggregates them using `count`, `sum`, `avg`, `min`, or `max`,
+//   see [ParseGroup].
+// - `pivot[:<format>] [<key>, ...] by <column> value <column> [agg <func>]`: converts the rows
+//   from long to wide format, with one row per key and one column per value of the by column,
+//   which holds the aggregate of the values, `sum` by default, e.g., `pivot date by category value amount`.
+// - `unpivot <column>, ... [into <name>, <value>]`: converts the rows from wide to long format,
+//   with one row per row and unpivoted column, e.g., `unpivot food, rent into category, amount`.
+//
+// The following sorting statements are supported:
+// - `sort[:<options>] <column>[:<type>] [asc|desc] [nulls first|last], ...`: sorts the rows by
+//   the given columns, e.g., `sort date:iso desc, amount:num:comma, name:natural`, where
+//   the type is `num`, `date`, or `natural` with an optional format, see [converters.SortKey].
+//   Options such as `desc`, `num`, or `nulls-first` set the defaults of all columns.
+//
+// The following row-selection statements are supported:
+// - `head <n>` or `limit <n>`: keeps the first n rows and stops reading the input after them.
+// - `tail <n>`: keeps the last n rows.
+// - `skip <n>` or `offset <n>`: drops the first n rows.
+// - `distinct [<column>, ...]`: keeps the first row of rows with equal values in the given
+//   columns or in all columns.
+// - `dedupe by <column>, ... [keep first|last]`: keeps the first or last row of rows with
+//   equal values in the given columns, in the order of the kept rows.
+// - `sample <n> [<seed>]`: keeps a random sample of n rows in their input order,
+//   the sample is reproducible if a seed is given.
+//
+// The following multi-file statements are supported:
+// - `join <file> on <column>[=<column>], ... [inner|left|anti]`: joins the rows with
+//   the rows of another CSV file that have equal values in the given columns.
+//
+// Number formats are locales such as `dot`, `comma`, `de`, `en`, `ch`, and `fr` with
+// optional modifiers such as `+group`, `+2`, `+eur`, `+pct`, `+acct`, and `+trail`,
+// e.g., `numbers de:en+group+2`, see [converters.NumberFormat].
+//
+// Date formats are names such as `iso`, `dot`, `us`, `rfc3339`, and `unix`, strftime formats
+// such as `%d.%m.%Y\ %H:%M`, or Go layouts, with an optional time zone suffix such as
+// `@Europe/Berlin`, see [converters.ParseDateFormat]. Spaces in formats must be escaped.
+//
+// Conditions are comparisons `<column> <op> <value>` that can be combined
+// using `and`, `or`, `not`, and parentheses, see [ParseCondition].
+//
+// Expressions support numbers, 'strings', columns, the arithmetic operators
+// `+`, `-`, `*`, `/`, `%`, the concatenation operator `&`, and functions such as
+// `upper`, `lower`, `trim`, `substr`, `replace`, `coalesce`, `round`, and `year`,
+// see [ParseAssignments] and [converters.Functions].
 //
 // The following operators are supported:
 // - common comparison operators: `=`, `!=`, `>`, `<`, `>=`, `<=`.
 // - common match operators: `~`, `!~`, `in`, `is`, `is not`, `like`
 //
+// Values can be quoted with single quotes, e.g., `if name = 'a | b'`.
+// Column names can be quoted with double quotes, e.g., `select "Last, First" as name`.
+// Patterns are regular expressions between slashes, e.g., `/^(net|tax)_/` or `/IBAN: (\S+)/`,
+// which do not start or end with a space.
+// Inside quotes, a backslash escapes the quote character or another backslash.
+//
+// Programs can span multiple lines, e.g., in query files. A line that starts with a
+// statement begins a new statement, while indented lines and lines starting with a pipe
+// continue the previous statement. A `#` at the start of a word starts a comment.
+//
+// Variables are defined with `let <name> = <value>` and referenced as `$name` or `${name}`
+// in words and column names, but not in single-quoted strings. Undefined variables are
+// looked up in the environment, and `$$` is a literal dollar, see [ParseVars].
+//
 // Example:
 // ```
 // select name, age | number:float age | if age > 18 | if name ~ '[a-z]+' | numbers comma:dot | dates iso
@@ -41,11 +125,56 @@ type Statement interface {
 	Converter() converters.Converter
 }
 
+// RowStatement is implemented by statements that can be applied record by record.
+type RowStatement interface {
+	Statement
+	// Rows returns a new row converter that corresponds to the statement.
+	Rows() converters.RowConverter
+}
+
+// RowConverter returns a row converter for the statement.
+// Whole-table statements are buffered until all records are read.
+func RowConverter(stmt Statement) converters.RowConverter {
+	if s, ok := stmt.(RowStatement); ok {
+		return s.Rows()
+	}
+	return converters.Buffer(stmt.Converter())
+}
+
+// RowConverters returns new row converters for all statements of a program.
+func RowConverters(statements []Statement) []converters.RowConverter {
+	result := make([]converters.RowConverter, len(statements))
+	for i, stmt := range statements {
+		result[i] = RowConverter(stmt)
+	}
+	return result
+}
+
+// RejectRows returns new row converters for all statements of a program that
+// handle row errors using the rejects, see [converters.Rejects].
+// Statements are named by their position and name, e.g., "2:number".
+func RejectRows(statements []Statement, rejects *converters.Rejects) []converters.RowConverter {
+	result := []converters.RowConverter{rejects.Input()}
+	for i, stmt := range statements {
+		name := fmt.Sprintf("%d:%s", i+1, Name(stmt))
+		result = append(result, rejects.Wrap(name, RowConverter(stmt)))
+	}
+	return result
+}
+
+// Name returns the name of a statement, e.g., "number" for a [NumberStatement].
+func Name(stmt Statement) string {
+	name := fmt.Sprintf("%T", stmt)
+	name = name[strings.LastIndex(name, ".")+1:]
+	return strings.ToLower(strings.TrimSuffix(name, "Statement"))
+}
+
 func Col(name string) converters.Column        { return converters.Col(name) }
 func Cols(names ...string) []converters.Column { return converters.Cols(names...) }
 
 type SelectStatement struct {
 	Columns []converters.Column
+	OnError converters.ErrorMode
 }
 
 func (s *SelectStatement) ColumnNames() []string {
@@ -87,29 +216,142 @@ type DatesStatement struct {
 }
 
 type FilterStatement struct {
-	Column converters.Column
-	Op     string
-	Value  string
+	Condition converters.Condition
+}
+
+type SetStatement struct {
+	Assignments []converters.Assignment
+	Number      converters.NumberFormat
+}
+
+type GroupStatement struct {
+	By         []string
+	Aggregates []converters.Aggregate
+	Number     converters.NumberFormat
+}
+
+type JoinStatement struct {
+	File string
+	Keys []converters.JoinKey
+	Kind converters.JoinKind
+	Read TableReader // reads the joined file, see [SetTableReader]
 }
 
 type SortStatement struct {
-	Column       string
-	Asc          bool
-	AsNumber     bool
-	NumberFormat converters.NumberFormat
+	Keys   []converters.SortKey
+	Memory int64 // memory budget in bytes, see [SetSortMemory]
+}
+
+type HeadStatement struct {
+	Count int
+}
+
+type TailStatement struct {
+	Count int
+}
+
+type SkipStatement struct {
+	Count int
+}
+
+type DistinctStatement struct {
+	Columns []string // all columns if empty
+}
+
+type DedupeStatement struct {
+	By       []string
+	KeepLast bool
+}
+
+type SampleStatement struct {
+	Count int
+	Seed  *uint64 // random sample if nil
 }
 
-func NewSelectStatement(args ...string) *SelectStatement {
+type PivotStatement struct {
+	Keys   []string
+	By     string
+	Value  string
+	Func   string
+	Number converters.NumberFormat
+}
+
+type UnpivotStatement struct {
+	Columns []converters.Column
+	Name    string
+	Value   string
+}
+
+type ExtractStatement struct {
+	Column  string
+	Pattern string // regular expression with named groups
+}
+
+type SplitStatement struct {
+	Column string
+	Sep    string // separator, or a regular expression in slashes
+	Into   []string
+}
+
+type ExplodeStatement struct {
+	Column string
+	Sep    string // separator, or a regular expression in slashes
+}
+
+type DropStatement struct {
+	Columns []converters.Column
+}
+
+type RenameStatement struct {
+	Columns []converters.Column // columns or patterns with their new names or replacements
+}
+
+type MoveStatement struct {
+	Columns []converters.Column
+	Place   converters.Placement
+	Anchor  string // column of a before or after placement
+}
+
+type HeaderStatement struct {
+	Names  []string
+	Insert bool // the input header is the first record
+}
+
+func NewSelectStatement(args []Token, opts ...string) (*SelectStatement, error) {
+	onError, err := errorOption(opts)
+	if err != nil {
+		return nil, err
+	}
 	cols := []converters.Column{}
 
-	arg := strings.Join(args, " ")
-	args = converters.TrimSplit(arg, ",")
+	// columns after "except" are removed from the selected columns, e.g., "* except id"
+	var except []Token
+	if i := slices.IndexFunc(args, func(tok Token) bool { return tok.Is(TokenWord, "except") }); i >= 0 {
+		if i == len(args)-1 {
+			p := &tokenParser{tokens: args}
+			return nil, p.endError("column")
+		}
+		args, except = args[:i], args[i+1:]
+	}
 
-	for idx, arg := range args {
-		renameParts := converters.Unquotes(converters.TrimSplit(arg, "as", "->"))
-		col := renameParts[0]
+	for idx, spec := range splitTokens(args, TokenComma) {
+		if len(spec) == 0 {
+			return nil, fmt.Errorf("%w: empty column in select statement", ErrInvalidArgs)
+		}
+		col := spec[0]
+		if !col.IsValue() {
+			return nil, errorAt(col.Pos, fmt.Errorf("%w: %q, expected column", ErrUnexpectedToken, col.Text))
+		}
+		rest := spec[1:]
+
+		// a column may have a type and cast: "col:type:cast" or "col":type:cast,
+		// the cast may be a pair of formats, e.g., "amount:num:comma:dot"
+		name, typ, rest := columnSpec(col, rest)
+		colParts := []string{name}
+		if typ != "" {
+			colParts = append(colParts, strings.SplitN(typ, ":", 2)...)
+		}
 
-		colParts := strings.Split(col, ":")
 		colType := ""
 		colCast := ""
 		switch len(colParts) {
@@ -119,21 +361,22 @@ func NewSelectStatement(args ...string) *SelectStatement {
 		case 3:
 			colType = colParts[1]
 			colCast = colParts[2]
-		default:
-			panic("invalid cast in select statement")
+		}
+		if _, err := converters.ParseCast(colType, colCast); err != nil {
+			return nil, errorAt(col.Pos, fmt.Errorf("%w: invalid cast %q of column %q in select statement: %w", ErrInvalidArgs, typ, name, err))
 		}
 
 		rename := ""
-		switch len(renameParts) {
-		case 1: // pass
-		case 2:
-			rename = renameParts[1]
+		switch {
+		case len(rest) == 0: // pass
+		case len(rest) == 2 && (rest[0].Is(TokenWord, "as") || rest[0].Is(TokenOp, "->")) && rest[1].IsValue():
+			rename = rest[1].Text
 		default:
-			panic("invalid rename in select statement")
+			return nil, errorAt(rest[0].Pos, fmt.Errorf("%w: invalid rename in select statement", ErrInvalidArgs))
 		}
 
 		cols = append(cols, converters.Column{
-			Name:   col,
+			Name:   colParts[0],
 			Index:  idx,
 			Rename: rename,
 			Type:   colType,
@@ -141,114 +384,880 @@ func NewSelectStatement(args ...string) *SelectStatement {
 		})
 	}
 
-	return &SelectStatement{Columns: cols}
+	if except != nil {
+		names, err := columnArgs(except)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			cols = append(cols, converters.Column{Name: name, Exclude: true})
+		}
+	}
+
+	return &SelectStatement{Columns: cols, OnError: onError}, nil
+}
+
+// errorOption returns the error mode of a statement with an optional error mode option.
+func errorOption(opts []string) (converters.ErrorMode, error) {
+	switch len(opts) {
+	case 0:
+		return converters.ErrorFail, nil
+	case 1:
+		mode, err := converters.ParseErrorMode(opts[0])
+		if err != nil {
+			return mode, fmt.Errorf("%w: %w", ErrInvalidOptions, err)
+		}
+		return mode, nil
+	default:
+		return converters.ErrorFail, fmt.Errorf("%w: too many error modes %q", ErrInvalidOptions, opts)
+	}
+}
+
+// splitTokens splits the tokens at all separator tokens of the given kind.
+func splitTokens(tokens []Token, sep TokenKind) [][]Token {
+	if len(tokens) == 0 {
+		return nil
+	}
+	result := [][]Token{{}}
+	for _, tok := range tokens {
+		if tok.Is(sep) {
+			result = append(result, []Token{})
+			continue
+		}
+		result[len(result)-1] = append(result[len(result)-1], tok)
+	}
+	return result
+}
+
+// columnSpec splits a column token with a type, e.g., "amount:num:comma",
+// into the name and the type. The type of a quoted column follows
+// the closing quote, e.g., "Last, First":natural.
+func columnSpec(col Token, rest []Token) (name, typ string, remaining []Token) {
+	if col.Is(TokenWord) {
+		n := lexPattern(col.Text) // the type follows the pattern, e.g., /^a:b/:num
+		name, typ, _ = strings.Cut(col.Text[n:], ":")
+		return col.Text[:n] + name, typ, rest
+	}
+	if len(rest) > 0 && rest[0].Is(TokenWord) && rest[0].Pos == col.End && strings.HasPrefix(rest[0].Text, ":") {
+		return col.Text, rest[0].Text[1:], rest[1:]
+	}
+	return col.Text, "", rest
+}
+
+// singleArg returns the only argument of a statement, which must be a column or value.
+func singleArg(stmt string, args []Token) (Token, error) {
+	switch {
+	case len(args) == 0:
+		return Token{}, fmt.Errorf("%w: %s statement requires one column", ErrInvalidArgs, stmt)
+	case len(args) > 1:
+		return Token{}, errorAt(args[1].Pos, fmt.Errorf("%w: %s statement requires one column, got %d args", ErrInvalidArgs, stmt, len(args)))
+	case !args[0].IsValue():
+		return Token{}, errorAt(args[0].Pos, fmt.Errorf("%w: %q, expected column", ErrUnexpectedToken, args[0].Text))
+	}
+	return args[0], nil
+}
+
+// argOptions converts arguments such as "dot comma" or "dot:comma" to options.
+func argOptions(args []Token) []string {
+	opts := []string{}
+	for _, arg := range args {
+		opts = append(opts, converters.TrimSplit(arg.Text, ":")...)
+	}
+	return opts
 }
 
-func getNumberFormat(opts []string) (from, to converters.NumberFormat) {
+func getNumberFormat(opts []string) (from, to converters.NumberFormat, err error) {
+	from, to = converters.NumberDot, converters.NumberDot
 	switch len(opts) {
 	case 0:
-		return converters.NumberDot, converters.NumberDot
 	case 1:
-		return converters.NumberDot, converters.MustParseNumberFormat(opts[0])
+		to, err = converters.ParseNumberFormat(opts[0])
 	case 2:
-		return converters.MustParseNumberFormat(opts[0]), converters.MustParseNumberFormat(opts[1])
+		from, err = converters.ParseNumberFormat(opts[0])
+		if err == nil {
+			to, err = converters.ParseNumberFormat(opts[1])
+		}
 	default:
 		slog.Error("invalid number options", "opts", opts)
-		panic("invalid number options")
+		return from, to, fmt.Errorf("%w: too many number formats %q", ErrInvalidOptions, opts)
+	}
+	if err != nil {
+		return from, to, fmt.Errorf("%w: %w %q", ErrInvalidOptions, err, opts)
 	}
+	return from, to, nil
 }
 
-func NewNumberStatement(args []string, opts ...string) *NumberStatement {
-	if len(args) != 1 {
-		panic("invalid number statement")
+func NewNumberStatement(args []Token, opts ...string) (*NumberStatement, error) {
+	col, err := singleArg("number", args)
+	if err != nil {
+		return nil, err
 	}
 	s := &NumberStatement{
-		Column: converters.Trim(args[0]),
+		Column: col.Text,
 	}
-	s.From, s.To = getNumberFormat(opts)
-	return s
+	s.From, s.To, err = getNumberFormat(opts)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
-func NewNumbersStatement(args ...string) *NumbersStatement {
-	opts := converters.TrimSplit(strings.Join(args, ":"), ":") // convert args to options
+func NewNumbersStatement(args ...Token) (*NumbersStatement, error) {
+	opts := argOptions(args)
 	s := &NumbersStatement{}
-	s.From, s.To = getNumberFormat(opts)
-	return s
+	var err error
+	s.From, s.To, err = getNumberFormat(opts)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
-func getDateFormats(opts []string) (from, to converters.DateFormat) {
+// getDateFormats parses the source and target formats of date statements.
+// Options are rejoined since layouts such as "%H:%M" may contain colons.
+func getDateFormats(opts []string) (from, to converters.DateFormat, err error) {
+	from, to = converters.DateAny, converters.DateISO
+	opts = converters.SplitDateFormats(strings.Join(opts, ":"))
 	switch len(opts) {
 	case 0:
-		return converters.DateAny, converters.DateISO
 	case 1:
-		return converters.DateAny, converters.MustParseDateFormat(opts[0])
+		to, err = converters.ParseDateFormat(opts[0])
 	case 2:
-		return converters.MustParseDateFormat(opts[0]), converters.MustParseDateFormat(opts[1])
+		from, err = converters.ParseDateFormat(opts[0])
+		if err == nil {
+			to, err = converters.ParseDateFormat(opts[1])
+		}
 	default:
 		slog.Error("invalid date options", "opts", opts)
-		panic("invalid date options")
+		return from, to, fmt.Errorf("%w: too many date formats %q", ErrInvalidOptions, opts)
+	}
+	if err != nil {
+		return from, to, fmt.Errorf("%w: %w %q", ErrInvalidOptions, err, opts)
 	}
+	return from, to, nil
 }
 
-func NewDateStatement(args []string, opts ...string) *DateStatement {
-	if len(args) != 1 {
-		panic("invalid date statement")
+func NewDateStatement(args []Token, opts ...string) (*DateStatement, error) {
+	col, err := singleArg("date", args)
+	if err != nil {
+		return nil, err
 	}
 	s := &DateStatement{
-		Column: converters.Trim(args[0]),
+		Column: col.Text,
+	}
+	s.From, s.To, err = getDateFormats(opts)
+	if err != nil {
+		return nil, err
 	}
-	s.From, s.To = getDateFormats(opts)
-	return s
+	return s, nil
 }
 
-func NewDatesStatement(args ...string) *DatesStatement {
+func NewDatesStatement(args ...Token) (*DatesStatement, error) {
 	s := &DatesStatement{}
-	opts := converters.TrimSplit(strings.Join(args, ":"), ":") // convert args to options
-	s.From, s.To = getDateFormats(opts)
-	return s
+	opts := Texts(args)
+	var err error
+	s.From, s.To, err = getDateFormats(opts)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func NewFilterStatement(args []Token, opts ...string) (*FilterStatement, error) {
+	cond, err := ParseCondition(args)
+	if err != nil {
+		return nil, err
+	}
+	if len(opts) > 0 {
+		number, err := numberOption(opts)
+		if err != nil {
+			return nil, err
+		}
+		converters.SetNumberFormat(cond, number)
+	}
+	return &FilterStatement{Condition: cond}, nil
+}
+
+func NewSetStatement(args []Token, opts ...string) (*SetStatement, error) {
+	assignments, err := ParseAssignments(args)
+	if err != nil {
+		return nil, err
+	}
+	number, err := numberOption(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &SetStatement{Assignments: assignments, Number: number}, nil
+}
+
+func NewGroupStatement(args []Token, opts ...string) (*GroupStatement, error) {
+	by, aggs, err := ParseGroup(args)
+	if err != nil {
+		return nil, err
+	}
+	number, err := numberOption(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &GroupStatement{By: by, Aggregates: aggs, Number: number}, nil
+}
+
+// numberOption returns the number format of a statement with an optional format option.
+func numberOption(opts []string) (converters.NumberFormat, error) {
+	switch len(opts) {
+	case 0:
+		return converters.NumberDot, nil
+	case 1:
+		number, err := converters.ParseNumberFormat(opts[0])
+		if err != nil {
+			return number, fmt.Errorf("%w: %w %q", ErrInvalidOptions, err, opts)
+		}
+		return number, nil
+	default:
+		return converters.NumberInvalid, fmt.Errorf("%w: too many number formats %q", ErrInvalidOptions, opts)
+	}
 }
 
-func NewFilterStatement(args []string) *FilterStatement {
-	if len(args) < 3 {
-		panic("invalid filter statement")
+func NewJoinStatement(args []Token) (*JoinStatement, error) {
+	p := &tokenParser{tokens: args}
+	file, ok := p.next()
+	if !ok {
+		return nil, fmt.Errorf("%w: join statement requires a file", ErrInvalidArgs)
+	}
+	if !file.IsValue() {
+		return nil, p.unexpected(file, "file")
+	}
+	if !p.accept(TokenWord, "on") {
+		if tok, ok := p.peek(); ok {
+			return nil, p.unexpected(tok, "'on'")
+		}
+		return nil, p.endError("'on'")
+	}
+
+	s := &JoinStatement{File: file.Text, Kind: converters.JoinInner}
+	for {
+		left, ok := p.next()
+		if !ok {
+			return nil, p.endError("column")
+		}
+		if !left.IsValue() {
+			return nil, p.unexpected(left, "column")
+		}
+		key := converters.JoinKey{Left: left.Text, Right: left.Text}
+		if p.accept(TokenOp, "=", "==") {
+			right, ok := p.next()
+			if !ok {
+				return nil, p.endError("column")
+			}
+			if !right.IsValue() {
+				return nil, p.unexpected(right, "column")
+			}
+			key.Right = right.Text
+		}
+		s.Keys = append(s.Keys, key)
+		if !p.accept(TokenComma) {
+			break
+		}
+	}
+
+	if kind, ok := p.next(); ok {
+		var err error
+		if s.Kind, err = converters.ParseJoinKind(kind.Text); err != nil || !kind.Is(TokenWord) {
+			return nil, p.unexpected(kind, "',', 'inner', 'left', or 'anti'")
+		}
 	}
-	// TODO: add OR support
-	return &FilterStatement{
-		Column: converters.Col(converters.Trim(args[0])),
-		Op:     strings.ToLower(converters.Trim(args[1])),
-		Value:  converters.Trim(strings.Join(args[2:], " ")),
+	if tok, ok := p.peek(); ok {
+		return nil, p.unexpected(tok, "end of statement")
 	}
+	return s, nil
 }
 
-func NewSortStatement(args []string, opts ...string) *SortStatement {
-	asc := true
-	number := false
-	fmt := converters.NumberInvalid
+// TableReader reads a table from a file, e.g., the joined file of a join statement.
+type TableReader func(file string) (converters.Records, error)
 
+// SetTableReader sets the reader for all statements that read other files.
+// The reader should use the same CSV settings as the main input.
+func SetTableReader(statements []Statement, read TableReader) {
+	for _, stmt := range statements {
+		if s, ok := stmt.(*JoinStatement); ok {
+			s.Read = read
+		}
+	}
+}
+
+// NewSortStatement parses sort keys such as "date desc, amount:num:comma, name:natural nulls first".
+// Keyword options, such as "desc", "num", "comma", "natural", or "nulls-first", set the defaults of all keys.
+func NewSortStatement(args []Token, opts ...string) (*SortStatement, error) {
+	defaults := converters.SortKey{}
 	for _, opt := range opts {
-		switch opt {
+		switch opt = strings.ToLower(opt); opt {
 		case "asc":
-			asc = true
+			defaults.Desc = false
 		case "desc":
-			asc = false
-		case "num":
-			if fmt == converters.NumberInvalid {
-				fmt = converters.NumberDot
+			defaults.Desc = true
+		case "nulls-first", "nullsfirst":
+			defaults.NullsFirst = true
+		case "nulls-last", "nullslast":
+			defaults.NullsFirst = false
+		default:
+			if err := defaults.SetType(opt); err != nil {
+				slog.Error("invalid sort option", "opt", opt)
+				return nil, fmt.Errorf("%w: unknown sort option %q", ErrInvalidOptions, opt)
+			}
+		}
+	}
+
+	s := &SortStatement{Memory: converters.DefaultSortMemory}
+	for _, spec := range splitTokens(args, TokenComma) {
+		if len(spec) == 0 {
+			return nil, fmt.Errorf("%w: empty sort key", ErrInvalidArgs)
+		}
+		col := spec[0]
+		if !col.IsValue() {
+			return nil, errorAt(col.Pos, fmt.Errorf("%w: %q, expected column", ErrUnexpectedToken, col.Text))
+		}
+		name, typ, rest := columnSpec(col, spec[1:])
+		key := defaults
+		key.Column = name
+		if typ != "" {
+			if err := key.SetType(typ); err != nil {
+				return nil, errorAt(col.Pos, err)
+			}
+		}
+		p := &tokenParser{tokens: rest}
+		for {
+			tok, ok := p.next()
+			if !ok {
+				break
+			}
+			switch {
+			case tok.Is(TokenWord, "asc"):
+				key.Desc = false
+			case tok.Is(TokenWord, "desc"):
+				key.Desc = true
+			case tok.Is(TokenWord, "nulls") && p.accept(TokenWord, "first"):
+				key.NullsFirst = true
+			case tok.Is(TokenWord, "nulls") && p.accept(TokenWord, "last"):
+				key.NullsFirst = false
+			case tok.Is(TokenWord, "nulls"):
+				if tok, ok := p.peek(); ok {
+					return nil, p.unexpected(tok, "'first' or 'last'")
+				}
+				return nil, p.endError("'first' or 'last'")
+			default:
+				return nil, p.unexpected(tok, "'asc', 'desc', 'nulls', or ','")
+			}
+		}
+		s.Keys = append(s.Keys, key)
+	}
+	if len(s.Keys) == 0 {
+		return nil, fmt.Errorf("%w: sort statement requires a column", ErrInvalidArgs)
+	}
+	return s, nil
+}
+
+// SetSortMemory sets the memory budget of all sort statements,
+// see [converters.SortRows].
+func SetSortMemory(statements []Statement, memory int64) {
+	for _, stmt := range statements {
+		if s, ok := stmt.(*SortStatement); ok {
+			s.Memory = memory
+		}
+	}
+}
+
+// countArg returns the number of rows of statements such as "head 10".
+func countArg(stmt string, args []Token) (int, error) {
+	if len(args) == 0 {
+		return 0, fmt.Errorf("%w: %s statement requires a number of rows", ErrInvalidArgs, stmt)
+	}
+	n, err := strconv.Atoi(args[0].Text)
+	if err != nil || n < 0 || !args[0].Is(TokenWord) {
+		return 0, errorAt(args[0].Pos, fmt.Errorf("%w: %q, expected number of rows", ErrUnexpectedToken, args[0].Text))
+	}
+	return n, nil
+}
+
+// endArgs returns an error if there are more arguments than expected.
+func endArgs(stmt string, args []Token, max int) error {
+	if len(args) > max {
+		return errorAt(args[max].Pos, fmt.Errorf("%w: %q, expected end of %s statement", ErrUnexpectedToken, args[max].Text, stmt))
+	}
+	return nil
+}
+
+// columnArgs returns the column names of a comma-separated list of columns.
+func columnArgs(args []Token) ([]string, error) {
+	columns := []string{}
+	for _, col := range splitTokens(args, TokenComma) {
+		switch {
+		case len(col) == 0:
+			return nil, fmt.Errorf("%w: empty column", ErrInvalidArgs)
+		case len(col) > 1:
+			return nil, errorAt(col[1].Pos, fmt.Errorf("%w: %q, expected ','", ErrUnexpectedToken, col[1].Text))
+		case !col[0].IsValue():
+			return nil, errorAt(col[0].Pos, fmt.Errorf("%w: %q, expected column", ErrUnexpectedToken, col[0].Text))
+		}
+		columns = append(columns, col[0].Text)
+	}
+	return columns, nil
+}
+
+// NewHeadStatement parses "head <n>", which keeps the first n rows.
+func NewHeadStatement(args []Token) (*HeadStatement, error) {
+	n, err := countArg("head", args)
+	if err != nil {
+		return nil, err
+	}
+	if err := endArgs("head", args, 1); err != nil {
+		return nil, err
+	}
+	return &HeadStatement{Count: n}, nil
+}
+
+// NewTailStatement parses "tail <n>", which keeps the last n rows.
+func NewTailStatement(args []Token) (*TailStatement, error) {
+	n, err := countArg("tail", args)
+	if err != nil {
+		return nil, err
+	}
+	if err := endArgs("tail", args, 1); err != nil {
+		return nil, err
+	}
+	return &TailStatement{Count: n}, nil
+}
+
+// NewSkipStatement parses "skip <n>", which drops the first n rows.
+func NewSkipStatement(args []Token) (*SkipStatement, error) {
+	n, err := countArg("skip", args)
+	if err != nil {
+		return nil, err
+	}
+	if err := endArgs("skip", args, 1); err != nil {
+		return nil, err
+	}
+	return &SkipStatement{Count: n}, nil
+}
+
+// NewDistinctStatement parses "distinct [<column>, ...]".
+func NewDistinctStatement(args []Token) (*DistinctStatement, error) {
+	if len(args) == 0 {
+		return &DistinctStatement{}, nil
+	}
+	columns, err := columnArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	return &DistinctStatement{Columns: columns}, nil
+}
+
+// NewDedupeStatement parses "dedupe by <column>, ... [keep first|last]".
+func NewDedupeStatement(args []Token) (*DedupeStatement, error) {
+	p := &tokenParser{tokens: args}
+	if !p.accept(TokenWord, "by") {
+		if tok, ok := p.peek(); ok {
+			return nil, p.unexpected(tok, "'by'")
+		}
+		return nil, p.endError("'by'")
+	}
+	cols := args[p.pos:]
+	keep := slices.IndexFunc(cols, func(tok Token) bool { return tok.Is(TokenWord, "keep") })
+	if keep >= 0 {
+		cols, p.pos = cols[:keep], p.pos+keep+1
+	} else {
+		p.pos = len(args)
+	}
+	switch {
+	case len(cols) == 0 && keep >= 0:
+		return nil, p.unexpected(args[p.pos-1], "column")
+	case len(cols) == 0:
+		return nil, p.endError("column")
+	}
+	columns, err := columnArgs(cols)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &DedupeStatement{By: columns}
+	if keep >= 0 {
+		switch {
+		case p.accept(TokenWord, "first"):
+		case p.accept(TokenWord, "last"):
+			s.KeepLast = true
+		default:
+			if tok, ok := p.peek(); ok {
+				return nil, p.unexpected(tok, "'first' or 'last'")
 			}
-		case "dot":
-			fmt = converters.NumberDot
-		case "comma":
-			fmt = converters.NumberComma
+			return nil, p.endError("'first' or 'last'")
+		}
+	}
+	if tok, ok := p.peek(); ok {
+		return nil, p.unexpected(tok, "end of dedupe statement")
+	}
+	return s, nil
+}
+
+// NewSampleStatement parses "sample <n> [<seed>]".
+func NewSampleStatement(args []Token) (*SampleStatement, error) {
+	n, err := countArg("sample", args)
+	if err != nil {
+		return nil, err
+	}
+	if err := endArgs("sample", args, 2); err != nil {
+		return nil, err
+	}
+	s := &SampleStatement{Count: n}
+	if len(args) > 1 {
+		seed, err := strconv.ParseUint(args[1].Text, 10, 64)
+		if err != nil || !args[1].Is(TokenWord) {
+			return nil, errorAt(args[1].Pos, fmt.Errorf("%w: %q, expected seed", ErrUnexpectedToken, args[1].Text))
+		}
+		s.Seed = &seed
+	}
+	return s, nil
+}
+
+// NewDropStatement parses "drop <column>, ...", where columns may be patterns.
+func NewDropStatement(args []Token) (*DropStatement, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%w: drop statement requires a column", ErrInvalidArgs)
+	}
+	names, err := columnArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	return &DropStatement{Columns: converters.Cols(names...)}, nil
+}
+
+// NewRenameStatement parses "rename <column> [as] <name>, ..." and "rename /<pattern>/ <replacement>".
+func NewRenameStatement(args []Token) (*RenameStatement, error) {
+	s := &RenameStatement{}
+	for _, spec := range splitTokens(args, TokenComma) {
+		p := &tokenParser{tokens: spec}
+		col, ok := p.next()
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("%w: empty column in rename statement", ErrInvalidArgs)
+		case !col.IsValue():
+			return nil, p.unexpected(col, "column")
+		}
+		if !p.accept(TokenWord, "as") {
+			p.accept(TokenOp, "->")
+		}
+		name, ok := p.next()
+		switch {
+		case !ok:
+			return nil, p.endError("new name")
+		case !name.IsValue():
+			return nil, p.unexpected(name, "new name")
+		}
+		if tok, ok := p.next(); ok {
+			return nil, p.unexpected(tok, "','")
+		}
+		s.Columns = append(s.Columns, converters.Column{Name: col.Text, Rename: name.Text})
+	}
+	if len(s.Columns) == 0 {
+		return nil, fmt.Errorf("%w: rename statement requires a column", ErrInvalidArgs)
+	}
+	return s, nil
+}
+
+// NewMoveStatement parses "move <column>, ... first|last|before <column>|after <column>".
+func NewMoveStatement(args []Token) (*MoveStatement, error) {
+	i := slices.IndexFunc(args, func(tok Token) bool { return tok.Is(TokenWord, "first", "last", "before", "after") })
+	p := &tokenParser{tokens: args}
+	switch {
+	case i < 0:
+		return nil, p.endError("'first', 'last', 'before', or 'after'")
+	case i == 0:
+		return nil, p.unexpected(args[0], "column")
+	}
+	names, err := columnArgs(args[:i])
+	if err != nil {
+		return nil, err
+	}
+
+	s := &MoveStatement{Columns: converters.Cols(names...), Place: converters.Placement(strings.ToLower(args[i].Text))}
+	p.pos = i + 1
+	if s.Place == converters.PlaceBefore || s.Place == converters.PlaceAfter {
+		anchor, ok := p.next()
+		switch {
+		case !ok:
+			return nil, p.endError("column")
+		case !anchor.IsValue():
+			return nil, p.unexpected(anchor, "column")
+		}
+		s.Anchor = anchor.Text
+	}
+	if tok, ok := p.next(); ok {
+		return nil, p.unexpected(tok, "end of move statement")
+	}
+	return s, nil
+}
+
+// NewHeaderStatement parses "header[:insert] <name>, ...", which replaces the names of the first
+// columns, or inserts a new header if the input has no header.
+func NewHeaderStatement(args []Token, opts ...string) (*HeaderStatement, error) {
+	s := &HeaderStatement{}
+	for _, opt := range opts {
+		switch strings.ToLower(opt) {
+		case "insert":
+			s.Insert = true
+		case "replace":
+			s.Insert = false
 		default:
-			slog.Error("invalid sort option", "opt", opt)
+			return nil, fmt.Errorf("%w: unknown header option %q", ErrInvalidOptions, opt)
+		}
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("%w: header statement requires a column name", ErrInvalidArgs)
+	}
+	names, err := columnArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	s.Names = names
+	return s, nil
+}
+
+// NewPivotStatement parses "pivot[:<format>] [<key>, ...] by <column> value <column> [agg <func>]".
+func NewPivotStatement(args []Token, opts ...string) (*PivotStatement, error) {
+	number, err := numberOption(opts)
+	if err != nil {
+		return nil, err
+	}
+	s := &PivotStatement{Func: "sum", Number: number}
+	p := &tokenParser{tokens: args}
+	by := slices.IndexFunc(args, func(tok Token) bool { return tok.Is(TokenWord, "by") })
+	if by < 0 {
+		return nil, p.endError("'by'")
+	}
+	if by > 0 {
+		if s.Keys, err = columnArgs(args[:by]); err != nil {
+			return nil, err
+		}
+	}
+	p.pos = by + 1
+
+	if s.By, err = p.valueArg("column"); err != nil {
+		return nil, err
+	}
+	if s.Value, err = p.keywordArg("value", "column"); err != nil {
+		return nil, err
+	}
+	if p.accept(TokenWord, "agg", "aggregate") {
+		fn, ok := p.next()
+		switch {
+		case !ok:
+			return nil, p.endError("aggregate function")
+		case !fn.Is(TokenWord) || !converters.IsAggregate(fn.Text):
+			return nil, errorAt(fn.Pos, fmt.Errorf("%w: %q, expected one of %s",
+				converters.ErrInvalidAggregate, fn.Text, strings.Join(converters.Aggregates, ", ")))
 		}
+		s.Func = strings.ToLower(fn.Text)
 	}
+	if tok, ok := p.next(); ok {
+		return nil, p.unexpected(tok, "'agg' or end of pivot statement")
+	}
+	return s, nil
+}
 
-	return &SortStatement{
-		Column:   converters.Trim(args[0]),
-		Asc:      asc,
-		AsNumber: number,
+// NewUnpivotStatement parses "unpivot <column>, ... [into <name>, <value>]".
+func NewUnpivotStatement(args []Token) (*UnpivotStatement, error) {
+	s := &UnpivotStatement{Name: "name", Value: "value"}
+	into := slices.IndexFunc(args, func(tok Token) bool { return tok.Is(TokenWord, "into") })
+	cols := args
+	if into >= 0 {
+		cols = args[:into]
+		names, err := columnArgs(args[into+1:])
+		if err != nil {
+			return nil, err
+		}
+		if len(names) != 2 {
+			return nil, errorAt(args[into].Pos, fmt.Errorf("%w: into requires a name and a value column", ErrInvalidArgs))
+		}
+		s.Name, s.Value = names[0], names[1]
+	}
+	if len(cols) == 0 {
+		return nil, fmt.Errorf("%w: unpivot statement requires a column", ErrInvalidArgs)
+	}
+	names, err := columnArgs(cols)
+	if err != nil {
+		return nil, err
+	}
+	s.Columns = converters.Cols(names...)
+	return s, nil
+}
+
+// NewExtractStatement parses "extract <column> /<pattern>/", e.g., "extract memo /IBAN: (?P<iban>\S+)/".
+func NewExtractStatement(args []Token) (*ExtractStatement, error) {
+	p := &tokenParser{tokens: args}
+	column, err := p.valueArg("column")
+	if err != nil {
+		return nil, err
+	}
+	pattern, err := p.valueArg("pattern")
+	if err != nil {
+		return nil, err
+	}
+	if tok, ok := p.next(); ok {
+		return nil, p.unexpected(tok, "end of extract statement")
 	}
+	return &ExtractStatement{Column: column, Pattern: pattern}, nil
+}
+
+// NewSplitStatement parses "split <column> by <sep> into <column>, ...".
+func NewSplitStatement(args []Token) (*SplitStatement, error) {
+	p := &tokenParser{tokens: args}
+	column, err := p.valueArg("column")
+	if err != nil {
+		return nil, err
+	}
+	sep, err := p.keywordArg("by", "separator")
+	if err != nil {
+		return nil, err
+	}
+	if !p.accept(TokenWord, "into") {
+		if tok, ok := p.peek(); ok {
+			return nil, p.unexpected(tok, "'into'")
+		}
+		return nil, p.endError("'into'")
+	}
+	if _, ok := p.peek(); !ok {
+		return nil, p.endError("column")
+	}
+	into, err := columnArgs(args[p.pos:])
+	if err != nil {
+		return nil, err
+	}
+	return &SplitStatement{Column: column, Sep: sep, Into: into}, nil
+}
+
+// NewExplodeStatement parses "explode <column> by <sep>".
+func NewExplodeStatement(args []Token) (*ExplodeStatement, error) {
+	p := &tokenParser{tokens: args}
+	column, err := p.valueArg("column")
+	if err != nil {
+		return nil, err
+	}
+	sep, err := p.keywordArg("by", "separator")
+	if err != nil {
+		return nil, err
+	}
+	if tok, ok := p.next(); ok {
+		return nil, p.unexpected(tok, "end of explode statement")
+	}
+	return &ExplodeStatement{Column: column, Sep: sep}, nil
+}
+
+// Implement the RowStatement interface for all record-level statements.
+// Blocking statements, such as sort and group, buffer records until Flush.
+
+func (s *SelectStatement) Rows() converters.RowConverter {
+	return converters.SelectCastRows(s.Columns, s.OnError)
+}
+
+func (s *NumberStatement) Rows() converters.RowConverter {
+	return converters.NumberRows(s.Column, s.From, s.To)
+}
+
+func (s *NumbersStatement) Rows() converters.RowConverter {
+	return converters.NumbersRows(s.From, s.To)
+}
+
+func (s *DateStatement) Rows() converters.RowConverter {
+	return converters.DateRows(s.Column, s.From, s.To)
+}
+
+func (s *DatesStatement) Rows() converters.RowConverter {
+	return converters.DatesRows(s.From, s.To)
+}
+
+func (s *FilterStatement) Rows() converters.RowConverter {
+	return converters.WhereRows(s.Condition)
+}
+
+func (s *SetStatement) Rows() converters.RowConverter {
+	return converters.SetRows(s.Number, s.Assignments...)
+}
+
+func (s *SortStatement) Rows() converters.RowConverter {
+	return converters.SortRows(s.Keys, s.Memory)
+}
+
+func (s *HeadStatement) Rows() converters.RowConverter {
+	return converters.HeadRows(s.Count)
+}
+
+func (s *TailStatement) Rows() converters.RowConverter {
+	return converters.TailRows(s.Count)
+}
+
+func (s *SkipStatement) Rows() converters.RowConverter {
+	return converters.SkipRows(s.Count)
+}
+
+func (s *DistinctStatement) Rows() converters.RowConverter {
+	return converters.DistinctRows(s.Columns...)
+}
+
+func (s *DedupeStatement) Rows() converters.RowConverter {
+	return converters.DedupeRows(s.By, s.KeepLast)
+}
+
+func (s *SampleStatement) Rows() converters.RowConverter {
+	return converters.SampleRows(s.Count, s.Seed)
+}
+
+func (s *DropStatement) Rows() converters.RowConverter {
+	return converters.DropRows(s.Columns)
+}
+
+func (s *RenameStatement) Rows() converters.RowConverter {
+	return converters.RenameRows(s.Columns)
+}
+
+func (s *MoveStatement) Rows() converters.RowConverter {
+	return converters.MoveRows(s.Columns, s.Place, s.Anchor)
+}
+
+func (s *HeaderStatement) Rows() converters.RowConverter {
+	return converters.HeaderRows(s.Names, s.Insert)
+}
+
+func (s *PivotStatement) Rows() converters.RowConverter {
+	return converters.PivotRows(s.Keys, s.By, s.Value, s.Func, s.Number)
+}
+
+func (s *UnpivotStatement) Rows() converters.RowConverter {
+	return converters.UnpivotRows(s.Columns, s.Name, s.Value)
+}
+
+func (s *ExtractStatement) Rows() converters.RowConverter {
+	return converters.ExtractRows(s.Column, s.Pattern)
+}
+
+func (s *SplitStatement) Rows() converters.RowConverter {
+	return converters.SplitRows(s.Column, s.Sep, s.Into)
+}
+
+func (s *ExplodeStatement) Rows() converters.RowConverter {
+	return converters.ExplodeRows(s.Column, s.Sep)
+}
+
+func (s *GroupStatement) Rows() converters.RowConverter {
+	return converters.GroupRows(s.By, s.Aggregates, s.Number)
+}
+
+func (s *JoinStatement) Rows() converters.RowConverter {
+	load := func() (converters.Records, error) {
+		if s.Read == nil {
+			return nil, fmt.Errorf("%w: no table reader for %q", ErrInvalidArgs, s.File)
+		}
+		return s.Read(s.File)
+	}
+	name := strings.TrimSuffix(filepath.Base(s.File), filepath.Ext(s.File))
+	return converters.JoinRows(load, s.Keys, s.Kind, name)
 }
 
 // Implement the Statement interface for each statement type.
@@ -256,7 +1265,7 @@ func NewSortStatement(args []string, opts ...string) *SortStatement {
 
 func (s *SelectStatement) Converter() converters.Converter {
 	fn := func(records converters.Records) (converters.Records, error) {
-		return converters.SelectColumns(records, s.Columns)
+		return converters.ApplyRows(records, converters.SelectCastRows(s.Columns, s.OnError))
 	}
 	return fn
 }
@@ -291,14 +1300,140 @@ func (s *DatesStatement) Converter() converters.Converter {
 
 func (s *FilterStatement) Converter() converters.Converter {
 	fn := func(records converters.Records) (converters.Records, error) {
-		return converters.Filter(records, s.Column.Name, s.Op, s.Value)
+		return converters.Where(records, s.Condition)
+	}
+	return fn
+}
+
+func (s *SetStatement) Converter() converters.Converter {
+	fn := func(records converters.Records) (converters.Records, error) {
+		return converters.SetColumns(records, s.Number, s.Assignments...)
+	}
+	return fn
+}
+
+func (s *GroupStatement) Converter() converters.Converter {
+	fn := func(records converters.Records) (converters.Records, error) {
+		return converters.GroupBy(records, s.By, s.Aggregates, s.Number)
+	}
+	return fn
+}
+
+func (s *JoinStatement) Converter() converters.Converter {
+	fn := func(records converters.Records) (converters.Records, error) {
+		return converters.ApplyRows(records, s.Rows())
 	}
 	return fn
 }
 
 func (s *SortStatement) Converter() converters.Converter {
 	fn := func(records converters.Records) (converters.Records, error) {
-		return converters.Sort(records, s.Column, s.Asc, s.NumberFormat)
+		return converters.ApplyRows(records, s.Rows())
+	}
+	return fn
+}
+
+func (s *HeadStatement) Converter() converters.Converter {
+	fn := func(records converters.Records) (converters.Records, error) {
+		return records.Head(s.Count), nil
+	}
+	return fn
+}
+
+func (s *TailStatement) Converter() converters.Converter {
+	fn := func(records converters.Records) (converters.Records, error) {
+		return records.Tail(s.Count), nil
+	}
+	return fn
+}
+
+func (s *SkipStatement) Converter() converters.Converter {
+	fn := func(records converters.Records) (converters.Records, error) {
+		return converters.ApplyRows(records, s.Rows())
+	}
+	return fn
+}
+
+func (s *DistinctStatement) Converter() converters.Converter {
+	fn := func(records converters.Records) (converters.Records, error) {
+		return converters.ApplyRows(records, s.Rows())
+	}
+	return fn
+}
+
+func (s *DedupeStatement) Converter() converters.Converter {
+	fn := func(records converters.Records) (converters.Records, error) {
+		return converters.ApplyRows(records, s.Rows())
+	}
+	return fn
+}
+
+func (s *SampleStatement) Converter() converters.Converter {
+	fn := func(records converters.Records) (converters.Records, error) {
+		return converters.ApplyRows(records, s.Rows())
+	}
+	return fn
+}
+
+func (s *DropStatement) Converter() converters.Converter {
+	fn := func(records converters.Records) (converters.Records, error) {
+		return converters.ApplyRows(records, s.Rows())
+	}
+	return fn
+}
+
+func (s *RenameStatement) Converter() converters.Converter {
+	fn := func(records converters.Records) (converters.Records, error) {
+		return converters.ApplyRows(records, s.Rows())
+	}
+	return fn
+}
+
+func (s *MoveStatement) Converter() converters.Converter {
+	fn := func(records converters.Records) (converters.Records, error) {
+		return converters.ApplyRows(records, s.Rows())
+	}
+	return fn
+}
+
+func (s *HeaderStatement) Converter() converters.Converter {
+	fn := func(records converters.Records) (converters.Records, error) {
+		return converters.ApplyRows(records, s.Rows())
+	}
+	return fn
+}
+
+func (s *PivotStatement) Converter() converters.Converter {
+	fn := func(records converters.Records) (converters.Records, error) {
+		return converters.Pivot(records, s.Keys, s.By, s.Value, s.Func, s.Number)
+	}
+	return fn
+}
+
+func (s *UnpivotStatement) Converter() converters.Converter {
+	fn := func(records converters.Records) (converters.Records, error) {
+		return converters.Unpivot(records, s.Columns, s.Name, s.Value)
+	}
+	return fn
+}
+
+func (s *ExtractStatement) Converter() converters.Converter {
+	fn := func(records converters.Records) (converters.Records, error) {
+		return converters.ApplyRows(records, s.Rows())
+	}
+	return fn
+}
+
+func (s *SplitStatement) Converter() converters.Converter {
+	fn := func(records converters.Records) (converters.Records, error) {
+		return converters.ApplyRows(records, s.Rows())
+	}
+	return fn
+}
+
+func (s *ExplodeStatement) Converter() converters.Converter {
+	fn := func(records converters.Records) (converters.Records, error) {
+		return converters.ApplyRows(records, s.Rows())
 	}
 	return fn
 }
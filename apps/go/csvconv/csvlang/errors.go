@@ -0,0 +1,99 @@
+package csvlang
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrUnknownStatement = errors.New("unknown statement")
+	ErrEmptyStatement   = errors.New("empty statement")
+	ErrInvalidArgs      = errors.New("invalid arguments")
+	ErrInvalidOptions   = errors.New("invalid options")
+	ErrUnknownOperator  = errors.New("unknown operator")
+	ErrUnexpectedToken  = errors.New("unexpected token")
+	ErrUnknownVariable  = errors.New("unknown variable")
+)
+
+// SyntaxError describes an invalid statement in a csvlang program.
+type SyntaxError struct {
+	Program   string // full program text
+	Statement int    // 0-based index of the statement in the program
+	Offset    int    // byte offset of the error in the program text
+	Err       error
+}
+
+func (e *SyntaxError) Error() string {
+	if strings.Contains(e.Program, "\n") {
+		return fmt.Sprintf("syntax error in statement %d at line %d, column %d: %v", e.Statement+1, e.Line(), e.Column(), e.Err)
+	}
+	return fmt.Sprintf("syntax error in statement %d at column %d: %v", e.Statement+1, e.Column(), e.Err)
+}
+
+func (e *SyntaxError) Unwrap() error { return e.Err }
+
+// Line returns the 1-based line of the error in the program text.
+func (e *SyntaxError) Line() int {
+	return strings.Count(e.Program[:e.offset()], "\n") + 1
+}
+
+// Column returns the 1-based column of the error in its line of the program text.
+func (e *SyntaxError) Column() int {
+	start, _ := e.lineBounds()
+	return utf8.RuneCountInString(e.Program[start:e.offset()]) + 1
+}
+
+// Snippet returns the line of the program text with a caret pointing to the error.
+//
+//	select a | foo b
+//	           ^
+func (e *SyntaxError) Snippet() string {
+	start, end := e.lineBounds()
+	return strings.TrimSuffix(e.Program[start:end], "\r") + "\n" + strings.Repeat(" ", e.Column()-1) + "^"
+}
+
+// offset returns the error offset clamped to the program text.
+func (e *SyntaxError) offset() int {
+	return min(max(e.Offset, 0), len(e.Program))
+}
+
+// lineBounds returns the start and end offsets of the line of the error.
+func (e *SyntaxError) lineBounds() (start, end int) {
+	offset := e.offset()
+	start = strings.LastIndexByte(e.Program[:offset], '\n') + 1
+	end = len(e.Program)
+	if i := strings.IndexByte(e.Program[offset:], '\n'); i >= 0 {
+		end = offset + i
+	}
+	return start, end
+}
+
+// syntaxError wraps err with position information of a statement.
+func syntaxError(program string, stmt int, offset int, err error) error {
+	return &SyntaxError{Program: program, Statement: stmt, Offset: offset, Err: err}
+}
+
+// posError is an error at a specific position in the program text.
+type posError struct {
+	pos int
+	err error
+}
+
+func (e *posError) Error() string { return e.err.Error() }
+func (e *posError) Unwrap() error { return e.err }
+
+// errorAt returns an error that points to the given position in the program text.
+func errorAt(pos int, err error) error {
+	return &posError{pos: pos, err: err}
+}
+
+// errorPos returns the position of err, or def if err has no position.
+func errorPos(err error, def int) int {
+	var pe *posError
+	if errors.As(err, &pe) {
+		return pe.pos
+	}
+	return def
+}
@@ -0,0 +1,179 @@
+package csvlang
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"ubunatic.com/dotapps/go/csvconv/converters"
+)
+
+// Expression Grammar
+// ==================
+// Set statements compute column values using expressions:
+//
+//	assignments := column "=" expr { "," column "=" expr }
+//	expr        := sum { "&" sum }
+//	sum         := term { ( "+" | "-" ) term }
+//	term        := unary { ( "*" | "/" | "%" ) unary }
+//	unary       := "-" unary | primary
+//	primary     := number | 'string' | column | func "(" [ expr { "," expr } ] ")" | "(" expr ")"
+//
+// Operators must be separated by spaces, since column names may contain them.
+//
+// Example:
+//
+//	set total = price * qty, label = upper(name) & ' (' & year(date) & ')'
+
+// exprParser is a recursive descent parser for value expressions.
+type exprParser struct {
+	tokenParser
+}
+
+// ParseExpr parses a value expression.
+func ParseExpr(tokens []Token) (converters.Expr, error) {
+	p := &exprParser{tokenParser{tokens: tokens}}
+	expr, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if tok, ok := p.peek(); ok {
+		return nil, p.unexpected(tok, "operator")
+	}
+	return expr, nil
+}
+
+// ParseAssignments parses a list of assignments, such as "a = b * 2, c = upper(d)".
+func ParseAssignments(tokens []Token) ([]converters.Assignment, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w: set statement requires an assignment", ErrInvalidArgs)
+	}
+	p := &exprParser{tokenParser{tokens: tokens}}
+	result := []converters.Assignment{}
+	for {
+		col, ok := p.next()
+		if !ok {
+			return nil, p.endError("column")
+		}
+		if !col.IsValue() {
+			return nil, p.unexpected(col, "column")
+		}
+		if !p.accept(TokenOp, "=") {
+			if tok, ok := p.peek(); ok {
+				return nil, p.unexpected(tok, "'='")
+			}
+			return nil, p.endError("'='")
+		}
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, converters.Assignment{Column: col.Text, Expr: expr})
+		if p.accept(TokenComma) {
+			continue
+		}
+		if tok, ok := p.peek(); ok {
+			return nil, p.unexpected(tok, "operator or ','")
+		}
+		return result, nil
+	}
+}
+
+// parseBinary parses a left-associative chain of operands joined by the given operators.
+func (p *exprParser) parseBinary(operand func() (converters.Expr, error), ops ...string) (converters.Expr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.peek()
+		if !ok || !op.Is(TokenWord, ops...) {
+			return left, nil
+		}
+		p.pos++
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &converters.Binary{Op: op.Text, Left: left, Right: right}
+	}
+}
+
+func (p *exprParser) parseExpr() (converters.Expr, error) {
+	return p.parseBinary(p.parseSum, "&")
+}
+
+func (p *exprParser) parseSum() (converters.Expr, error) {
+	return p.parseBinary(p.parseTerm, "+", "-")
+}
+
+func (p *exprParser) parseTerm() (converters.Expr, error) {
+	return p.parseBinary(p.parseUnary, "*", "/", "%")
+}
+
+func (p *exprParser) parseUnary() (converters.Expr, error) {
+	if p.accept(TokenWord, "-") {
+		expr, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &converters.Binary{Op: "-", Left: converters.Literal("0"), Right: expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (converters.Expr, error) {
+	tok, ok := p.next()
+	if !ok {
+		return nil, p.endError("value")
+	}
+	switch {
+	case tok.Is(TokenLParen):
+		expr, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(TokenRParen, "')'"); err != nil {
+			return nil, err
+		}
+		return expr, nil
+	case tok.Is(TokenString):
+		return converters.Literal(tok.Text), nil
+	case tok.Is(TokenIdent):
+		return converters.Ref(tok.Text), nil
+	case !tok.Is(TokenWord):
+		return nil, p.unexpected(tok, "value")
+	case p.accept(TokenLParen):
+		return p.parseCall(tok)
+	}
+	if _, err := strconv.ParseFloat(tok.Text, 64); err == nil {
+		return converters.Literal(tok.Text), nil
+	}
+	return converters.Ref(tok.Text), nil
+}
+
+// parseCall parses the arguments of a function call after the opening parenthesis.
+func (p *exprParser) parseCall(fn Token) (converters.Expr, error) {
+	def, ok := converters.Functions[strings.ToLower(fn.Text)]
+	if !ok {
+		return nil, errorAt(fn.Pos, fmt.Errorf("%w: %q", converters.ErrUnknownFunction, fn.Text))
+	}
+	call := &converters.Call{Func: fn.Text}
+	for !p.accept(TokenRParen) {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		call.Args = append(call.Args, arg)
+		if !p.accept(TokenComma) {
+			if err := p.expect(TokenRParen, "',' or ')'"); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	if n := len(call.Args); n < def.MinArgs || (def.MaxArgs >= 0 && n > def.MaxArgs) {
+		return nil, errorAt(fn.Pos, fmt.Errorf("%w: wrong number of arguments for %s: %d", ErrInvalidArgs, fn.Text, n))
+	}
+	return call, nil
+}
@@ -0,0 +1,243 @@
+package csvlang
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type TokenKind int
+
+const (
+	TokenWord   TokenKind = iota // unquoted word, e.g., keyword, column name, number
+	TokenString                  // single-quoted string, e.g., 'a | b'
+	TokenIdent                   // double-quoted identifier, e.g., "Last, First"
+	TokenOp                      // comparison or rename operator, e.g., >=, ->
+	TokenComma                   // ,
+	TokenLParen                  // (
+	TokenRParen                  // )
+	TokenPipe                    // |
+)
+
+var ErrUnterminatedQuote = errors.New("unterminated quote")
+
+// Token is a lexical token of a csvlang program.
+type Token struct {
+	Kind TokenKind
+	Text string // unquoted and unescaped text
+	Pos  int    // byte offset of the token in the program text
+	End  int    // byte offset after the token
+}
+
+// Is reports whether the token is of the given kind and, if texts are given,
+// matches one of the texts. Words are matched case-insensitively.
+func (t Token) Is(kind TokenKind, texts ...string) bool {
+	if t.Kind != kind {
+		return false
+	}
+	if len(texts) == 0 {
+		return true
+	}
+	for _, text := range texts {
+		if t.Text == text || (kind == TokenWord && strings.EqualFold(t.Text, text)) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValue reports whether the token is a word, string, or identifier.
+func (t Token) IsValue() bool {
+	return t.Kind == TokenWord || t.Kind == TokenString || t.Kind == TokenIdent
+}
+
+// Texts returns the texts of the tokens.
+func Texts(tokens []Token) []string {
+	texts := make([]string, len(tokens))
+	for i, tok := range tokens {
+		texts[i] = tok.Text
+	}
+	return texts
+}
+
+var punctuation = map[rune]TokenKind{',': TokenComma, '(': TokenLParen, ')': TokenRParen, '|': TokenPipe}
+
+// operators are matched longest first.
+var operators = []string{"->", "==", "!=", "<>", "<=", ">=", "!~", "=", "<", ">", "~"}
+
+// Lex splits a csvlang program into tokens.
+//
+// Whitespace separates tokens and is otherwise ignored.
+// Single quotes delimit string values and double quotes delimit identifiers,
+// such as column names with spaces, commas, or pipes.
+// Inside quotes, a backslash escapes the quote character or another backslash.
+// Other backslashes are kept, so that regular expressions can be written as is.
+// Outside quotes, a backslash escapes the next character.
+// Words that start with a slash-delimited pattern, such as /^(net|tax)\s/ or /IBAN: (\S+)/,
+// keep the pattern as is, including spaces, backslashes, pipes, and parentheses.
+//
+// A "#" at the start of a line, after optional indentation, starts a comment that ends
+// at the end of the line. Elsewhere, "#" is part of a word, e.g., in `set tag = #1`,
+// see [LexFile] for comments after statements.
+// A line that starts with a statement separates it from the previous statement,
+// like a pipe, while indented lines and lines starting with a pipe continue it.
+//
+// On error, Lex returns the tokens read so far.
+func Lex(program string) ([]Token, error) {
+	return lex(program, 0)
+}
+
+// LexFile splits a csvlang program of a query file into tokens like [Lex],
+// but a "#" after whitespace also starts a comment, e.g., in `select a # columns`.
+func LexFile(program string) ([]Token, error) {
+	return lex(program, len(program))
+}
+
+// lex splits the program into tokens, where the query file ends at fileEnd, see [LexFile].
+func lex(program string, fileEnd int) ([]Token, error) {
+	tokens := []Token{}
+	for pos := 0; pos < len(program); {
+		r, size := utf8.DecodeRuneInString(program[pos:])
+		start := pos
+
+		if r == '#' && isComment(program, pos, fileEnd) {
+			if end := strings.IndexByte(program[pos:], '\n'); end >= 0 {
+				pos += end
+			} else {
+				pos = len(program)
+			}
+			continue
+		}
+
+		if r == '\n' && len(tokens) > 0 && !tokens[len(tokens)-1].Is(TokenPipe) && startsStatement(program[pos+size:]) {
+			tokens = append(tokens, Token{Kind: TokenPipe, Text: "\n", Pos: start, End: start + size})
+		}
+
+		if unicode.IsSpace(r) {
+			pos += size
+			continue
+		}
+
+		if kind, ok := punctuation[r]; ok {
+			pos += size
+			tokens = append(tokens, Token{Kind: kind, Text: string(r), Pos: start, End: pos})
+			continue
+		}
+
+		if op := matchOperator(program[pos:]); op != "" {
+			pos += len(op)
+			tokens = append(tokens, Token{Kind: TokenOp, Text: op, Pos: start, End: pos})
+			continue
+		}
+
+		if r == '\'' || r == '"' {
+			text, end, ok := lexQuoted(program, pos)
+			if !ok {
+				return tokens, errorAt(start, ErrUnterminatedQuote)
+			}
+			kind := TokenString
+			if r == '"' {
+				kind = TokenIdent
+			}
+			pos = end
+			tokens = append(tokens, Token{Kind: kind, Text: text, Pos: start, End: pos})
+			continue
+		}
+
+		text, end := lexWord(program, pos)
+		pos = end
+		tokens = append(tokens, Token{Kind: TokenWord, Text: text, Pos: start, End: pos})
+	}
+	return tokens, nil
+}
+
+// isComment reports whether the "#" at pos starts a comment, i.e., it starts a line,
+// or it follows whitespace in the query file that ends at fileEnd.
+func isComment(program string, pos, fileEnd int) bool {
+	line := program[strings.LastIndexByte(program[:pos], '\n')+1 : pos]
+	if strings.TrimSpace(line) == "" {
+		return true
+	}
+	r, _ := utf8.DecodeLastRuneInString(line)
+	return pos < fileEnd && unicode.IsSpace(r)
+}
+
+// startsStatement reports whether the line starts with a new statement,
+// i.e., it is not indented, empty, a comment, or a continuation with a pipe.
+func startsStatement(line string) bool {
+	r, _ := utf8.DecodeRuneInString(line)
+	return line != "" && !unicode.IsSpace(r) && r != '|' && r != '#'
+}
+
+func matchOperator(s string) string {
+	for _, op := range operators {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// lexQuoted reads a quoted text starting at pos and returns the unescaped text
+// and the offset after the closing quote.
+func lexQuoted(program string, pos int) (text string, end int, ok bool) {
+	quote := program[pos]
+	var sb strings.Builder
+	for i := pos + 1; i < len(program); i++ {
+		c := program[i]
+		switch {
+		case c == '\\' && i+1 < len(program) && (program[i+1] == quote || program[i+1] == '\\'):
+			i++
+			sb.WriteByte(program[i])
+		case c == quote:
+			return sb.String(), i + 1, true
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return "", len(program), false
+}
+
+// lexWord reads an unquoted word starting at pos and returns the unescaped text
+// and the offset after the word.
+func lexWord(program string, pos int) (text string, end int) {
+	var sb strings.Builder
+	if n := lexPattern(program[pos:]); n > 0 {
+		sb.WriteString(program[pos : pos+n])
+		pos += n
+	}
+	for pos < len(program) {
+		r, size := utf8.DecodeRuneInString(program[pos:])
+		if r == '\\' && pos+size < len(program) {
+			next, nextSize := utf8.DecodeRuneInString(program[pos+size:])
+			sb.WriteRune(next)
+			pos += size + nextSize
+			continue
+		}
+		if unicode.IsSpace(r) || strings.ContainsRune(`,()|'"`, r) || matchOperator(program[pos:]) != "" {
+			break
+		}
+		sb.WriteRune(r)
+		pos += size
+	}
+	return sb.String(), pos
+}
+
+// lexPattern returns the length of a leading pattern such as /^a\/b/ or /IBAN: (\S+)/,
+// or 0 if the text does not start with a pattern. Patterns do not start or end
+// with a space, so that divisions such as "a / b / c" are not patterns.
+func lexPattern(text string) int {
+	if len(text) < 3 || text[0] != '/' || text[1] == '/' || unicode.IsSpace(rune(text[1])) {
+		return 0
+	}
+	for i := 1; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '\\':
+			i++
+		case c == '/' && !unicode.IsSpace(rune(text[i-1])):
+			return i + 1
+		}
+	}
+	return 0
+}
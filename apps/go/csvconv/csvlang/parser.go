@@ -1,71 +1,217 @@
 package csvlang
 
 import (
+	"fmt"
 	"log/slog"
+	"maps"
 	"strings"
-
-	"ubunatic.com/dotapps/go/csvconv/converters"
 )
 
-// Parse parses the CSV transformation language into a sequence of [converters.Converter].
-func Parse(program ...string) []Statement {
+// Parse parses the CSV transformation language into a sequence of [Statement].
+// Invalid statements are reported as [*SyntaxError].
+func Parse(program ...string) ([]Statement, error) {
+	return ParseVars(nil, program...)
+}
+
+// ParseVars parses the program like [Parse] with predefined variables, which can be
+// overridden by `let` statements and take precedence over environment variables.
+func ParseVars(vars Vars, program ...string) ([]Statement, error) {
+	return parse(vars, strings.Join(program, " "), 0)
+}
+
+// ParseFile parses the program of a query file like [ParseVars], followed by the args,
+// which start on a new line and thus with a new statement.
+// Comments of the file can follow statements, see [LexFile].
+func ParseFile(vars Vars, file string, args ...string) ([]Statement, error) {
+	if file == "" {
+		return ParseVars(vars, args...)
+	}
+	return parse(vars, file+"\n"+strings.Join(args, " "), len(file))
+}
+
+// parse parses the program, where the query file ends at fileEnd, see [LexFile].
+func parse(vars Vars, prg string, fileEnd int) ([]Statement, error) {
+	vars = maps.Clone(vars)
+	if vars == nil {
+		vars = Vars{}
+	}
+
+	tokens, err := lex(prg, fileEnd)
+	if err != nil {
+		return nil, syntaxError(prg, countPipes(tokens), errorPos(err, len(prg)), err)
+	}
+
 	statements := []Statement{}
-	for _, tup := range parseStatements(program...) {
-		var stmt Statement
-		switch tup.keyword {
-		case "select", "sel", "get":
-			stmt = NewSelectStatement(tup.args...)
-		case "number", "num":
-			stmt = NewNumberStatement(tup.args, tup.options...)
-		case "date":
-			stmt = NewDateStatement(tup.args, tup.options...)
-		case "numbers", "nums":
-			stmt = NewNumbersStatement(tup.args...)
-		case "dates":
-			stmt = NewDatesStatement(tup.args...)
-		case "filter", "and", "where", "if":
-			stmt = NewFilterStatement(tup.args)
-		case "sort", "order":
-			stmt = NewSortStatement(tup.args, tup.options...)
-		default:
-			panic("unknown statement: " + tup.keyword)
+	for idx, cmd := range parseCommands(tokens) {
+		stmt, err := parseVarStatement(cmd, vars)
+		if err != nil {
+			slog.Debug("invalid statement", "command", cmd, "error", err)
+			return nil, syntaxError(prg, idx, errorPos(err, cmd.Pos), err)
+		}
+		if stmt != nil {
+			statements = append(statements, stmt)
+		}
+	}
+	return statements, nil
+}
+
+// parseVarStatement expands the variables of the command and parses the statement.
+// A `let` statement defines a variable and returns no statement.
+func parseVarStatement(cmd Command, vars Vars) (Statement, error) {
+	cmd, err := expandCommand(cmd, vars)
+	if err != nil {
+		return nil, err
+	}
+	if cmd.Keyword == "let" {
+		return nil, parseLet(cmd, vars)
+	}
+	return parseStatement(cmd)
+}
+
+// Keywords are the statement keywords, including aliases, and the `let` keyword.
+var Keywords = []string{
+	"select", "sel", "get", "number", "num", "date", "numbers", "nums", "dates",
+	"filter", "and", "where", "if", "set", "add", "group", "pivot", "unpivot", "melt",
+	"join", "sort", "order", "extract", "split", "explode", "drop", "rename", "move", "header",
+	"head", "limit", "tail", "skip", "offset", "distinct", "unique", "uniq", "dedupe", "dedup",
+	"sample", "let",
+}
+
+// ArgKeywords are the keywords used in statement arguments, e.g., "as" or "desc".
+var ArgKeywords = []string{
+	"as", "by", "agg", "value", "into", "except", "on", "inner", "left", "anti",
+	"asc", "desc", "nulls", "first", "last", "before", "after", "keep",
+	"and", "or", "not", "in", "is", "like", "null",
+}
+
+func parseStatement(cmd Command) (Statement, error) {
+	switch cmd.Keyword {
+	case "select", "sel", "get":
+		return NewSelectStatement(cmd.Args, cmd.Options...)
+	case "number", "num":
+		return NewNumberStatement(cmd.Args, cmd.Options...)
+	case "date":
+		return NewDateStatement(cmd.Args, cmd.Options...)
+	case "numbers", "nums":
+		return NewNumbersStatement(cmd.Args...)
+	case "dates":
+		return NewDatesStatement(cmd.Args...)
+	case "filter", "and", "where", "if":
+		return NewFilterStatement(cmd.Args, cmd.Options...)
+	case "set", "add":
+		return NewSetStatement(cmd.Args, cmd.Options...)
+	case "group":
+		return NewGroupStatement(cmd.Args, cmd.Options...)
+	case "pivot":
+		return NewPivotStatement(cmd.Args, cmd.Options...)
+	case "unpivot", "melt":
+		return NewUnpivotStatement(cmd.Args)
+	case "join":
+		return NewJoinStatement(cmd.Args)
+	case "sort", "order":
+		return NewSortStatement(cmd.Args, cmd.Options...)
+	case "extract":
+		return NewExtractStatement(cmd.Args)
+	case "split":
+		return NewSplitStatement(cmd.Args)
+	case "explode":
+		return NewExplodeStatement(cmd.Args)
+	case "drop":
+		return NewDropStatement(cmd.Args)
+	case "rename":
+		return NewRenameStatement(cmd.Args)
+	case "move":
+		return NewMoveStatement(cmd.Args)
+	case "header":
+		return NewHeaderStatement(cmd.Args, cmd.Options...)
+	case "head", "limit":
+		return NewHeadStatement(cmd.Args)
+	case "tail":
+		return NewTailStatement(cmd.Args)
+	case "skip", "offset":
+		return NewSkipStatement(cmd.Args)
+	case "distinct", "unique", "uniq":
+		return NewDistinctStatement(cmd.Args)
+	case "dedupe", "dedup":
+		return NewDedupeStatement(cmd.Args)
+	case "sample":
+		return NewSampleStatement(cmd.Args)
+	case "":
+		if len(cmd.Args) > 0 {
+			tok := cmd.Args[0]
+			return nil, errorAt(tok.Pos, fmt.Errorf("%w: %q, expected statement keyword", ErrUnexpectedToken, tok.Text))
 		}
-		statements = append(statements, stmt)
+		return nil, ErrEmptyStatement
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownStatement, cmd.Keyword)
 	}
-	return statements
 }
 
-type statementTuple struct {
-	keyword string
-	options []string
-	args    []string
+// Command is the syntax tree of a single statement,
+// e.g., "number:dot:comma amount" or "select a, b as c".
+type Command struct {
+	Keyword string   // lower-case statement keyword, e.g., "number"
+	Options []string // keyword options in original case, e.g., ["dot", "comma"]
+	Args    []Token  // argument tokens after the keyword
+	Pos     int      // byte offset of the statement in the program text
 }
 
-// parseStatements parses a Csvlang program into a sequence of statement texts.
-func parseStatements(program ...string) []statementTuple {
-	prg := strings.Join(program, " ")
-	statements := []statementTuple{}
-	for _, line := range converters.TrimSplit(prg, "|") {
-		tuple := parseTuple(line)
-		slog.Debug("parsed statement", "tuple", tuple, "len", len(tuple.args))
-		statements = append(statements, tuple)
+// parseCommands splits the tokens of a csvlang program into commands.
+func parseCommands(tokens []Token) []Command {
+	commands := []Command{}
+	if len(tokens) == 0 {
+		return commands
 	}
-	return statements
+
+	pos := 0 // start of the current statement
+	stmt := []Token{}
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) && !tokens[i].Is(TokenPipe) {
+			stmt = append(stmt, tokens[i])
+			continue
+		}
+		cmd := parseCommand(stmt, pos)
+		slog.Debug("parsed statement", "command", cmd, "len", len(cmd.Args))
+		commands = append(commands, cmd)
+		if i < len(tokens) {
+			pos = tokens[i].End
+			if i+1 < len(tokens) {
+				pos = tokens[i+1].Pos
+			}
+		}
+		stmt = []Token{}
+	}
+	return commands
 }
 
-// parseTuple parses a statement text into a statement tuple.
-func parseTuple(text string) statementTuple {
-	words := strings.Split(text, " ")
-	keyword := strings.ToLower(words[0])   // example: "select", "number:float"
-	kwParts := strings.Split(keyword, ":") // example: ["select"], ["number", "float"]
-	tuple := statementTuple{
-		keyword: kwParts[0],
+// parseCommand parses the tokens of a statement into a command.
+func parseCommand(tokens []Token, pos int) Command {
+	if len(tokens) == 0 {
+		return Command{Pos: pos}
+	}
+	keyword := tokens[0]
+	kwParts := strings.Split(keyword.Text, ":") // example: ["select"], ["number", "float"]
+	cmd := Command{
+		Keyword: strings.ToLower(kwParts[0]),
+		Args:    tokens[1:],
+		Pos:     keyword.Pos,
+	}
+	if !keyword.Is(TokenWord) {
+		return Command{Args: tokens, Pos: keyword.Pos} // not a keyword
 	}
 	if len(kwParts) > 1 { // keyword has options
-		tuple.options = kwParts[1:]
+		cmd.Options = kwParts[1:]
 	}
-	if len(words) > 1 { // statement has multiple args
-		tuple.args = words[1:]
+	return cmd
+}
+
+// countPipes returns the number of pipe tokens, i.e., the index of the last statement.
+func countPipes(tokens []Token) int {
+	n := 0
+	for _, tok := range tokens {
+		if tok.Is(TokenPipe) {
+			n++
+		}
 	}
-	return tuple
+	return n
 }
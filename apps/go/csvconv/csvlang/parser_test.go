@@ -2,6 +2,8 @@ package csvlang
 
 import (
 	"log/slog"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -31,6 +33,7 @@ func TestSlices(t *testing.T) {
 }
 
 func TestParse(t *testing.T) {
+	seed := uint64(0) // zero is a valid seed
 	tests := []struct {
 		program string
 		expect  Statement
@@ -48,21 +51,233 @@ func TestParse(t *testing.T) {
 			program: "date:iso col1",
 			expect:  &DateStatement{Column: "col1", From: converters.DateAny, To: converters.DateISO},
 		},
+		{
+			program: `date:%d.%m.%Y\ %H:%M:rfc3339@UTC col1`,
+			expect:  &DateStatement{Column: "col1", From: "02.01.2006 15:04", To: "rfc3339@UTC"},
+		},
+		{
+			program: "dates US dot",
+			expect:  &DatesStatement{From: converters.DateUS, To: converters.DateDot},
+		},
 		{
 			program: "numbers",
 			expect:  &NumbersStatement{From: converters.NumberDot, To: converters.NumberDot},
 		},
+		{
+			program: "sort:desc:num amount",
+			expect: &SortStatement{Keys: []converters.SortKey{
+				{Column: "amount", Desc: true, Kind: converters.SortNumber, Number: converters.NumberDot},
+			}, Memory: converters.DefaultSortMemory},
+		},
+		{
+			program: `sort date:iso desc, amount:num:comma asc nulls first, "Last, First":natural`,
+			expect: &SortStatement{Keys: []converters.SortKey{
+				{Column: "date", Desc: true, Kind: converters.SortDate, Date: converters.DateISO},
+				{Column: "amount", Kind: converters.SortNumber, Number: converters.NumberComma, NullsFirst: true},
+				{Column: "Last, First", Kind: converters.SortNatural},
+			}, Memory: converters.DefaultSortMemory},
+		},
+		{
+			program: `select * except id, "Last, First" | select /^amount_(.*)/:num:comma as $1`,
+			expects: []Statement{
+				&SelectStatement{Columns: []converters.Column{
+					{Name: "*"},
+					{Name: "id", Exclude: true},
+					{Name: "Last, First", Exclude: true},
+				}},
+				&SelectStatement{Columns: []converters.Column{{Name: "/^amount_(.*)/", Type: "num", Cast: "comma", Rename: "$1"}}},
+			},
+		},
+		{
+			program: `drop a, /^tmp_/ | rename /\s+/ _, a as b, "c d" -> e | move total, sum first | move x after "y z"`,
+			expects: []Statement{
+				&DropStatement{Columns: Cols("a", "/^tmp_/")},
+				&RenameStatement{Columns: []converters.Column{
+					{Name: `/\s+/`, Rename: "_"},
+					{Name: "a", Rename: "b"},
+					{Name: "c d", Rename: "e"},
+				}},
+				&MoveStatement{Columns: Cols("total", "sum"), Place: converters.PlaceFirst},
+				&MoveStatement{Columns: Cols("x"), Place: converters.PlaceAfter, Anchor: "y z"},
+			},
+		},
+		{
+			program: "header:insert a, b | header c",
+			expects: []Statement{
+				&HeaderStatement{Names: []string{"a", "b"}, Insert: true},
+				&HeaderStatement{Names: []string{"c"}},
+			},
+		},
+		{
+			program: "pivot:de date, account by category value amount agg max | pivot by c value v | unpivot food, /^rent/ into category, amount | melt a",
+			expects: []Statement{
+				&PivotStatement{Keys: []string{"date", "account"}, By: "category", Value: "amount", Func: "max", Number: converters.NumberComma},
+				&PivotStatement{By: "c", Value: "v", Func: "sum", Number: converters.NumberDot},
+				&UnpivotStatement{Columns: Cols("food", "/^rent/"), Name: "category", Value: "amount"},
+				&UnpivotStatement{Columns: Cols("a"), Name: "name", Value: "value"},
+			},
+		},
+		{
+			program: `extract memo /IBAN: (?P<iban>\S+)/ | split name by ' ' into first, "last name" | explode tags by /;\s*/ | extract a '(?P<b>.)'`,
+			expects: []Statement{
+				&ExtractStatement{Column: "memo", Pattern: `/IBAN: (?P<iban>\S+)/`},
+				&SplitStatement{Column: "name", Sep: " ", Into: []string{"first", "last name"}},
+				&ExplodeStatement{Column: "tags", Sep: `/;\s*/`},
+				&ExtractStatement{Column: "a", Pattern: "(?P<b>.)"},
+			},
+		},
+		{
+			program: "head 10 | skip 2 | tail 3 | limit 1",
+			expects: []Statement{&HeadStatement{Count: 10}, &SkipStatement{Count: 2}, &TailStatement{Count: 3}, &HeadStatement{Count: 1}},
+		},
+		{
+			program: `distinct | distinct name, "Last, First" | dedupe by id keep last | sample 5 0`,
+			expects: []Statement{
+				&DistinctStatement{},
+				&DistinctStatement{Columns: []string{"name", "Last, First"}},
+				&DedupeStatement{By: []string{"id"}, KeepLast: true},
+				&SampleStatement{Count: 5, Seed: &seed},
+			},
+		},
+		{
+			program: "dedupe by a, b keep first",
+			expect:  &DedupeStatement{By: []string{"a", "b"}},
+		},
+		{
+			program: "numbers de:en+group+2",
+			expect:  &NumbersStatement{From: converters.NumberComma, To: "dot+group+2"},
+		},
 		{
 			program: "dates",
 			expect:  &DatesStatement{From: converters.DateAny, To: converters.DateISO},
 		},
+		{
+			program: "if:de amount > '1.000,5'",
+			expect: &FilterStatement{Condition: &converters.Compare{
+				Column: "amount", Op: ">", Value: "1.000,5", Number: converters.NumberComma,
+			}},
+		},
 		{
 			program: "filter col1 > 10",
-			expect:  &FilterStatement{Column: Col("col1"), Op: ">", Value: "10"},
+			expect:  &FilterStatement{Condition: &converters.Compare{Column: "col1", Op: ">", Value: "10"}},
 		},
 		{
 			program: "and col1 = 10",
-			expect:  &FilterStatement{Column: Col("col1"), Op: "=", Value: "10"},
+			expect:  &FilterStatement{Condition: &converters.Compare{Column: "col1", Op: "=", Value: "10"}},
+		},
+		{
+			program: `select "Last, First" as name,  'a | b'->b`,
+			expect: &SelectStatement{Columns: []converters.Column{
+				{Name: "Last, First", Rename: "name"},
+				{Name: "a | b", Rename: "b", Index: 1},
+			}},
+		},
+		{
+			program: `select "Last, First":num:comma, amount:dot`,
+			expect: &SelectStatement{Columns: []converters.Column{
+				{Name: "Last, First", Type: "num", Cast: "comma"},
+				{Name: "amount", Cast: "dot", Index: 1},
+			}},
+		},
+		{
+			program: `select:blank amount:num:comma:dot, booked:date:%d.%m.%Y:iso, id:int`,
+			expect: &SelectStatement{OnError: converters.ErrorBlank, Columns: []converters.Column{
+				{Name: "amount", Type: "num", Cast: "comma:dot"},
+				{Name: "booked", Type: "date", Cast: "%d.%m.%Y:iso", Index: 1},
+				{Name: "id", Cast: "int", Index: 2},
+			}},
+		},
+		{
+			program: `if name = 'a | b'`,
+			expect:  &FilterStatement{Condition: &converters.Compare{Column: "name", Op: "=", Value: "a | b"}},
+		},
+		{
+			program: `if  "full name"   in ('Alice A.', Bob)`,
+			expect: &FilterStatement{Condition: &converters.Compare{
+				Column: "full name", Op: "in", Value: "(Alice A., Bob)", Values: []string{"Alice A.", "Bob"},
+			}},
+		},
+		{
+			program: `where (amount > 100 or category in (food, rent)) and not name ~ '^test'`,
+			expect: &FilterStatement{Condition: converters.And{
+				converters.Or{
+					&converters.Compare{Column: "amount", Op: ">", Value: "100"},
+					&converters.Compare{Column: "category", Op: "in", Value: "(food, rent)", Values: []string{"food", "rent"}},
+				},
+				&converters.Not{Cond: &converters.Compare{Column: "name", Op: "~", Value: "^test"}},
+			}},
+		},
+		{
+			program: `if a is not null or b not in x, y and c not like 'd%'`,
+			expect: &FilterStatement{Condition: converters.Or{
+				&converters.Compare{Column: "a", Op: "is", Value: "not null"},
+				converters.And{
+					&converters.Not{Cond: &converters.Compare{Column: "b", Op: "in", Value: "(x, y)", Values: []string{"x", "y"}}},
+					&converters.Not{Cond: &converters.Compare{Column: "c", Op: "like", Value: "d%"}},
+				},
+			}},
+		},
+		{
+			program: `filter col1>=10`,
+			expect:  &FilterStatement{Condition: &converters.Compare{Column: "col1", Op: ">=", Value: "10"}},
+		},
+		{
+			program: `set total = price * (qty + 1), name = upper(trim("first name")) & '!'`,
+			expect: &SetStatement{Number: converters.NumberDot, Assignments: []converters.Assignment{
+				{Column: "total", Expr: &converters.Binary{Op: "*",
+					Left:  converters.Ref("price"),
+					Right: &converters.Binary{Op: "+", Left: converters.Ref("qty"), Right: converters.Literal("1")},
+				}},
+				{Column: "name", Expr: &converters.Binary{Op: "&",
+					Left:  &converters.Call{Func: "upper", Args: []converters.Expr{&converters.Call{Func: "trim", Args: []converters.Expr{converters.Ref("first name")}}}},
+					Right: converters.Literal("!"),
+				}},
+			}},
+		},
+		{
+			program: "add:comma net = - a - b / 2",
+			expect: &SetStatement{Number: converters.NumberComma, Assignments: []converters.Assignment{
+				{Column: "net", Expr: &converters.Binary{Op: "-",
+					Left:  &converters.Binary{Op: "-", Left: converters.Literal("0"), Right: converters.Ref("a")},
+					Right: &converters.Binary{Op: "/", Left: converters.Ref("b"), Right: converters.Literal("2")},
+				}},
+			}},
+		},
+		{
+			program: "group:comma by a, \"b c\" agg sum(x) as total, count(*), MAX(d) -> last",
+			expect: &GroupStatement{
+				By: []string{"a", "b c"},
+				Aggregates: []converters.Aggregate{
+					{Func: "sum", Column: "x", As: "total"},
+					{Func: "count", Column: "*"},
+					{Func: "max", Column: "d", As: "last"},
+				},
+				Number: converters.NumberComma,
+			},
+		},
+		{
+			program: "group agg avg(x)",
+			expect: &GroupStatement{
+				By:         []string{},
+				Aggregates: []converters.Aggregate{{Func: "avg", Column: "x"}},
+				Number:     converters.NumberDot,
+			},
+		},
+		{
+			program: "join 'data/owners.csv' on id=account, bank left",
+			expect: &JoinStatement{
+				File: "data/owners.csv",
+				Keys: []converters.JoinKey{{Left: "id", Right: "account"}, {Left: "bank", Right: "bank"}},
+				Kind: converters.JoinLeft,
+			},
+		},
+		{
+			program: "join owners.csv on id",
+			expect: &JoinStatement{
+				File: "owners.csv",
+				Keys: []converters.JoinKey{{Left: "id", Right: "id"}},
+				Kind: converters.JoinInner,
+			},
 		},
 		{
 			program: "select col1|numbers dot|dates iso",
@@ -76,7 +291,8 @@ func TestParse(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.program, func(t *testing.T) {
-			got := Parse(tt.program)
+			got, err := Parse(tt.program)
+			require.NoError(t, err)
 			var got1 Statement
 			if len(got) > 0 {
 				got1 = got[0]
@@ -103,8 +319,14 @@ func TestPrograms(t *testing.T) {
 		{"Charlie", "", "", "Charlie C.", "12.12.2021"},
 	}
 
+	ids := converters.Records{{"id", "name"}}
+	for i := range 10 {
+		ids = append(ids, []string{strconv.Itoa(i % 7), "n" + strconv.Itoa(i%3)})
+	}
+
 	tests := []struct {
 		program string
+		data    converters.Records // input, if not data
 		want    converters.Records
 		wantErr bool
 	}{
@@ -117,6 +339,20 @@ func TestPrograms(t *testing.T) {
 				{"Charlie", ""},
 			},
 		},
+		{
+			program: "filter (age > 26 or name = Alice) and not name in (Bob) | select name",
+			want: converters.Records{
+				{"name"},
+				{"Alice"},
+			},
+		},
+		{
+			program: "where age is empty or not (active = true or name ~ '^B') | select name",
+			want: converters.Records{
+				{"name"},
+				{"Charlie"},
+			},
+		},
 		{
 			program: "select * | filter age = 30 | select name | filter name = 'Bob' | filter name like 'B%'",
 			want: converters.Records{
@@ -124,6 +360,13 @@ func TestPrograms(t *testing.T) {
 				{"Bob"},
 			},
 		},
+		{
+			program: `select "full name" as "first, last" | filter "first, last" ~ '^B\w+ B\.$'`,
+			want: converters.Records{
+				{"first, last"},
+				{"Bob B."},
+			},
+		},
 		{
 			program: "select 'full name' as name2 | select name2->name | filter name ~ 'Bob'",
 			want: converters.Records{
@@ -131,6 +374,48 @@ func TestPrograms(t *testing.T) {
 				{"Bob B."},
 			},
 		},
+		{
+			program: "set age = age * 2 + 1, name = lower(substr(name, 1, 3)) & '-' & coalesce(age, 'n/a') | add year = year(date) | select name, year",
+			want: converters.Records{
+				{"name", "year"},
+				{"ali-51", "2020"},
+				{"bob-61", "2023"},
+				{"cha-n/a", "2021"},
+			},
+		},
+		{
+			program: "set:comma age = round(age / 7, 2) | select age",
+			want: converters.Records{
+				{"age"},
+				{"3,57"},
+				{"4,29"},
+				{""},
+			},
+		},
+		{
+			program: "group by active agg count(*), count(age) as ages, sum(age), avg(age), min(date), max(name) | select active, count, ages, sum_age, avg_age, min_date, max_name",
+			want: converters.Records{
+				{"active", "count", "ages", "sum_age", "avg_age", "min_date", "max_name"},
+				{"true", "1", "1", "25", "25", "01.01.2020", "Alice"},
+				{"false", "1", "1", "30", "30", "03.03.2023", "Bob"},
+				{"", "1", "0", "0", "", "12.12.2021", "Charlie"},
+			},
+		},
+		{
+			program: "group agg count(*) as n, min(date) as first, max(date) as last, avg(age) as age",
+			want: converters.Records{
+				{"n", "first", "last", "age"},
+				{"3", "01.01.2020", "03.03.2023", "27.5"},
+			},
+		},
+		{
+			program: "group by active agg sum(name)",
+			wantErr: true,
+		},
+		{
+			program: "set x = name * 2",
+			wantErr: true,
+		},
 		{
 			program: "select name, date | dates iso | date:iso:slash date | date:slash:dot date | date:iso date | filter name = 'Bob'",
 			want: converters.Records{
@@ -138,14 +423,57 @@ func TestPrograms(t *testing.T) {
 				{"Bob", "2023-03-03"},
 			},
 		},
+		{
+			program: strings.Join([]string{
+				"# adults with a name",
+				"let min_age = 26",
+				"select name, age",
+				"  | if age > $min_age",
+				"  # older only",
+				"",
+				"if name ~ '^[A-Z]' and name != ${NOBODY}",
+				"select name",
+			}, "\n"),
+			want: converters.Records{
+				{"name"},
+				{"Bob"},
+			},
+		},
+		{program: "tail 2", data: ids, want: converters.Records{{"id", "name"}, {"1", "n2"}, {"2", "n0"}}},
+		{program: "distinct name", data: ids, want: converters.Records{{"id", "name"}, {"0", "n0"}, {"1", "n1"}, {"2", "n2"}}},
+		{program: "dedupe by name keep last", data: ids, want: converters.Records{{"id", "name"}, {"0", "n1"}, {"1", "n2"}, {"2", "n0"}}},
+		{program: "sort id:num desc | limit 1", data: ids, want: converters.Records{{"id", "name"}, {"6", "n0"}}},
+		{program: "sample 3 7 | select id", data: ids, want: converters.Records{{"id"}, {"4"}, {"0"}, {"1"}}},
+		{
+			program: `drop age, active | rename /\s+/ _ | move full_name first`,
+			want: converters.Records{
+				{"full_name", "name", "date"},
+				{"Alice A.", "Alice", "01.01.2020"},
+				{"Bob B.", "Bob", "03.03.2023"},
+				{"Charlie C.", "Charlie", "12.12.2021"},
+			},
+		},
+		{
+			program: "unpivot food, rent into category, amount | pivot name by category value amount agg sum",
+			data:    converters.Records{{"name", "food", "rent"}, {"a", "1", "2"}, {"b", "3", ""}},
+			want:    converters.Records{{"name", "food", "rent"}, {"a", "1", "2"}, {"b", "3", "0"}},
+		},
+		{
+			program: "split 'full name' by ' ' into first, initial | explode initial by . | if initial != '' | select first, initial",
+			want:    converters.Records{{"first", "initial"}, {"Alice", "A"}, {"Bob", "B"}, {"Charlie", "C"}},
+		},
 	}
 
+	t.Setenv("NOBODY", "Nobody")
 	for _, tt := range tests {
 		t.Run(tt.program, func(t *testing.T) {
-			prg := Parse(tt.program)
+			prg, err := Parse(tt.program)
+			require.NoError(t, err)
 			require.NotNil(t, prg)
 			got := data
-			var err error
+			if tt.data != nil {
+				got = tt.data
+			}
 			for _, s := range prg {
 				slog.Debug("Statement", "statement", s, "data", got)
 				got, err = s.Converter()(got)
@@ -162,3 +490,161 @@ func TestPrograms(t *testing.T) {
 		})
 	}
 }
+
+func TestSyntaxErrors(t *testing.T) {
+	tests := []struct {
+		program string
+		err     error
+		stmt    int
+		snippet string
+	}{
+		{"select a | foo b", ErrUnknownStatement, 1, "select a | foo b\n           ^"},
+		{"select a |  | dates", ErrEmptyStatement, 1, "select a |  | dates\n            ^"},
+		{"select a:b:c:d", ErrInvalidArgs, 0, "select a:b:c:d\n       ^"},
+		{"select a as", ErrInvalidArgs, 0, "select a as\n         ^"},
+		{"select a:num:euro", ErrInvalidArgs, 0, "select a:num:euro\n       ^"},
+		{"select:maybe a", ErrInvalidOptions, 0, "select:maybe a\n^"},
+		{"numbers | number:dot:foo a", ErrInvalidOptions, 1, "numbers | number:dot:foo a\n          ^"},
+		{"dates | date:iso a b", ErrInvalidArgs, 1, "dates | date:iso a b\n                   ^"},
+		{"sel ä | if a ?? 1", ErrUnknownOperator, 1, "sel ä | if a ?? 1\n             ^"},
+		{"if (a = 1 or b = 2", ErrInvalidArgs, 0, "if (a = 1 or b = 2\n                  ^"},
+		{"if a = 1 b = 2 c", ErrUnexpectedToken, 0, "if a = 1 b = 2 c\n           ^"},
+		{"if a = 1 and", ErrInvalidArgs, 0, "if a = 1 and\n            ^"},
+		{"sort:up a", ErrInvalidOptions, 0, "sort:up a\n^"},
+		{"sort a, b:money", converters.ErrInvalidSortKey, 0, "sort a, b:money\n        ^"},
+		{"sort a up", ErrUnexpectedToken, 0, "sort a up\n       ^"},
+		{"sort a nulls", ErrInvalidArgs, 0, "sort a nulls\n            ^"},
+		{"head", ErrInvalidArgs, 0, "head\n^"},
+		{"extract memo", ErrInvalidArgs, 0, "extract memo\n            ^"},
+		{"extract memo /a/ b", ErrUnexpectedToken, 0, "extract memo /a/ b\n                 ^"},
+		{"split name ' ' into a", ErrUnexpectedToken, 0, "split name ' ' into a\n           ^"},
+		{"split name by ' '", ErrInvalidArgs, 0, "split name by ' '\n                 ^"},
+		{"split name by ' ' into", ErrInvalidArgs, 0, "split name by ' ' into\n                      ^"},
+		{"explode tags by", ErrInvalidArgs, 0, "explode tags by\n               ^"},
+		{"pivot a", ErrInvalidArgs, 0, "pivot a\n       ^"},
+		{"pivot a by b", ErrInvalidArgs, 0, "pivot a by b\n            ^"},
+		{"pivot a by b amount c", ErrUnexpectedToken, 0, "pivot a by b amount c\n             ^"},
+		{"pivot a by b value c agg median", converters.ErrInvalidAggregate, 0, "pivot a by b value c agg median\n                         ^"},
+		{"unpivot a into b", ErrInvalidArgs, 0, "unpivot a into b\n          ^"},
+		{"select * except", ErrInvalidArgs, 0, "select * except\n               ^"},
+		{"drop a b", ErrUnexpectedToken, 0, "drop a b\n       ^"},
+		{"rename a", ErrInvalidArgs, 0, "rename a\n        ^"},
+		{"rename a b c", ErrUnexpectedToken, 0, "rename a b c\n           ^"},
+		{"move a", ErrInvalidArgs, 0, "move a\n      ^"},
+		{"move first", ErrUnexpectedToken, 0, "move first\n     ^"},
+		{"move a before", ErrInvalidArgs, 0, "move a before\n             ^"},
+		{"move a last b", ErrUnexpectedToken, 0, "move a last b\n            ^"},
+		{"header:data a", ErrInvalidOptions, 0, "header:data a\n^"},
+		{"head ten", ErrUnexpectedToken, 0, "head ten\n     ^"},
+		{"tail 5 6", ErrUnexpectedToken, 0, "tail 5 6\n       ^"},
+		{"sample 5 x", ErrUnexpectedToken, 0, "sample 5 x\n         ^"},
+		{"dedupe id", ErrUnexpectedToken, 0, "dedupe id\n       ^"},
+		{"dedupe by keep last", ErrUnexpectedToken, 0, "dedupe by keep last\n          ^"},
+		{"dedupe by a keep all", ErrUnexpectedToken, 0, "dedupe by a keep all\n                 ^"},
+		{"distinct a b", ErrUnexpectedToken, 0, "distinct a b\n           ^"},
+		{"select a | if a = 'b", ErrUnterminatedQuote, 1, "select a | if a = 'b\n                  ^"},
+		{"select a | 'select' b", ErrUnexpectedToken, 1, "select a | 'select' b\n           ^"},
+		{"set a = b *", ErrInvalidArgs, 0, "set a = b *\n           ^"},
+		{"set a = b c", ErrUnexpectedToken, 0, "set a = b c\n          ^"},
+		{"set a = foo(b)", converters.ErrUnknownFunction, 0, "set a = foo(b)\n        ^"},
+		{"set a = round(b, 1, 2)", ErrInvalidArgs, 0, "set a = round(b, 1, 2)\n        ^"},
+		{"group by a sum(b)", ErrUnexpectedToken, 0, "group by a sum(b)\n           ^"},
+		{"group by a agg median(b)", converters.ErrInvalidAggregate, 0, "group by a agg median(b)\n               ^"},
+		{"group by a agg sum(*)", converters.ErrInvalidAggregate, 0, "group by a agg sum(*)\n                   ^"},
+		{"group by a agg sum(b", ErrInvalidArgs, 0, "group by a agg sum(b\n                    ^"},
+		{"join x.csv id", ErrUnexpectedToken, 0, "join x.csv id\n           ^"},
+		{"join x.csv on a = b outer", ErrUnexpectedToken, 0, "join x.csv on a = b outer\n                    ^"},
+		{"join x.csv on a left b", ErrUnexpectedToken, 0, "join x.csv on a left b\n                     ^"},
+		{"add a + 1", ErrUnexpectedToken, 0, "add a + 1\n      ^"},
+		{"let", ErrInvalidArgs, 0, "let\n^"},
+		{"let x 1", ErrUnexpectedToken, 0, "let x 1\n      ^"},
+		{"let 1x = 1", ErrUnexpectedToken, 0, "let 1x = 1\n    ^"},
+		{"let x = 1 2", ErrUnexpectedToken, 0, "let x = 1 2\n          ^"},
+		{"select a | if a > $csvlang_unknown", ErrUnknownVariable, 1, "select a | if a > $csvlang_unknown\n                  ^"},
+		{"select a\n# comment\n  | if a = 1\nfoo b", ErrUnknownStatement, 2, "foo b\n^"},
+		{"set tag = # comment", ErrUnexpectedToken, 0, "set tag = # comment\n            ^"},
+		{"select a\n\nif a ?? 1", ErrUnknownOperator, 1, "if a ?? 1\n     ^"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.program, func(t *testing.T) {
+			got, err := Parse(tt.program)
+			require.Nil(t, got)
+			require.ErrorIs(t, err, tt.err)
+
+			var synErr *SyntaxError
+			require.ErrorAs(t, err, &synErr)
+			require.Equal(t, tt.stmt, synErr.Statement)
+			require.Equal(t, tt.snippet, synErr.Snippet())
+		})
+	}
+}
+
+func TestLex(t *testing.T) {
+	tokens, err := Lex(`sel "a \"b\"",c->'d\'s \d' |if x\ y>=1`)
+	require.NoError(t, err)
+	require.Equal(t, []Token{
+		{Kind: TokenWord, Text: "sel", Pos: 0, End: 3},
+		{Kind: TokenIdent, Text: `a "b"`, Pos: 4, End: 13},
+		{Kind: TokenComma, Text: ",", Pos: 13, End: 14},
+		{Kind: TokenWord, Text: "c", Pos: 14, End: 15},
+		{Kind: TokenOp, Text: "->", Pos: 15, End: 17},
+		{Kind: TokenString, Text: `d's \d`, Pos: 17, End: 26},
+		{Kind: TokenPipe, Text: "|", Pos: 27, End: 28},
+		{Kind: TokenWord, Text: "if", Pos: 28, End: 30},
+		{Kind: TokenWord, Text: "x y", Pos: 31, End: 35},
+		{Kind: TokenOp, Text: ">=", Pos: 35, End: 37},
+		{Kind: TokenWord, Text: "1", Pos: 37, End: 38},
+	}, tokens)
+}
+
+func TestLexPattern(t *testing.T) {
+	tokens, err := Lex(`sel /^(a|b)\s=\//:num, x / 2 / y, /IBAN: (?P<iban>\S+)/ z`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"sel", `/^(a|b)\s=\//:num`, ",", "x", "/", "2", "/", "y", ",", `/IBAN: (?P<iban>\S+)/`, "z"}, Texts(tokens))
+}
+
+func TestLexLines(t *testing.T) {
+	tokens, err := LexFile("# comment\nselect a, b # columns\n  | if a = '#1'\r\n\nsort a\n# end")
+	require.NoError(t, err)
+	require.Equal(t, []string{"select", "a", ",", "b", "|", "if", "a", "=", "#1", "\n", "sort", "a"}, Texts(tokens))
+	require.Equal(t, TokenPipe, tokens[9].Kind)
+}
+
+func TestLexComments(t *testing.T) {
+	tokens, err := Lex("# comment\nset tag = #1, color = #ff0000 # tag\n  # indented comment\n| sort a")
+	require.NoError(t, err)
+	require.Equal(t, []string{"set", "tag", "=", "#1", ",", "color", "=", "#ff0000", "#", "tag", "|", "sort", "a"}, Texts(tokens))
+
+	tokens, err = LexFile("set tag = '#1' # comment\nset color = #ff0000")
+	require.NoError(t, err)
+	require.Equal(t, []string{"set", "tag", "=", "#1", "\n", "set", "color", "="}, Texts(tokens))
+
+	// the args are not part of the file
+	got, err := ParseFile(nil, "# tags\nset tag = '#1' # comment\n", "if color = #ff0000")
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+}
+
+func TestParseVars(t *testing.T) {
+	t.Setenv("CSVLANG_COLUMN", "env")
+	got, err := ParseVars(Vars{"sep": ";"}, "let col = $CSVLANG_COLUMN\nexplode ${col}_tags by $sep\nsplit $col by $$ into a\nrename /(\\w+)_(\\w+)/ ${2}_$1")
+	require.NoError(t, err)
+	require.Equal(t, []Statement{
+		&ExplodeStatement{Column: "env_tags", Sep: ";"},
+		&SplitStatement{Column: "env", Sep: "$", Into: []string{"a"}},
+		&RenameStatement{Columns: []converters.Column{{Name: `/(\w+)_(\w+)/`, Rename: "${2}_$1"}}},
+	}, got)
+
+	vars := Vars{"x": "1"}
+	_, err = ParseVars(vars, "let x = 2")
+	require.NoError(t, err)
+	require.Equal(t, Vars{"x": "1"}, vars, "predefined variables are not modified")
+}
+
+func TestKeywords(t *testing.T) {
+	for _, keyword := range Keywords {
+		_, err := parseVarStatement(Command{Keyword: keyword}, Vars{})
+		require.NotErrorIs(t, err, ErrUnknownStatement, keyword)
+	}
+}
@@ -0,0 +1,99 @@
+package csvlang
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Vars holds the variables of a program by name.
+type Vars map[string]string
+
+// varPattern matches variable references such as $name, ${name}, and the escaped dollar $$.
+var varPattern = regexp.MustCompile(`\$\$|\$[A-Za-z_][A-Za-z0-9_]*|\$\{[A-Za-z_][A-Za-z0-9_]*\}`)
+
+// varName matches valid variable names.
+var varName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Lookup returns the value of the variable, or of the environment variable with the same name.
+func (v Vars) Lookup(name string) (string, bool) {
+	if value, ok := v[name]; ok {
+		return value, true
+	}
+	return os.LookupEnv(name)
+}
+
+// Expand replaces the variable references in the text, e.g., "$threshold" or "${HOME}".
+// It returns an error for unknown variables.
+func (v Vars) Expand(text string) (string, error) {
+	var err error
+	result := varPattern.ReplaceAllStringFunc(text, func(ref string) string {
+		if ref == "$$" {
+			return "$"
+		}
+		name := strings.Trim(ref, "${}")
+		value, ok := v.Lookup(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("%w: %q", ErrUnknownVariable, ref)
+		}
+		return value
+	})
+	return result, err
+}
+
+// expandCommand returns the command with the variables in its options, words, and identifiers
+// replaced by their values. Single-quoted strings are kept as is.
+func expandCommand(cmd Command, vars Vars) (Command, error) {
+	expanded := cmd
+	expanded.Options = make([]string, len(cmd.Options))
+	for i, opt := range cmd.Options {
+		text, err := vars.Expand(opt)
+		if err != nil {
+			return cmd, errorAt(cmd.Pos, err)
+		}
+		expanded.Options[i] = text
+	}
+	expanded.Args = make([]Token, len(cmd.Args))
+	for i, tok := range cmd.Args {
+		if tok.Is(TokenWord) || tok.Is(TokenIdent) {
+			text, err := vars.Expand(tok.Text)
+			if err != nil {
+				return cmd, errorAt(tok.Pos, err)
+			}
+			tok.Text = text
+		}
+		expanded.Args[i] = tok
+	}
+	return expanded, nil
+}
+
+// parseLet parses a variable definition `let <name> = <value>` and sets the variable.
+func parseLet(cmd Command, vars Vars) error {
+	if len(cmd.Options) > 0 {
+		return fmt.Errorf("%w: let has no options", ErrInvalidOptions)
+	}
+	p := &tokenParser{tokens: cmd.Args}
+	tok, ok := p.next()
+	switch {
+	case !ok:
+		return fmt.Errorf("%w: let statement requires a variable name", ErrInvalidArgs)
+	case !tok.Is(TokenWord) || !varName.MatchString(tok.Text):
+		return p.unexpected(tok, "variable name")
+	}
+	if !p.accept(TokenOp, "=") {
+		if next, ok := p.peek(); ok {
+			return p.unexpected(next, "'='")
+		}
+		return p.endError("'='")
+	}
+	value, err := p.valueArg("value")
+	if err != nil {
+		return err
+	}
+	if err := endArgs("let", cmd.Args, p.pos); err != nil {
+		return err
+	}
+	vars[tok.Text] = value
+	return nil
+}
@@ -2,7 +2,6 @@ package csvconv
 
 import (
 	"bytes"
-	"encoding/csv"
 	"errors"
 	"os"
 	"strings"
@@ -91,25 +90,21 @@ func DetectNewline(data []byte) NLMode {
 	}
 }
 
+// ReadCsv reads all records of CSV data with a header.
+// A zero delimiter is sniffed from the data.
 func ReadCsv(delimiter rune, data []byte) ([][]string, error) {
-	r := csv.NewReader(bytes.NewReader(data))
-	r.Comma = delimiter
-	r.LazyQuotes = true    // do not allow quotes in fields
-	r.FieldsPerRecord = -1 // allow variable number of fields
+	return ReadRecords(InputCSV, ReaderConfig{Delimiter: delimiter, Header: HeaderYes}, bytes.NewReader(data))
+}
 
-	records, err := r.ReadAll()
+// ReadCsvFile reads all records of a CSV file, e.g., a lookup table for a join.
+func ReadCsvFile(delimiter rune, src string) (converters.Records, error) {
+	data, _, err := ReadFileRaw(src)
 	if err != nil {
 		return nil, err
 	}
-	return records, nil
+	return ReadCsv(delimiter, data)
 }
 
 func WriteCsv(delimiter rune, nl NLMode, records [][]string) ([]byte, error) {
-	var buf bytes.Buffer
-	w := csv.NewWriter(&buf)
-	w.Comma = delimiter
-	w.UseCRLF = nl == UseCRLF
-	w.WriteAll(records)
-	w.Flush()
-	return buf.Bytes(), w.Error()
+	return WriteRecords(FormatCSV, WriterConfig{Delimiter: delimiter, NL: nl}, records)
 }
@@ -0,0 +1,231 @@
+package csvconv
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"ubunatic.com/dotapps/go/csvconv/converters"
+)
+
+var ErrInvalidInput = errors.New("invalid input")
+
+// ExpandInputs returns the input files of the given files, glob patterns, and directories.
+// Directories are expanded to their non-hidden files with a known input extension, e.g., ".csv".
+// Files are returned in the given order, expanded files are sorted by name.
+func ExpandInputs(inputs ...string) ([]string, error) {
+	files := []string{}
+	for _, input := range inputs {
+		if input == "-" {
+			files = append(files, input)
+			continue
+		}
+		matches := []string{input}
+		if strings.ContainsAny(input, "*?[") {
+			var err error
+			if matches, err = filepath.Glob(input); err != nil {
+				return nil, fmt.Errorf("%w: %q: %w", ErrInvalidInput, input, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("%w: no files match %q", ErrInvalidInput, input)
+			}
+		}
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, err
+			}
+			if !info.IsDir() {
+				files = append(files, match)
+				continue
+			}
+			dirFiles, err := inputFiles(match)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, dirFiles...)
+		}
+	}
+	if len(files) > 1 && slices.Contains(files, "-") {
+		return nil, fmt.Errorf("%w: stdin cannot be combined with other inputs", ErrInvalidInput)
+	}
+	return files, nil
+}
+
+// inputFiles returns the input files of a directory.
+func inputFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if _, ok := inputExtensions[strings.ToLower(filepath.Ext(name))]; ok {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: no input files in %q", ErrInvalidInput, dir)
+	}
+	return files, nil
+}
+
+// concatReader reads the records of several inputs as one input.
+// The records are aligned to the unified header, see [converters.UnifyHeaders].
+// Only the current input is open, the other inputs are opened when they are read.
+type concatReader struct {
+	o       *options
+	files   []string
+	indices [][]int // field indices of each input, nil if aligned
+	header  []string
+	current int // index of the current input, -1 before the header
+	row     int // row number in the current input, the header is row 1
+	reader  RecordReader
+	in      io.Closer
+}
+
+// openInputs reads the headers of the files and unifies them. Empty files are skipped.
+// The returned [Sniffed] settings are taken from the first non-empty file, which stays open.
+func openInputs(files []string, o *options) (RecordReader, Sniffed, io.Closer, error) {
+	c := &concatReader{o: o, current: -1}
+	var sniffed Sniffed
+	headers := [][]string{}
+	for _, file := range files {
+		r, s, in, err := c.open(file)
+		if err != nil {
+			c.Close()
+			return nil, Sniffed{}, nil, err
+		}
+		header, err := r.Read()
+		switch {
+		case errors.Is(err, io.EOF):
+			in.Close()
+			continue // empty input
+		case err != nil:
+			err = fmt.Errorf("%s: %w", file, err)
+		default:
+			_, err = converters.UnifyHeaders(o.union, append(headers, header)...)
+			if err != nil {
+				err = fmt.Errorf("%s: %w", file, err)
+			}
+		}
+		if err != nil {
+			in.Close()
+			c.Close()
+			return nil, Sniffed{}, nil, err
+		}
+		if c.reader == nil {
+			// keep the first input open, it may be stdin
+			c.reader, c.in, c.row, sniffed = r, in, 1, s
+		} else {
+			in.Close()
+		}
+		headers = append(headers, header)
+		c.files = append(c.files, file)
+	}
+	if len(headers) == 0 {
+		return nil, Sniffed{}, nil, fmt.Errorf("%w: no records in %s", ErrInvalidInput, strings.Join(files, ", "))
+	}
+	c.header, _ = converters.UnifyHeaders(o.union, headers...)
+	for _, header := range headers {
+		var indices []int
+		if !slices.Equal(header, c.header) {
+			indices = converters.AlignFields(header, c.header)
+		}
+		c.indices = append(c.indices, indices)
+	}
+	return c, sniffed, c, nil
+}
+
+// open opens a file and returns its reader.
+func (c *concatReader) open(file string) (RecordReader, Sniffed, io.Closer, error) {
+	name := file
+	if name == "-" {
+		name = os.Stdin.Name()
+	}
+	in, err := os.Open(name)
+	if err != nil {
+		return nil, Sniffed{}, nil, err
+	}
+	format, cfg := c.o.readerConfig(name)
+	r, sniffed, err := ReadStream(format, cfg, in)
+	if err != nil {
+		in.Close()
+		return nil, Sniffed{}, nil, fmt.Errorf("%s: %w", file, err)
+	}
+	return r, sniffed, in, nil
+}
+
+// next closes the current input and opens the next one, skipping its header.
+func (c *concatReader) next() error {
+	c.Close()
+	if c.current++; c.current >= len(c.files) {
+		return io.EOF
+	}
+	file := c.files[c.current]
+	r, _, in, err := c.open(file)
+	if err != nil {
+		return err
+	}
+	c.reader, c.in, c.row = r, in, 1
+	if _, err := r.Read(); err != nil {
+		return fmt.Errorf("%s: %w", file, err)
+	}
+	return nil
+}
+
+func (c *concatReader) Read() ([]string, error) {
+	if c.current < 0 {
+		c.current = 0
+		if source := c.o.sourceColumn; source != "" {
+			return append(slices.Clone(c.header), source), nil
+		}
+		return slices.Clone(c.header), nil
+	}
+	for c.reader != nil {
+		record, err := c.reader.Read()
+		if errors.Is(err, io.EOF) {
+			if err := c.next(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.files[c.current], err)
+		}
+		c.row++
+		if indices := c.indices[c.current]; indices != nil {
+			record = converters.AlignRecord(record, indices)
+		}
+		if c.o.sourceColumn != "" {
+			// pad the record, so that the source is always in the last column
+			if len(record) > len(c.header) {
+				return nil, fmt.Errorf("%w: %s: row %d has %d fields, more than the %d columns of the header",
+					ErrInvalidInput, c.files[c.current], c.row, len(record), len(c.header))
+			}
+			fields := make([]string, len(c.header), len(c.header)+1)
+			copy(fields, record)
+			record = append(fields, c.files[c.current])
+		}
+		return record, nil
+	}
+	return nil, io.EOF
+}
+
+// Close closes the current input.
+func (c *concatReader) Close() error {
+	in := c.in
+	c.reader, c.in = nil, nil
+	if in == nil {
+		return nil
+	}
+	return in.Close()
+}
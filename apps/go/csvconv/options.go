@@ -1,6 +1,10 @@
 package csvconv
 
-import "ubunatic.com/dotapps/go/csvconv/converters"
+import (
+	"cmp"
+
+	"ubunatic.com/dotapps/go/csvconv/converters"
+)
 
 type Opt func(*options)
 
@@ -8,15 +12,26 @@ type options struct {
 	srcDelimiter rune
 	dstDelimiter rune
 	outputMode   NLMode
-	converters   []converters.Converter
+	outputEnc    string
+	quote        QuoteMode
+	inputFormat  InputFormat
+	encoding     string
+	header       HeaderMode
+	fixed        []FixedColumn
+	outputFormat OutputFormat
+	rows         []converters.RowConverter
 	inline       bool
+	inputs       []string
+	sourceColumn string
+	union        bool
 }
 
 func newOptions(opts ...Opt) *options {
 	o := &options{
 		srcDelimiter: ',',
 		outputMode:   AutoCRLF,
-		converters:   []converters.Converter{},
+		header:       HeaderYes,
+		rows:         []converters.RowConverter{},
 		inline:       false,
 	}
 	o.apply(opts...)
@@ -32,6 +47,9 @@ func (o *options) apply(opts ...Opt) {
 	}
 }
 
+// WithDelimiters sets the delimiters of the input and output.
+// A zero srcDelim sniffs the delimiter of the input,
+// a zero dstDelim uses the delimiter of the input.
 func WithDelimiters(srcDelim, dstDelim rune) Opt {
 	return func(opts *options) {
 		opts.srcDelimiter = srcDelim
@@ -39,15 +57,120 @@ func WithDelimiters(srcDelim, dstDelim rune) Opt {
 	}
 }
 
+// WithInputFormat sets the input format.
+// The default [AutoInput] infers the format from the input file name.
+func WithInputFormat(format InputFormat) Opt {
+	return func(opts *options) {
+		opts.inputFormat = format
+	}
+}
+
+// WithEncoding sets the encoding of the input, see [Encodings].
+// The default [AutoEncoding] detects the encoding.
+func WithEncoding(encoding string) Opt {
+	return func(opts *options) {
+		opts.encoding = encoding
+	}
+}
+
+// WithHeader sets whether the input has a header.
+// [HeaderAuto] guesses the header, which is recommended for interactive use.
+func WithHeader(header HeaderMode) Opt {
+	return func(opts *options) {
+		opts.header = header
+	}
+}
+
+// WithFixedColumns sets the columns of fixed-width input, see [ParseFixedColumns].
+// If no input format is set, the input is read as fixed-width.
+func WithFixedColumns(columns ...FixedColumn) Opt {
+	return func(opts *options) {
+		opts.fixed = columns
+	}
+}
+
+// readerConfig returns the input format and reader config for src.
+func (o *options) readerConfig(src string) (InputFormat, ReaderConfig) {
+	format := o.inputFormat
+	switch {
+	case format != AutoInput:
+	case len(o.fixed) > 0:
+		format = InputFixed
+	default:
+		format = InputFormatFromPath(src)
+	}
+	return format, ReaderConfig{
+		Delimiter: o.srcDelimiter,
+		Header:    o.header,
+		Encoding:  o.encoding,
+		Columns:   o.fixed,
+	}
+}
+
+// WithOutputFormat sets the output format.
+// The default [AutoFormat] infers the format from the output file name.
+func WithOutputFormat(format OutputFormat) Opt {
+	return func(opts *options) {
+		opts.outputFormat = format
+	}
+}
+
+// writerConfig returns the output format and writer config for dst.
+// Automatic settings are taken from the input.
+func (o *options) writerConfig(dst string, in Sniffed) (OutputFormat, WriterConfig) {
+	format := o.outputFormat
+	if format == AutoFormat {
+		format = FormatFromPath(dst)
+	}
+	cfg := WriterConfig{Delimiter: o.dstDelimiter, NL: o.outputMode, Encoding: o.outputEnc, Quote: o.quote}
+	if cfg.Delimiter == 0 {
+		cfg.Delimiter = cmp.Or(in.Delimiter, ',')
+	}
+	if cfg.NL == AutoCRLF {
+		cfg.NL = in.NL
+	}
+	if cfg.Encoding == AutoEncoding && in.BOM && in.Encoding == UTF8 {
+		cfg.Encoding = UTF8BOM
+	}
+	return format, cfg
+}
+
 func WithOutputMode(outputMode NLMode) Opt {
 	return func(opts *options) {
 		opts.outputMode = outputMode
 	}
 }
 
-func With(converters ...converters.Converter) Opt {
+// WithOutputEncoding sets the encoding of the output, see [Encodings].
+// The default [AutoEncoding] writes UTF-8 and keeps the BOM of UTF-8 input.
+// Use [UTF8BOM] for files that are opened in Excel.
+func WithOutputEncoding(encoding string) Opt {
+	return func(opts *options) {
+		opts.outputEnc = encoding
+	}
+}
+
+// WithQuoting sets which fields of CSV output are quoted.
+func WithQuoting(quote QuoteMode) Opt {
 	return func(opts *options) {
-		opts.converters = append(opts.converters, converters...)
+		opts.quote = quote
+	}
+}
+
+// With adds whole-table converters. They buffer all records when streaming.
+func With(convs ...converters.Converter) Opt {
+	return func(opts *options) {
+		for _, conv := range convs {
+			opts.rows = append(opts.rows, converters.Buffer(conv))
+		}
+	}
+}
+
+// WithRows adds row converters. Row converters are stateful
+// and must not be shared between conversions.
+func WithRows(rows ...converters.RowConverter) Opt {
+	return func(opts *options) {
+		opts.rows = append(opts.rows, rows...)
 	}
 }
 
@@ -56,3 +179,26 @@ func WithInline(inline bool) Opt {
 		opts.inline = inline
 	}
 }
+
+// WithInputs sets the input files, which are read instead of src and concatenated,
+// see [ExpandInputs] for globs and directories. Columns are aligned by name.
+func WithInputs(files ...string) Opt {
+	return func(opts *options) {
+		opts.inputs = files
+	}
+}
+
+// WithSourceColumn adds a column with the input file name of each record.
+func WithSourceColumn(name string) Opt {
+	return func(opts *options) {
+		opts.sourceColumn = name
+	}
+}
+
+// WithUnion allows concatenated inputs to have different columns.
+// The missing values of an input are empty.
+func WithUnion(union bool) Opt {
+	return func(opts *options) {
+		opts.union = union
+	}
+}
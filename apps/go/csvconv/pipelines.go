@@ -0,0 +1,101 @@
+package csvconv
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	"ubunatic.com/dotapps/go/csvconv/csvlang"
+)
+
+var (
+	ErrUnknownPipeline = errors.New("unknown pipeline")
+	ErrInvalidPipeline = errors.New("invalid pipeline")
+)
+
+// Pipeline is a named csvlang query of a pipelines file.
+type Pipeline struct {
+	Description string            `yaml:"description"`
+	Query       string            `yaml:"query"` // csvlang program
+	File        string            `yaml:"file"`  // query file, relative to the pipelines file
+	Vars        map[string]string `yaml:"vars"`  // predefined variables of the query
+}
+
+// Pipelines holds the named pipelines of a pipelines file, e.g.:
+//
+//	pipelines:
+//	  bank-cleanup:
+//	    description: Clean up bank exports
+//	    vars: {threshold: 100}
+//	    query: |
+//	      select date:date:dot:iso, amount:num:comma:dot
+//	      if amount > $threshold
+//	  monthly:
+//	    file: monthly.csvl
+type Pipelines struct {
+	Pipelines map[string]Pipeline `yaml:"pipelines"`
+	dir       string              // directory of the pipelines file
+}
+
+// DefaultPipelinesFile returns the path of the pipelines file in the user config directory,
+// e.g., ~/.config/csvconv/pipelines.yaml, or an empty string if there is no config directory.
+func DefaultPipelinesFile() string {
+	return configFile("pipelines.yaml")
+}
+
+// configFile returns the path of a file in the csvconv user config directory,
+// or an empty string if there is no config directory.
+func configFile(name string) string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "csvconv", name)
+}
+
+// LoadPipelines reads a pipelines file.
+func LoadPipelines(file string) (*Pipelines, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	p := &Pipelines{dir: filepath.Dir(file)}
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrInvalidPipeline, file, err)
+	}
+	return p, nil
+}
+
+// Names returns the sorted names of the pipelines.
+func (p *Pipelines) Names() []string {
+	return slices.Sorted(maps.Keys(p.Pipelines))
+}
+
+// Query returns the csvlang program and the variables of the named pipeline.
+func (p *Pipelines) Query(name string) (string, csvlang.Vars, error) {
+	pipeline, ok := p.Pipelines[name]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: %q, available pipelines: %s", ErrUnknownPipeline, name, strings.Join(p.Names(), ", "))
+	}
+	query := pipeline.Query
+	switch {
+	case pipeline.File != "" && query != "":
+		return "", nil, fmt.Errorf("%w: %q has a query and a file", ErrInvalidPipeline, name)
+	case pipeline.File != "":
+		file := pipeline.File
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(p.dir, file)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", nil, err
+		}
+		query = string(data)
+	}
+	return query, pipeline.Vars, nil
+}
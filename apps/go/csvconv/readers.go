@@ -0,0 +1,393 @@
+package csvconv
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"ubunatic.com/dotapps/go/csvconv/converters"
+)
+
+// InputFormat is the file format of the input records.
+type InputFormat string
+
+const (
+	AutoInput  InputFormat = ""      // inferred from the input file name
+	InputCSV   InputFormat = "csv"   // delimited values, optionally with an Excel "sep=" line
+	InputTSV   InputFormat = "tsv"   // tab-separated values
+	InputJSON  InputFormat = "json"  // array of objects, nested keys are flattened
+	InputJSONL InputFormat = "jsonl" // one object per line, nested keys are flattened
+	InputFixed InputFormat = "fixed" // fixed-width columns, see [ParseFixedColumns]
+)
+
+func MustParseInputFormat(s string) InputFormat { return converters.MustParse(s, ParseInputFormat) }
+func ParseInputFormat(s string) (InputFormat, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "auto", "":
+		return AutoInput, nil
+	case "ndjson":
+		return InputJSONL, nil
+	case "fixed-width", "fixedwidth":
+		return InputFixed, nil
+	}
+	if _, ok := Readers[InputFormat(s)]; ok {
+		return InputFormat(s), nil
+	}
+	return AutoInput, fmt.Errorf("%w: %q", ErrInvalidFormat, s)
+}
+
+// inputExtensions maps file extensions to input formats.
+var inputExtensions = map[string]InputFormat{
+	".csv":    InputCSV,
+	".tsv":    InputTSV,
+	".tab":    InputTSV,
+	".json":   InputJSON,
+	".jsonl":  InputJSONL,
+	".ndjson": InputJSONL,
+}
+
+// InputFormatFromPath infers the input format from the file extension.
+// Unknown extensions use CSV.
+func InputFormatFromPath(path string) InputFormat {
+	if format, ok := inputExtensions[strings.ToLower(filepath.Ext(path))]; ok {
+		return format
+	}
+	return InputCSV
+}
+
+// RecordReader reads records from an input. The first record is the header.
+// Read returns [io.EOF] after the last record.
+type RecordReader interface {
+	Read() ([]string, error)
+}
+
+// ReaderConfig configures a [RecordReader].
+// Zero values are automatic settings that are sniffed by [ReadStream].
+type ReaderConfig struct {
+	Delimiter rune
+	Header    HeaderMode
+	Encoding  string
+	Columns   []FixedColumn // columns of fixed-width input
+}
+
+// NewReader creates a [RecordReader] for an input format.
+type NewReader func(r io.Reader, cfg ReaderConfig) (RecordReader, error)
+
+// Readers lists the readers of all input formats.
+// Additional formats can be added before parsing any options.
+var Readers = map[InputFormat]NewReader{
+	InputCSV: newCsvReader,
+	InputTSV: func(r io.Reader, cfg ReaderConfig) (RecordReader, error) {
+		cfg.Delimiter = '\t'
+		return newCsvReader(r, cfg)
+	},
+	InputJSON:  func(r io.Reader, cfg ReaderConfig) (RecordReader, error) { return newJSONReader(r, false) },
+	InputJSONL: func(r io.Reader, cfg ReaderConfig) (RecordReader, error) { return newJSONReader(r, true) },
+	InputFixed: newFixedReader,
+}
+
+// NewRecordReader returns a reader for the given input format.
+func NewRecordReader(format InputFormat, r io.Reader, cfg ReaderConfig) (RecordReader, error) {
+	newReader, ok := Readers[format]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidFormat, format)
+	}
+	return newReader(r, cfg)
+}
+
+// ReadRecords reads all records of the input in the given format, see [ReadStream].
+func ReadRecords(format InputFormat, cfg ReaderConfig, input io.Reader) (converters.Records, error) {
+	r, _, err := ReadStream(format, cfg, input)
+	if err != nil {
+		return nil, err
+	}
+	return readAll(r)
+}
+
+func readAll(r RecordReader) (converters.Records, error) {
+	records := converters.Records{}
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+}
+
+// newCsvReader returns a CSV reader. A leading "sep=;" line, as written
+// by Excel, is removed and overrides the configured delimiter.
+// Without a header, column numbers are used as header.
+func newCsvReader(input io.Reader, cfg ReaderConfig) (RecordReader, error) {
+	br := bufio.NewReader(input)
+	if line, _ := br.Peek(len("sep=;\r\n")); bytes.HasPrefix(line, []byte("sep=")) {
+		if sep, rest, ok := cutSepLine(string(line)); ok {
+			br.Discard(len(line) - len(rest))
+			cfg.Delimiter = sep
+		}
+	}
+	if cfg.Delimiter == 0 {
+		cfg.Delimiter = ','
+	}
+
+	r := csv.NewReader(br)
+	r.Comma = cfg.Delimiter
+	r.LazyQuotes = true    // do not allow quotes in fields
+	r.FieldsPerRecord = -1 // allow variable number of fields
+	if cfg.Header == HeaderNo {
+		return &numberedReader{RecordReader: r}, nil
+	}
+	return r, nil
+}
+
+// numberedReader adds a header of column numbers to headerless input.
+type numberedReader struct {
+	RecordReader
+	started bool
+	first   []string // first record, returned after the header
+}
+
+func (r *numberedReader) Read() ([]string, error) {
+	if !r.started {
+		r.started = true
+		record, err := r.RecordReader.Read()
+		if err != nil {
+			return nil, err
+		}
+		r.first = record
+		header := make([]string, len(record))
+		for i := range header {
+			header[i] = strconv.Itoa(i + 1)
+		}
+		return header, nil
+	}
+	if r.first != nil {
+		record := r.first
+		r.first = nil
+		return record, nil
+	}
+	return r.RecordReader.Read()
+}
+
+// recordsReader returns records of a table that was read at once.
+type recordsReader struct {
+	records converters.Records
+}
+
+func (r *recordsReader) Read() ([]string, error) {
+	if len(r.records) == 0 {
+		return nil, io.EOF
+	}
+	record := r.records[0]
+	r.records = r.records[1:]
+	return record, nil
+}
+
+// newJSONReader reads an array of objects or a sequence of objects.
+// Nested keys are flattened to dotted column names, such as "account.iban"
+// or "tags.0", and the header is the union of all keys in order of appearance.
+// Since later objects may add columns, all objects are read at once.
+func newJSONReader(input io.Reader, lines bool) (RecordReader, error) {
+	dec := json.NewDecoder(input)
+	dec.UseNumber()
+
+	if !lines {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+		}
+		if tok != json.Delim('[') {
+			return nil, fmt.Errorf("%w: expected JSON array, got %v", ErrInvalidFormat, tok)
+		}
+	}
+
+	header := []string{}
+	columns := map[string]int{}
+	records := converters.Records{header}
+	for dec.More() {
+		obj := &flatObject{}
+		if err := obj.readValue(dec, ""); err != nil {
+			return nil, fmt.Errorf("%w: object %d: %w", ErrInvalidFormat, len(records), err)
+		}
+		record := make([]string, len(header))
+		for i, key := range obj.keys {
+			idx, ok := columns[key]
+			if !ok {
+				idx = len(header)
+				columns[key] = idx
+				header = append(header, key)
+				record = append(record, "")
+			}
+			record[idx] = obj.values[i]
+		}
+		records = append(records, record)
+	}
+	records[0] = header
+	for i, record := range records {
+		records[i] = append(record, make([]string, len(header)-len(record))...)
+	}
+
+	if !lines {
+		if _, err := dec.Token(); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidFormat, err)
+		}
+	}
+	return &recordsReader{records: records}, nil
+}
+
+// flatObject holds the flattened keys and values of a JSON value.
+type flatObject struct {
+	keys   []string
+	values []string
+}
+
+// readValue reads the next JSON value and adds its flattened keys with the given prefix.
+// Scalar values at the top level are stored in the column "value".
+func (o *flatObject) readValue(dec *json.Decoder, prefix string) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if err := o.readValue(dec, joinKey(prefix, key.(string))); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token() // }
+		return err
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			if err := o.readValue(dec, joinKey(prefix, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+		_, err = dec.Token() // ]
+		return err
+	}
+
+	if prefix == "" {
+		prefix = "value"
+	}
+	o.keys = append(o.keys, prefix)
+	switch v := tok.(type) {
+	case nil:
+		o.values = append(o.values, "")
+	case string:
+		o.values = append(o.values, v)
+	default:
+		o.values = append(o.values, fmt.Sprint(v))
+	}
+	return nil
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+var ErrInvalidFixedColumns = errors.New("invalid fixed-width columns")
+
+// FixedColumn is a column of fixed-width input.
+// Positions are 1-based and inclusive, and count characters.
+type FixedColumn struct {
+	Name  string
+	Start int
+	End   int // 0 reads until the end of the line
+}
+
+// ParseFixedColumns parses a fixed-width spec such as "name:1-20,amount:21-32".
+// A column without an end, such as "note:33-", reads until the end of the line.
+func ParseFixedColumns(spec string) ([]FixedColumn, error) {
+	columns := []FixedColumn{}
+	for _, part := range converters.TrimSplit(spec, ",") {
+		name, pos, ok := strings.Cut(part, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%w: %q, expected name:start-end", ErrInvalidFixedColumns, part)
+		}
+		from, to, isRange := strings.Cut(pos, "-")
+		col := FixedColumn{Name: strings.TrimSpace(name)}
+		var err error
+		if col.Start, err = strconv.Atoi(strings.TrimSpace(from)); err != nil || col.Start < 1 {
+			return nil, fmt.Errorf("%w: invalid start %q", ErrInvalidFixedColumns, part)
+		}
+		switch to = strings.TrimSpace(to); {
+		case !isRange:
+			col.End = col.Start
+		case to == "":
+			col.End = 0
+		default:
+			if col.End, err = strconv.Atoi(to); err != nil || col.End < col.Start {
+				return nil, fmt.Errorf("%w: invalid end %q", ErrInvalidFixedColumns, part)
+			}
+		}
+		columns = append(columns, col)
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("%w: no columns", ErrInvalidFixedColumns)
+	}
+	return columns, nil
+}
+
+// fixedReader reads fixed-width lines. Fields are trimmed and empty lines are skipped.
+type fixedReader struct {
+	scanner *bufio.Scanner
+	columns []FixedColumn
+	header  bool
+}
+
+func newFixedReader(input io.Reader, cfg ReaderConfig) (RecordReader, error) {
+	if len(cfg.Columns) == 0 {
+		return nil, fmt.Errorf("%w: no columns", ErrInvalidFixedColumns)
+	}
+	return &fixedReader{scanner: bufio.NewScanner(input), columns: cfg.Columns}, nil
+}
+
+func (r *fixedReader) Read() ([]string, error) {
+	if !r.header {
+		r.header = true
+		header := make([]string, len(r.columns))
+		for i, col := range r.columns {
+			header[i] = col.Name
+		}
+		return header, nil
+	}
+
+	for r.scanner.Scan() {
+		line := []rune(strings.TrimRight(r.scanner.Text(), "\r"))
+		if len(strings.TrimSpace(string(line))) == 0 {
+			continue
+		}
+		record := make([]string, len(r.columns))
+		for i, col := range r.columns {
+			start, end := min(col.Start-1, len(line)), len(line)
+			if col.End > 0 {
+				end = min(col.End, len(line))
+			}
+			record[i] = strings.TrimSpace(string(line[start:end]))
+		}
+		return record, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
@@ -0,0 +1,55 @@
+package csvconv
+
+import (
+	"os"
+	"strconv"
+
+	"ubunatic.com/dotapps/go/csvconv/converters"
+)
+
+// RejectsFile writes rejected records to a CSV file with the columns
+// row, statement, and error, followed by the fields of the records.
+// The header is taken from the first rejected record.
+type RejectsFile struct {
+	file   *os.File
+	w      RecordWriter
+	header bool
+}
+
+// CreateRejectsFile creates or truncates the rejects file.
+func CreateRejectsFile(path string) (*RejectsFile, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewRecordWriter(FormatCSV, f, WriterConfig{Delimiter: ',', NL: NoUseCRLF})
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &RejectsFile{file: f, w: w}, nil
+}
+
+// Write writes a rejected record, it can be used as report function of [converters.Rejects].
+func (r *RejectsFile) Write(reject converters.Reject) error {
+	if !r.header {
+		r.header = true
+		if err := r.w.Write(append([]string{"row", "statement", "error"}, reject.Header...)); err != nil {
+			return err
+		}
+	}
+	row := ""
+	if reject.Row > 0 {
+		row = strconv.Itoa(reject.Row)
+	}
+	return r.w.Write(append([]string{row, reject.Statement, reject.Err.Error()}, reject.Record...))
+}
+
+// Close flushes and closes the file.
+func (r *RejectsFile) Close() error {
+	err := r.w.Flush()
+	if closeErr := r.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
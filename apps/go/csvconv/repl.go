@@ -0,0 +1,435 @@
+package csvconv
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+	"ubunatic.com/dotapps/go/csvconv/converters"
+	"ubunatic.com/dotapps/go/csvconv/csvlang"
+	"ubunatic.com/dotapps/go/tui"
+)
+
+var ErrNotTerminal = errors.New("not a terminal")
+
+const replPrompt = "csvlang> "
+
+// Repl is an interactive csvlang editor that previews the result of the query after each edit.
+type Repl struct {
+	Records converters.Records  // input records, read once
+	Rows    int                 // number of previewed rows
+	Read    csvlang.TableReader // reads the tables of join statements
+	History []string            // submitted queries, oldest first
+
+	line       []rune
+	cursor     int      // rune offset of the cursor in the line
+	hist       int      // number of steps back in the history, 0 for the draft
+	draft      []rune   // line being edited while browsing the history
+	candidates []string // completions of the last tab
+	pending    []byte   // incomplete UTF-8 sequence of the last key presses
+	tilde      bool     // the last escape sequence, e.g., "\x1b[3~", ends with a '~'
+}
+
+// NewRepl returns a REPL that previews the given number of rows of the query result.
+func NewRepl(records converters.Records, rows int) *Repl {
+	return &Repl{Records: records, Rows: rows}
+}
+
+// Line returns the edited query.
+func (r *Repl) Line() string { return string(r.line) }
+
+// SetLine replaces the edited query and moves the cursor to its end.
+func (r *Repl) SetLine(line string) {
+	r.line = []rune(line)
+	r.cursor = len(r.line)
+	r.hist = 0
+}
+
+// Candidates returns the completions of the last tab if there was more than one.
+func (r *Repl) Candidates() []string { return r.candidates }
+
+// Key handles a key press read by [tui.ReadKeycode] and reports whether the query was submitted.
+// Submitted queries are added to the history.
+func (r *Repl) Key(key tui.KeyPress) bool {
+	if r.tilde {
+		r.tilde = false
+		if key.Char == "~" {
+			return false
+		}
+	}
+	r.candidates = nil
+	if strings.HasPrefix(key.Char, "\x1b[") {
+		r.escape(key.Char[2:])
+		return false
+	}
+	if strings.HasPrefix(key.Char, "\x1b") {
+		return false // other escape sequences are not supported
+	}
+	switch key.Keycode {
+	case '\r', '\n':
+		r.submit()
+		return true
+	case '\t':
+		r.candidates = r.Complete()
+	case 127, 8: // backspace
+		if r.cursor > 0 {
+			r.line = slices.Delete(r.line, r.cursor-1, r.cursor)
+			r.cursor--
+		}
+	case 1: // Ctrl+A
+		r.cursor = 0
+	case 5: // Ctrl+E
+		r.cursor = len(r.line)
+	case 11: // Ctrl+K
+		r.line = r.line[:r.cursor]
+	case 21: // Ctrl+U
+		r.line = slices.Delete(r.line, 0, r.cursor)
+		r.cursor = 0
+	case 23: // Ctrl+W
+		start := r.cursor
+		for start > 0 && unicode.IsSpace(r.line[start-1]) {
+			start--
+		}
+		for start > 0 && !unicode.IsSpace(r.line[start-1]) {
+			start--
+		}
+		r.line = slices.Delete(r.line, start, r.cursor)
+		r.cursor = start
+	default:
+		if key.Keycode >= ' ' {
+			r.insert(key.Char)
+		}
+	}
+	return false
+}
+
+// escape handles the escape sequence after "\x1b[", e.g., "A" for the up arrow.
+func (r *Repl) escape(seq string) {
+	switch seq {
+	case "A":
+		r.browse(1)
+	case "B":
+		r.browse(-1)
+	case "C":
+		r.cursor = min(r.cursor+1, len(r.line))
+	case "D":
+		r.cursor = max(r.cursor-1, 0)
+	case "H":
+		r.cursor = 0
+	case "F":
+		r.cursor = len(r.line)
+	case "3": // delete
+		if r.cursor < len(r.line) {
+			r.line = slices.Delete(r.line, r.cursor, r.cursor+1)
+		}
+		r.tilde = true
+	default:
+		r.tilde = seq != "" && seq[0] >= '0' && seq[0] <= '9'
+	}
+}
+
+// insert inserts the text at the cursor. Incomplete UTF-8 sequences are kept until
+// the remaining bytes are read.
+func (r *Repl) insert(text string) {
+	r.pending = append(r.pending, text...)
+	for len(r.pending) > 0 && utf8.FullRune(r.pending) {
+		c, size := utf8.DecodeRune(r.pending)
+		r.pending = r.pending[size:]
+		r.line = slices.Insert(r.line, r.cursor, c)
+		r.cursor++
+	}
+}
+
+// browse shows the previous (steps > 0) or next (steps < 0) query of the history.
+func (r *Repl) browse(steps int) {
+	hist := min(max(r.hist+steps, 0), len(r.History))
+	if hist == r.hist {
+		return
+	}
+	if r.hist == 0 {
+		r.draft = r.line
+	}
+	r.hist = hist
+	if hist == 0 {
+		r.line = r.draft
+	} else {
+		r.line = []rune(r.History[len(r.History)-hist])
+	}
+	r.cursor = len(r.line)
+}
+
+// submit adds the query to the history, unless it is empty or equal to the last query.
+func (r *Repl) submit() {
+	r.hist = 0
+	query := strings.TrimSpace(r.Line())
+	if query != "" && (len(r.History) == 0 || r.History[len(r.History)-1] != query) {
+		r.History = append(r.History, query)
+	}
+}
+
+// Complete completes the word before the cursor with a statement keyword at the start
+// of a statement, or with a column name or an argument keyword otherwise.
+// Columns are the columns of the result of the previous statements.
+// The word is completed to the longest common prefix of the candidates,
+// which are returned if there is more than one.
+func (r *Repl) Complete() []string {
+	start := r.cursor
+	for start > 0 && !unicode.IsSpace(r.line[start-1]) && !strings.ContainsRune(",()|", r.line[start-1]) {
+		start--
+	}
+	word := string(r.line[start:r.cursor])
+	before := strings.TrimRightFunc(string(r.line[:start]), unicode.IsSpace)
+
+	type completion struct{ name, text string } // matched name and inserted text
+	var completions []completion
+	switch {
+	case before == "" || strings.HasSuffix(before, "|"):
+		for _, keyword := range csvlang.Keywords {
+			completions = append(completions, completion{keyword, keyword + " "})
+		}
+	case strings.HasPrefix(word, `"`):
+		word = word[1:]
+		for _, name := range r.columns(before) {
+			text := quoteColumn(name)
+			if !strings.HasPrefix(text, `"`) {
+				text = `"` + text + `"`
+			}
+			completions = append(completions, completion{name, text})
+		}
+	default:
+		for _, name := range r.columns(before) {
+			completions = append(completions, completion{name, quoteColumn(name)})
+		}
+		for _, keyword := range csvlang.ArgKeywords {
+			completions = append(completions, completion{keyword, keyword + " "})
+		}
+	}
+	var candidates, texts []string
+	for _, c := range completions {
+		if strings.HasPrefix(strings.ToLower(c.name), strings.ToLower(word)) && !slices.Contains(candidates, c.name) {
+			candidates = append(candidates, c.name)
+			texts = append(texts, c.text)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return nil
+	case 1:
+		r.replace(start, texts[0])
+		return nil
+	}
+	if prefix := commonPrefix(texts); utf8.RuneCountInString(prefix) > utf8.RuneCountInString(string(r.line[start:r.cursor])) {
+		r.replace(start, prefix)
+	}
+	return candidates
+}
+
+// replace replaces the text from start to the cursor.
+func (r *Repl) replace(start int, text string) {
+	r.line = slices.Concat(r.line[:start], []rune(text), r.line[r.cursor:])
+	r.cursor = start + utf8.RuneCountInString(text)
+}
+
+// columns returns the columns of the result of the statements before the current statement,
+// or the input columns if the statements are invalid.
+func (r *Repl) columns(text string) []string {
+	tokens, err := csvlang.Lex(text)
+	if err != nil {
+		return r.Records.HeaderRow()
+	}
+	prefix := ""
+	for _, tok := range tokens {
+		if tok.Is(csvlang.TokenPipe) {
+			prefix = text[:tok.Pos]
+		}
+	}
+	result, err := r.Result(prefix)
+	if err != nil {
+		return r.Records.HeaderRow()
+	}
+	return result.HeaderRow()
+}
+
+// quoteColumn returns the column name, quoted if it contains special characters.
+func quoteColumn(name string) string {
+	if name == "" || strings.ContainsAny(name, " \t,()|'\"=<>!~#$\\:") {
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(name) + `"`
+	}
+	return name
+}
+
+// commonPrefix returns the longest common prefix of the texts.
+func commonPrefix(texts []string) string {
+	prefix := texts[0]
+	for _, text := range texts[1:] {
+		for !strings.HasPrefix(text, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
+
+// Result returns the result of the query applied to a copy of the records.
+func (r *Repl) Result(query string) (converters.Records, error) {
+	statements, err := csvlang.Parse(query)
+	if err != nil {
+		return nil, err
+	}
+	if r.Read != nil {
+		csvlang.SetTableReader(statements, r.Read)
+	}
+	records := make(converters.Records, len(r.Records))
+	for i, record := range r.Records {
+		records[i] = slices.Clone(record) // some statements change the records in place
+	}
+	return converters.ApplyRows(records, csvlang.RowConverters(statements)...)
+}
+
+// Preview returns the first rows of the result of the query as a table
+// and the number of rows, or the error of the query.
+func (r *Repl) Preview(query string) string {
+	result, err := r.Result(query)
+	var synErr *csvlang.SyntaxError
+	switch {
+	case errors.As(err, &synErr):
+		return fmt.Sprintf("%v\n%s\n", synErr, synErr.Snippet())
+	case err != nil:
+		return fmt.Sprintf("error: %v\n", err)
+	}
+	data, err := WriteRecords(FormatTable, WriterConfig{Delimiter: ','}, result.Head(r.Rows))
+	if err != nil {
+		return fmt.Sprintf("error: %v\n", err)
+	}
+	return fmt.Sprintf("%s(%d of %d rows)\n", data, min(r.Rows, result.Count()), result.Count())
+}
+
+// Run edits queries in the terminal until Ctrl+C or Ctrl+D is pressed.
+// Submitted queries are passed to save, e.g., to append them to a history file.
+func (r *Repl) Run(in *os.File, out io.Writer, save func(query string) error) error {
+	fd := int(in.Fd())
+	if !term.IsTerminal(fd) {
+		return ErrNotTerminal
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, state)
+
+	keys, err := tui.ReadKeycode(in)
+	if err != nil {
+		return err
+	}
+	width, height, err := term.GetSize(fd)
+	if err != nil || width <= 0 || height <= 0 {
+		width, height = 80, 24
+	}
+
+	fmt.Fprint(out, "Tab completes, Up and Down browse the history, Enter keeps the result, Ctrl+D exits.\r\n")
+	r.render(out, width, height, false)
+	for key := range keys {
+		if r.Key(key) {
+			r.render(out, width, height, true)
+			if query := strings.TrimSpace(r.Line()); query != "" && save != nil {
+				if err := save(query); err != nil {
+					return err
+				}
+			}
+			r.SetLine("")
+		}
+		r.render(out, width, height, false)
+	}
+	fmt.Fprint(out, "\r\x1b[J")
+	return nil
+}
+
+// render draws the prompt with the completions and the preview below it.
+// Unless the query was submitted, the preview is redrawn after the next key
+// and the cursor is moved back into the prompt.
+func (r *Repl) render(out io.Writer, width, height int, submitted bool) {
+	lines := []string{replPrompt + r.Line()}
+	if len(r.candidates) > 0 {
+		lines = append(lines, strings.Join(r.candidates, "  "))
+	}
+	lines = append(lines, strings.Split(strings.TrimSuffix(r.Preview(r.Line()), "\n"), "\n")...)
+	lines = lines[:min(len(lines), max(height-1, 1))]
+	for i := 1; i < len(lines); i++ {
+		lines[i] = truncateWidth(lines[i], width-1)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\r\x1b[J" + strings.Join(lines, "\r\n"))
+	if submitted {
+		sb.WriteString("\r\n")
+	} else {
+		if len(lines) > 1 {
+			fmt.Fprintf(&sb, "\x1b[%dA", len(lines)-1)
+		}
+		sb.WriteString("\r")
+		if col := DisplayWidth(replPrompt + string(r.line[:r.cursor])); col > 0 {
+			fmt.Fprintf(&sb, "\x1b[%dC", col)
+		}
+	}
+	io.WriteString(out, sb.String())
+}
+
+// truncateWidth returns the prefix of s that fits into the given display width.
+func truncateWidth(s string, width int) string {
+	n := 0
+	for i, c := range s {
+		if n += DisplayWidth(string(c)); n > width {
+			return s[:i]
+		}
+	}
+	return s
+}
+
+// DefaultHistoryFile returns the path of the REPL history in the user config directory,
+// e.g., ~/.config/csvconv/history, or an empty string if there is no config directory.
+func DefaultHistoryFile() string {
+	return configFile("history")
+}
+
+// ReadHistory reads the queries of a history file, one per line.
+// A missing history file is empty.
+func ReadHistory(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	history := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			history = append(history, line)
+		}
+	}
+	return history, scanner.Err()
+}
+
+// AppendHistory appends a query to the history file.
+func AppendHistory(file, query string) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(f, query)
+	return errors.Join(err, f.Close())
+}
@@ -0,0 +1,64 @@
+package csvconv
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"ubunatic.com/dotapps/go/csvconv/converters"
+)
+
+// openInput opens src, or the inputs of [WithInputs], and returns a reader for the input options.
+// An input without records is an error.
+func openInput(src string, o *options) (RecordReader, Sniffed, io.Closer, error) {
+	files := o.inputs
+	if len(files) == 0 {
+		files = []string{src}
+	}
+	return openInputs(files, o)
+}
+
+// InferSchemaFile infers the schema from the first records of src, see [converters.InferSchema].
+// A sample size of 0 reads all records.
+func InferSchemaFile(src string, sample int, opts ...Opt) (*converters.Schema, error) {
+	r, _, in, err := openInput(src, newOptions(opts...))
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+
+	records := converters.Records{}
+	for sample <= 0 || len(records) <= sample {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return converters.InferSchema(records), nil
+}
+
+// ValidateFile validates src against the schema, see [converters.ValidateRows].
+// Invalid values are passed to report, which may return an error to stop validation.
+func ValidateFile(src string, schema *converters.Schema, report func(converters.RowError) error, opts ...Opt) error {
+	r, _, in, err := openInput(src, newOptions(opts...))
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	rows, err := streamCsv(r, discardWriter{}, []converters.RowConverter{converters.ValidateRows(schema, report)})
+	if err == nil && rows == 0 {
+		return fmt.Errorf("%w: no header", converters.ErrSchemaMismatch)
+	}
+	return err
+}
+
+// discardWriter is a [RecordWriter] that drops all records.
+type discardWriter struct{}
+
+func (discardWriter) Write(record []string) error { return nil }
+func (discardWriter) Flush() error                { return nil }
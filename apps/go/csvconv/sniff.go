@@ -0,0 +1,296 @@
+package csvconv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"ubunatic.com/dotapps/go/csvconv/converters"
+)
+
+// Encoding names supported for input and output files.
+const (
+	AutoEncoding = ""
+	UTF8         = "utf-8"
+	UTF8BOM      = "utf-8-bom" // UTF-8 with byte order mark
+	UTF16LE      = "utf-16le"
+	UTF16BE      = "utf-16be"
+	Windows1252  = "windows-1252"
+	ISO88591     = "iso-8859-1"
+)
+
+var ErrInvalidEncoding = errors.New("invalid encoding")
+
+// Encodings maps encoding names to their decoders and encoders.
+// UTF-8 is the native encoding and needs no transformation.
+// Encoders of UTF-8 with BOM and UTF-16 write a byte order mark.
+var Encodings = map[string]encoding.Encoding{
+	UTF8:        nil,
+	UTF8BOM:     unicode.UTF8BOM,
+	UTF16LE:     unicode.UTF16(unicode.LittleEndian, unicode.UseBOM),
+	UTF16BE:     unicode.UTF16(unicode.BigEndian, unicode.UseBOM),
+	Windows1252: charmap.Windows1252,
+	ISO88591:    charmap.ISO8859_1,
+}
+
+func ParseEncoding(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "auto", "":
+		return AutoEncoding, nil
+	case "utf8":
+		return UTF8, nil
+	case "utf8bom", "utf-8-sig", "utf8-bom", "bom":
+		return UTF8BOM, nil
+	case "utf-16", "utf16", "utf16le":
+		return UTF16LE, nil
+	case "utf16be":
+		return UTF16BE, nil
+	case "cp1252", "win-1252", "windows1252":
+		return Windows1252, nil
+	case "latin1", "latin-1", "iso8859-1", "iso-8859-1":
+		return ISO88591, nil
+	}
+	if _, ok := Encodings[s]; ok {
+		return s, nil
+	}
+	return AutoEncoding, fmt.Errorf("%w: %q", ErrInvalidEncoding, s)
+}
+
+// HeaderMode defines whether the first record of the input is a header.
+type HeaderMode string
+
+const (
+	HeaderAuto HeaderMode = ""    // guess from the data
+	HeaderYes  HeaderMode = "yes" // the first record is the header
+	HeaderNo   HeaderMode = "no"  // column numbers "1", "2", ... are used as header
+)
+
+func ParseHeaderMode(s string) (HeaderMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "auto", "":
+		return HeaderAuto, nil
+	case "yes", "true", "y":
+		return HeaderYes, nil
+	case "no", "false", "n", "none":
+		return HeaderNo, nil
+	default:
+		return HeaderAuto, fmt.Errorf("%w: invalid header mode %q", ErrInvalidFormat, s)
+	}
+}
+
+// Sniffed describes the detected properties of an input.
+type Sniffed struct {
+	Encoding  string
+	BOM       bool
+	NL        NLMode
+	Delimiter rune
+	Header    HeaderMode
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+var boms = []struct {
+	bom      []byte
+	encoding string
+}{
+	{utf8BOM, UTF8},
+	{[]byte{0xFF, 0xFE}, UTF16LE},
+	{[]byte{0xFE, 0xFF}, UTF16BE},
+}
+
+// DetectEncoding detects the encoding of a data sample using its byte order mark,
+// the distribution of zero bytes for UTF-16, and UTF-8 validity.
+// Data that is not valid UTF-8 is assumed to be Windows-1252 or ISO-8859-1.
+func DetectEncoding(data []byte, truncated bool) (name string, bom bool) {
+	for _, b := range boms {
+		if bytes.HasPrefix(data, b.bom) {
+			return b.encoding, true
+		}
+	}
+
+	// UTF-16 text in Latin script has a zero byte in every other position
+	var even, odd int
+	for i, c := range data {
+		if c == 0 && i%2 == 0 {
+			even++
+		} else if c == 0 {
+			odd++
+		}
+	}
+	if half := len(data) / 2; half > 0 {
+		switch {
+		case odd > half*3/4:
+			return UTF16LE, false
+		case even > half*3/4:
+			return UTF16BE, false
+		}
+	}
+
+	if truncated {
+		data = trimPartialRune(data)
+	}
+	if utf8.Valid(data) {
+		return UTF8, false
+	}
+	if slices.ContainsFunc(data, func(c byte) bool { return c >= 0x80 && c <= 0x9F }) {
+		return Windows1252, false // these bytes are control characters in ISO-8859-1
+	}
+	return ISO88591, false
+}
+
+// trimPartialRune removes an incomplete UTF-8 sequence at the end of the data.
+func trimPartialRune(data []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		if utf8.RuneStart(data[len(data)-i]) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				return data[:len(data)-i]
+			}
+			break
+		}
+	}
+	return data
+}
+
+// delimiters are the candidates for delimiter sniffing, in order of preference.
+var delimiters = []rune{',', ';', '\t', '|'}
+
+// SniffDelimiter detects the delimiter of a CSV sample. It prefers the delimiter
+// that occurs the same number of times in most lines, ignoring quoted text.
+// It returns false if no candidate occurs in the first line.
+func SniffDelimiter(sample string) (rune, bool) {
+	lines := sampleLines(sample, 20)
+	best, bestScore, bestCount := ',', 0.0, 0
+	for _, delim := range delimiters {
+		counts := make([]int, len(lines))
+		for i, line := range lines {
+			counts[i] = countUnquoted(line, delim)
+		}
+		if len(counts) == 0 || counts[0] == 0 {
+			continue
+		}
+		consistent := 0
+		for _, n := range counts {
+			if n == counts[0] {
+				consistent++
+			}
+		}
+		score := float64(consistent) / float64(len(counts))
+		if score > bestScore || (score == bestScore && counts[0] > bestCount) {
+			best, bestScore, bestCount = delim, score, counts[0]
+		}
+	}
+	return best, bestScore > 0
+}
+
+// sampleLines returns up to n complete lines of the sample.
+// The last line is dropped if the sample has more than one line,
+// since it may be cut off.
+func sampleLines(sample string, n int) []string {
+	lines := strings.Split(strings.ReplaceAll(sample, "\r\n", "\n"), "\n")
+	if len(lines) > 1 {
+		lines = lines[:len(lines)-1]
+	}
+	lines = slices.DeleteFunc(lines, func(line string) bool { return strings.TrimSpace(line) == "" })
+	return lines[:min(n, len(lines))]
+}
+
+func countUnquoted(line string, delim rune) int {
+	n, quoted := 0, false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == delim && !quoted:
+			n++
+		}
+	}
+	return n
+}
+
+// GuessHeader reports whether the first record is a header.
+// The first record is only considered data if each of its fields has the same kind
+// of value as the second record, e.g., a number, a date, or text, and at least one
+// of them is a number or date. Empty fields match any kind.
+func GuessHeader(records [][]string) bool {
+	if len(records) < 2 || len(records[0]) != len(records[1]) {
+		return true
+	}
+	values := 0
+	for i, value := range records[0] {
+		kind, other := valueKind(value), valueKind(records[1][i])
+		switch {
+		case kind == kindEmpty || other == kindEmpty:
+		case kind != other:
+			return true
+		case kind != kindText:
+			values++
+		}
+	}
+	return values == 0
+}
+
+type fieldKind int
+
+const (
+	kindEmpty fieldKind = iota
+	kindText
+	kindNumber
+	kindDate
+)
+
+func valueKind(field string) fieldKind {
+	field = strings.TrimSpace(field)
+	if field == "" {
+		return kindEmpty
+	}
+	if converters.IsNumber(field, converters.NumberDot) || converters.IsNumber(field, converters.NumberComma) {
+		return kindNumber
+	}
+	if _, err := converters.ParseDate(field, converters.DateAny); err == nil || errors.Is(err, converters.ErrAmbiguousDate) {
+		return kindDate
+	}
+	return kindText
+}
+
+// sniffCsv detects the delimiter and header of a decoded CSV sample.
+// Settings in cfg that are not automatic are kept.
+func sniffCsv(sample string, cfg ReaderConfig) (delim rune, header HeaderMode) {
+	delim, header = cfg.Delimiter, cfg.Header
+	if sep, rest, ok := cutSepLine(sample); ok {
+		delim, sample = sep, rest
+	}
+	if delim == 0 {
+		delim, _ = SniffDelimiter(sample)
+	}
+	if header == HeaderAuto {
+		r := csv.NewReader(strings.NewReader(strings.Join(sampleLines(sample, 2), "\n")))
+		r.Comma = delim
+		r.LazyQuotes = true
+		r.FieldsPerRecord = -1
+		records, _ := r.ReadAll() // partial records are fine for guessing
+		header = HeaderYes
+		if !GuessHeader(records) {
+			header = HeaderNo
+		}
+	}
+	return delim, header
+}
+
+// cutSepLine removes an Excel "sep=;" line and returns the delimiter.
+func cutSepLine(sample string) (sep rune, rest string, ok bool) {
+	line, rest, found := strings.Cut(sample, "\n")
+	line = strings.TrimSuffix(line, "\r")
+	if !found || !strings.HasPrefix(line, "sep=") || utf8.RuneCountInString(line) != len("sep=")+1 {
+		return 0, sample, false
+	}
+	sep, _ = utf8.DecodeRuneInString(line[len("sep="):])
+	return sep, rest, true
+}
@@ -0,0 +1,137 @@
+package csvconv
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ubunatic.com/dotapps/go/csvconv/converters"
+)
+
+// peekSize is the number of bytes used to detect the newline mode of a stream.
+const peekSize = 64 * 1024
+
+// ReadStream returns a record reader for the given input and the detected properties of the input.
+// The input is decoded to UTF-8 without byte order mark. Automatic settings of cfg,
+// such as the encoding, delimiter, and header, are sniffed from the start of the input.
+func ReadStream(format InputFormat, cfg ReaderConfig, input io.Reader) (RecordReader, Sniffed, error) {
+	sniffed := Sniffed{Encoding: cfg.Encoding}
+	br := bufio.NewReaderSize(input, peekSize)
+	data, err := br.Peek(peekSize) // partial data is fine for detection
+	if sniffed.Encoding == AutoEncoding {
+		sniffed.Encoding, sniffed.BOM = DetectEncoding(data, err == nil)
+	}
+	enc, ok := Encodings[sniffed.Encoding]
+	if !ok {
+		return nil, sniffed, fmt.Errorf("%w: %q", ErrInvalidEncoding, sniffed.Encoding)
+	}
+
+	var decoded io.Reader = br
+	switch {
+	case enc != nil:
+		decoded = enc.NewDecoder().Reader(br) // also removes a UTF-16 BOM
+	case bytes.HasPrefix(data, utf8BOM):
+		br.Discard(len(utf8BOM))
+		sniffed.BOM = true
+	}
+
+	dr := bufio.NewReaderSize(decoded, peekSize)
+	data, err = dr.Peek(peekSize)
+	sniffed.NL = DetectNewline(data)
+	if format == InputTSV {
+		cfg.Delimiter = '\t'
+	}
+	if format == InputCSV || format == InputTSV {
+		sample := string(data)
+		if err == nil {
+			sample = string(trimPartialRune(data)) // cut off, drop partial rune
+		} else if !strings.HasSuffix(sample, "\n") {
+			sample += "\n" // complete input, keep the last line
+		}
+		cfg.Delimiter, cfg.Header = sniffCsv(sample, cfg)
+		sniffed.Delimiter, sniffed.Header = cfg.Delimiter, cfg.Header
+	}
+	slog.Debug("Sniffed input", "format", format,
+		"encoding", sniffed.Encoding, "bom", sniffed.BOM, "newline", sniffed.NL,
+		"delimiter", string(sniffed.Delimiter), "header", sniffed.Header,
+	)
+
+	r, err := NewRecordReader(format, dr, cfg)
+	return r, sniffed, err
+}
+
+// createOutput creates the output file. If the output is also the input,
+// the data is written to a temporary file that replaces dst on close.
+// The returned close function discards the temporary file if err is not nil.
+func createOutput(src, dst string) (f *os.File, close func(err error) error, err error) {
+	if src != dst {
+		f, err = os.Create(dst)
+		if err != nil {
+			return nil, nil, err
+		}
+		close = func(err error) error {
+			closeErr := f.Close()
+			if err != nil {
+				return err
+			}
+			return closeErr
+		}
+		return f, close, nil
+	}
+
+	f, err = os.CreateTemp(filepath.Dir(dst), ".csvconv-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	close = func(err error) error {
+		closeErr := f.Close()
+		if err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Chmod(f.Name(), 0o644)
+		}
+		if err != nil {
+			os.Remove(f.Name())
+			return err
+		}
+		return os.Rename(f.Name(), dst)
+	}
+	return f, close, nil
+}
+
+func streamCsv(r RecordReader, w RecordWriter, stages []converters.RowConverter) (rows int, err error) {
+	p := converters.NewPipeline(func(record []string) error {
+		rows++
+		return w.Write(record)
+	}, stages...)
+
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return rows, err
+		}
+		err = p.Push(record)
+		if errors.Is(err, converters.ErrStop) {
+			slog.Debug("Stopped reading early", "records", rows)
+			break
+		}
+		if err != nil {
+			return rows, err
+		}
+	}
+
+	if err := p.Flush(); err != nil {
+		return rows, err
+	}
+	return rows, w.Flush()
+}
@@ -0,0 +1,431 @@
+package csvconv
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/width"
+	"ubunatic.com/dotapps/go/csvconv/converters"
+)
+
+// OutputFormat is the file format of the converted records.
+type OutputFormat string
+
+const (
+	AutoFormat     OutputFormat = ""         // inferred from the output file name
+	FormatCSV      OutputFormat = "csv"      // delimited values
+	FormatTSV      OutputFormat = "tsv"      // tab-separated values
+	FormatJSON     OutputFormat = "json"     // array of objects keyed by header
+	FormatJSONL    OutputFormat = "jsonl"    // one object per line
+	FormatMarkdown OutputFormat = "markdown" // markdown table
+	FormatTable    OutputFormat = "table"    // plain text with aligned columns
+)
+
+var ErrInvalidFormat = errors.New("invalid format")
+
+func MustParseOutputFormat(s string) OutputFormat { return converters.MustParse(s, ParseOutputFormat) }
+func ParseOutputFormat(s string) (OutputFormat, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "auto", "":
+		return AutoFormat, nil
+	case "md":
+		return FormatMarkdown, nil
+	case "ndjson":
+		return FormatJSONL, nil
+	case "text", "txt", "aligned":
+		return FormatTable, nil
+	}
+	if _, ok := Writers[OutputFormat(s)]; ok {
+		return OutputFormat(s), nil
+	}
+	return AutoFormat, fmt.Errorf("%w: %q", ErrInvalidFormat, s)
+}
+
+// outputExtensions maps file extensions to output formats.
+var outputExtensions = map[string]OutputFormat{
+	".csv":      FormatCSV,
+	".tsv":      FormatTSV,
+	".tab":      FormatTSV,
+	".json":     FormatJSON,
+	".jsonl":    FormatJSONL,
+	".ndjson":   FormatJSONL,
+	".md":       FormatMarkdown,
+	".markdown": FormatMarkdown,
+	".txt":      FormatTable,
+}
+
+// FormatFromPath infers the output format from the file extension.
+// Unknown extensions use CSV.
+func FormatFromPath(path string) OutputFormat {
+	if format, ok := outputExtensions[strings.ToLower(filepath.Ext(path))]; ok {
+		return format
+	}
+	return FormatCSV
+}
+
+// RecordWriter writes records to an output. The first record is the header.
+type RecordWriter interface {
+	Write(record []string) error
+	// Flush writes all buffered data and must be called after the last record.
+	Flush() error
+}
+
+// WriterConfig configures a [RecordWriter].
+type WriterConfig struct {
+	Delimiter rune
+	NL        NLMode
+	Encoding  string    // output encoding, see [Encodings], empty for UTF-8
+	Quote     QuoteMode // quoting of CSV fields
+}
+
+// QuoteMode defines which CSV fields are quoted.
+type QuoteMode string
+
+const (
+	QuoteMinimal    QuoteMode = ""            // fields with delimiters, quotes, or newlines
+	QuoteAll        QuoteMode = "all"         // all fields
+	QuoteNonNumeric QuoteMode = "non-numeric" // all fields except numbers
+)
+
+func ParseQuoteMode(s string) (QuoteMode, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "minimal", "":
+		return QuoteMinimal, nil
+	case "all":
+		return QuoteAll, nil
+	case "non-numeric", "nonnumeric":
+		return QuoteNonNumeric, nil
+	default:
+		return QuoteMinimal, fmt.Errorf("%w: invalid quote mode %q", ErrInvalidFormat, s)
+	}
+}
+
+// NewWriter creates a [RecordWriter] for an output format.
+type NewWriter func(w io.Writer, cfg WriterConfig) RecordWriter
+
+// Writers lists the writers of all output formats.
+// Additional formats can be added before parsing any options.
+var Writers = map[OutputFormat]NewWriter{
+	FormatCSV: newCsvWriter,
+	FormatTSV: func(w io.Writer, cfg WriterConfig) RecordWriter {
+		cfg.Delimiter = '\t'
+		return newCsvWriter(w, cfg)
+	},
+	FormatJSON:     func(w io.Writer, cfg WriterConfig) RecordWriter { return newJSONWriter(w, cfg, false) },
+	FormatJSONL:    func(w io.Writer, cfg WriterConfig) RecordWriter { return newJSONWriter(w, cfg, true) },
+	FormatMarkdown: func(w io.Writer, cfg WriterConfig) RecordWriter { return newTableWriter(w, cfg, true) },
+	FormatTable:    func(w io.Writer, cfg WriterConfig) RecordWriter { return newTableWriter(w, cfg, false) },
+}
+
+// NewRecordWriter returns a writer for the given output format and encoding.
+// Characters that cannot be encoded are replaced.
+func NewRecordWriter(format OutputFormat, w io.Writer, cfg WriterConfig) (RecordWriter, error) {
+	newWriter, ok := Writers[format]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidFormat, format)
+	}
+	enc, ok := Encodings[cmp.Or(cfg.Encoding, UTF8)]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidEncoding, cfg.Encoding)
+	}
+	if enc == nil {
+		return newWriter(w, cfg), nil
+	}
+	ew := transform.NewWriter(w, encoding.ReplaceUnsupported(enc.NewEncoder()))
+	return &encodedWriter{RecordWriter: newWriter(ew, cfg), encoder: ew}, nil
+}
+
+// encodedWriter closes the encoder after flushing the records.
+type encodedWriter struct {
+	RecordWriter
+	encoder io.WriteCloser
+}
+
+func (w *encodedWriter) Flush() error {
+	if err := w.RecordWriter.Flush(); err != nil {
+		return err
+	}
+	return w.encoder.Close()
+}
+
+// WriteRecords writes all records in the given format.
+func WriteRecords(format OutputFormat, cfg WriterConfig, records [][]string) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := NewRecordWriter(format, &buf, cfg)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// csvWriter adapts [csv.Writer] to the [RecordWriter] interface.
+type csvWriter struct {
+	*csv.Writer
+}
+
+func newCsvWriter(w io.Writer, cfg WriterConfig) RecordWriter {
+	if cfg.Quote != QuoteMinimal {
+		return &quotingWriter{w: bufio.NewWriter(w), cfg: cfg}
+	}
+	cw := csv.NewWriter(w)
+	cw.Comma = cfg.Delimiter
+	cw.UseCRLF = cfg.NL == UseCRLF
+	return &csvWriter{cw}
+}
+
+func (w *csvWriter) Flush() error {
+	w.Writer.Flush()
+	return w.Error()
+}
+
+// quotingWriter writes CSV with additional quotes, see [QuoteMode].
+type quotingWriter struct {
+	w   *bufio.Writer
+	cfg WriterConfig
+}
+
+func (w *quotingWriter) Write(record []string) error {
+	for i, value := range record {
+		if i > 0 {
+			if _, err := w.w.WriteRune(w.cfg.Delimiter); err != nil {
+				return err
+			}
+		}
+		if w.needsQuotes(value) {
+			value = `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+		}
+		if _, err := w.w.WriteString(value); err != nil {
+			return err
+		}
+	}
+	_, err := w.w.WriteString(w.cfg.NL.Delim())
+	return err
+}
+
+func (w *quotingWriter) needsQuotes(value string) bool {
+	switch {
+	case w.cfg.Quote == QuoteAll:
+		return true
+	case strings.ContainsRune(value, w.cfg.Delimiter) || strings.ContainsAny(value, "\"\r\n"):
+		return true
+	case strings.HasPrefix(value, " ") || strings.HasPrefix(value, "\t"):
+		return true
+	}
+	return !isNumeric(value)
+}
+
+// isNumeric reports whether the value is a number in dot or comma format.
+func isNumeric(value string) bool {
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return true
+	}
+	return converters.IsNumber(value, converters.NumberDot) || converters.IsNumber(value, converters.NumberComma)
+}
+
+func (w *quotingWriter) Flush() error {
+	return w.w.Flush()
+}
+
+// jsonWriter writes records as JSON objects keyed by the header.
+// Fields without a header are keyed by their 1-based column number.
+// Repeated keys are numbered, e.g., "name", "name_2", see [jsonKeys].
+type jsonWriter struct {
+	w     *bufio.Writer
+	nl    string
+	lines bool
+	keys  []string
+	rows  int
+}
+
+func newJSONWriter(w io.Writer, cfg WriterConfig, lines bool) RecordWriter {
+	return &jsonWriter{w: bufio.NewWriter(w), nl: cfg.NL.Delim(), lines: lines}
+}
+
+func (w *jsonWriter) Write(record []string) error {
+	if w.keys == nil {
+		w.keys = jsonKeys(record, len(record))
+		return nil
+	}
+	if len(record) > len(w.keys) {
+		w.keys = jsonKeys(w.keys, len(record))
+	}
+	switch {
+	case w.lines:
+	case w.rows == 0:
+		w.w.WriteString("[" + w.nl + "  ")
+	default:
+		w.w.WriteString("," + w.nl + "  ")
+	}
+	w.rows++
+
+	w.w.WriteByte('{')
+	for i, key := range w.keys {
+		if i > 0 {
+			w.w.WriteString(", ")
+		}
+		writeJSONString(w.w, key)
+		w.w.WriteString(": ")
+		writeJSONString(w.w, converters.Field(record, i))
+	}
+	// the errors of the buffered writer are sticky and returned by the last write
+	err := w.w.WriteByte('}')
+	if w.lines && err == nil {
+		_, err = w.w.WriteString(w.nl)
+	}
+	return err
+}
+
+// jsonKeys returns n unique keys for the header. Repeated names are numbered,
+// e.g., "name_2", and fields without a header are keyed by their column number.
+func jsonKeys(header []string, n int) []string {
+	keys := make([]string, max(n, len(header)))
+	used := map[string]bool{}
+	for i := range keys {
+		name := strconv.Itoa(i + 1)
+		if i < len(header) {
+			name = header[i]
+		}
+		key := name
+		for c := 2; used[key]; c++ {
+			key = name + "_" + strconv.Itoa(c)
+		}
+		used[key] = true
+		keys[i] = key
+	}
+	return keys
+}
+
+func (w *jsonWriter) Flush() error {
+	switch {
+	case w.lines:
+	case w.rows == 0:
+		w.w.WriteString("[]" + w.nl)
+	default:
+		w.w.WriteString(w.nl + "]" + w.nl)
+	}
+	return w.w.Flush()
+}
+
+func writeJSONString(w *bufio.Writer, s string) {
+	data, _ := json.Marshal(s) // strings cannot fail
+	w.Write(data)
+}
+
+// tableWriter buffers all records and writes them with aligned columns,
+// either as plain text or as a markdown table.
+type tableWriter struct {
+	w        io.Writer
+	nl       string
+	markdown bool
+	records  [][]string
+	widths   []int
+}
+
+func newTableWriter(w io.Writer, cfg WriterConfig, markdown bool) RecordWriter {
+	return &tableWriter{w: w, nl: cfg.NL.Delim(), markdown: markdown}
+}
+
+func (w *tableWriter) Write(record []string) error {
+	cells := make([]string, len(record))
+	for i, value := range record {
+		cells[i] = w.cell(value)
+		if i >= len(w.widths) {
+			w.widths = append(w.widths, 0)
+		}
+		w.widths[i] = max(w.widths[i], DisplayWidth(cells[i]))
+	}
+	w.records = append(w.records, cells)
+	return nil
+}
+
+// cell escapes a value so that it fits into a single table cell.
+func (w *tableWriter) cell(value string) string {
+	value = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(value)
+	if w.markdown {
+		value = strings.ReplaceAll(value, "|", `\|`)
+	}
+	return value
+}
+
+func (w *tableWriter) Flush() error {
+	var sb strings.Builder
+	for i, record := range w.records {
+		w.writeRow(&sb, record)
+		if i == 0 && w.markdown {
+			sep := make([]string, len(w.widths))
+			for j, width := range w.widths {
+				sep[j] = strings.Repeat("-", max(width, 3))
+			}
+			w.writeRow(&sb, sep)
+		}
+	}
+	w.records = nil
+	_, err := io.WriteString(w.w, sb.String())
+	return err
+}
+
+func (w *tableWriter) writeRow(sb *strings.Builder, record []string) {
+	cells := make([]string, len(w.widths))
+	for i, width := range w.widths {
+		if w.markdown {
+			width = max(width, 3)
+		}
+		cells[i] = pad(converters.Field(record, i), width)
+	}
+	if w.markdown {
+		sb.WriteString("| " + strings.Join(cells, " | ") + " |" + w.nl)
+		return
+	}
+	sb.WriteString(strings.TrimRight(strings.Join(cells, "  "), " ") + w.nl)
+}
+
+// pad appends spaces to the value to fill the given display width.
+func pad(value string, n int) string {
+	return value + strings.Repeat(" ", max(n-DisplayWidth(value), 0))
+}
+
+// DisplayWidth returns the number of terminal columns needed to display s.
+// Combining marks, such as the diaeresis of a decomposed "ü", take no space,
+// and wide east asian characters take two columns.
+func DisplayWidth(s string) int {
+	n := 0
+	for _, r := range s {
+		switch {
+		case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf) || unicode.IsControl(r):
+		case isWide(r):
+			n += 2
+		default:
+			n++
+		}
+	}
+	return n
+}
+
+func isWide(r rune) bool {
+	switch width.LookupRune(r).Kind() {
+	case width.EastAsianWide, width.EastAsianFullwidth:
+		return true
+	}
+	return false
+}